@@ -0,0 +1,377 @@
+// Package archiver tags, comments on and archives stale posts by declarative rules.
+package archiver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/bulktag"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+	"github.com/hayashiki/docbase-go/internal/report"
+)
+
+// Statuses of entries
+const (
+	StatusDone    = "done"
+	StatusPlanned = "planned" // in a dry run
+	StatusFailed  = "failed"
+)
+
+// ErrNoRun is returned by Undo when the log has no actions of the run
+var ErrNoRun = errors.New("no actions of the run in the log")
+
+// Entry is the handling of a post by a rule
+type Entry struct {
+	Rule    string   `json:"rule"`
+	PostID  int      `json:"post_id"`
+	Title   string   `json:"title"`
+	URL     string   `json:"url"`
+	Actions []string `json:"actions"`
+	Status  string   `json:"status"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Report is the result of a run
+type Report struct {
+	RunID   string    `json:"run_id"`
+	DryRun  bool      `json:"dry_run"`
+	RanAt   time.Time `json:"ran_at"`
+	Entries []Entry   `json:"entries"`
+}
+
+// Count returns the number of entries of the status
+func (r *Report) Count(status string) int {
+	return report.Count(r.Entries, func(e Entry) bool { return e.Status == status })
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// Print writes a line per post
+func (r *Report) Print(w io.Writer) error {
+	var b strings.Builder
+	report.Heading(&b, "Run "+r.RunID, r.DryRun)
+
+	for _, e := range r.Entries {
+		fmt.Fprintf(&b, "  %-7s %s: #%d %s [%s]%s\n", e.Status, e.Rule, e.PostID, e.Title, strings.Join(e.Actions, ", "), report.ErrorSuffix(e.Error))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// logRecord is a line of the undo log, one per action taken
+type logRecord struct {
+	RunID     string `json:"run_id"`
+	PostID    int    `json:"post_id"`
+	Action    string `json:"action"`
+	Tag       string `json:"tag,omitempty"`
+	CommentID int    `json:"comment_id,omitempty"`
+}
+
+// Runner runs policies
+type Runner struct {
+	client *docbase.Client
+	now    func() time.Time
+
+	// DryRun reports the matching posts without changing them
+	DryRun bool
+	// Log is the undo log file the actions are appended to
+	Log string
+}
+
+// New returns a Runner appending actions to the undo log file
+func New(c *docbase.Client, log string) *Runner {
+	return &Runner{client: c, now: time.Now, Log: log}
+}
+
+// Run evaluates the rules in order and takes their actions on the matching posts.
+// Archived posts and exempted posts are skipped. When an action fails, the later actions
+// on the post are not taken, while other posts are handled. Actions taken before the failure
+// are in the undo log, under the run ID of the report which is given to Undo.
+func (r *Runner) Run(p *Policy) (*Report, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := r.now()
+	report := &Report{RunID: now.UTC().Format("20060102T150405.000Z"), DryRun: r.DryRun, RanAt: now}
+
+	var log *os.File
+	if !r.DryRun {
+		if r.Log == "" {
+			return nil, errors.New("undo log is required")
+		}
+
+		var err error
+		log, err = os.OpenFile(r.Log, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+
+		if err != nil {
+			return nil, err
+		}
+		defer log.Close()
+	}
+
+	handled := make(map[int]bool)
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		posts, err := postiter.All(r.client, rule.Query)
+
+		if err != nil {
+			return report, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+
+		for _, post := range posts {
+			if handled[post.ID] || !matches(rule, post, now) || exempt(p.Exempt, post) || exempt(rule.Exempt, post) {
+				continue
+			}
+
+			handled[post.ID] = true
+			report.Entries = append(report.Entries, r.handle(rule, post, report.RunID, log))
+		}
+	}
+
+	if n := report.Count(StatusFailed); n > 0 {
+		return report, fmt.Errorf("%d posts failed", n)
+	}
+
+	return report, nil
+}
+
+func matches(rule *Rule, p *docbase.Post, now time.Time) bool {
+	if p.Archived {
+		return false
+	}
+
+	if !rule.UnchangedFor.IsZero() {
+		// a post without timestamps cannot be told to be stale
+		updated := p.LastUpdatedAt()
+		if updated.IsZero() || updated.After(rule.UnchangedFor.Before(now)) {
+			return false
+		}
+	}
+
+	if rule.Draft != nil && p.Draft != *rule.Draft {
+		return false
+	}
+
+	for _, t := range rule.Tags {
+		if !hasTag(p, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func exempt(e Exemptions, p *docbase.Post) bool {
+	for _, t := range e.Tags {
+		if hasTag(p, t) {
+			return true
+		}
+	}
+
+	for _, name := range e.Groups {
+		for _, g := range p.Groups {
+			if g.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func hasTag(p *docbase.Post, name string) bool {
+	for _, t := range p.Tags {
+		if strings.EqualFold(t.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handle takes the actions of the rule, logging each one as soon as it is taken
+func (r *Runner) handle(rule *Rule, p *docbase.Post, runID string, log io.Writer) Entry {
+	entry := Entry{Rule: rule.Name, PostID: p.ID, Title: p.Title, URL: p.URL, Status: StatusDone}
+
+	for _, a := range []string{ActionTag, ActionComment, ActionArchive} {
+		if rule.has(a) {
+			entry.Actions = append(entry.Actions, a)
+		}
+	}
+
+	if r.DryRun {
+		entry.Status = StatusPlanned
+		return entry
+	}
+
+	record := func(rec logRecord) error {
+		rec.RunID = runID
+		rec.PostID = p.ID
+		b, _ := json.Marshal(rec)
+		_, err := log.Write(append(b, '\n'))
+		return err
+	}
+
+	err := r.act(rule, p, record)
+
+	if err != nil {
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+func (r *Runner) act(rule *Rule, p *docbase.Post, record func(logRecord) error) error {
+	if rule.has(ActionTag) {
+		changed, err := r.retag(p, bulktag.AddTag(rule.Tag))
+
+		if err != nil {
+			return fmt.Errorf("tagging: %w", err)
+		}
+
+		if changed {
+			if err := record(logRecord{Action: ActionTag, Tag: rule.Tag}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if rule.has(ActionComment) {
+		c, _, err := r.client.Comments.Create(p.ID, &docbase.CommentCreateRequest{Body: rule.Comment, Notice: docbase.Bool(false)})
+
+		if err != nil {
+			return fmt.Errorf("commenting: %w", err)
+		}
+
+		if err := record(logRecord{Action: ActionComment, CommentID: c.ID}); err != nil {
+			return err
+		}
+	}
+
+	if rule.has(ActionArchive) {
+		if _, err := r.client.Posts.Archive(p.ID); err != nil {
+			return fmt.Errorf("archiving: %w", err)
+		}
+
+		if err := record(logRecord{Action: ActionArchive}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retag applies op to the tags of p like bulktag does, sending only the tags and
+// applying op again to the current tags when the post changed since it was listed.
+// It reports whether the tags were changed.
+func (r *Runner) retag(p *docbase.Post, op bulktag.Operation) (bool, error) {
+	tags, changed := op.Apply(p.TagNames())
+	if !changed {
+		return false, nil
+	}
+
+	merge := func(current *docbase.Post, _ *docbase.PostUpdateRequest) (*docbase.PostUpdateRequest, error) {
+		tags, changed = op.Apply(current.TagNames())
+		return &docbase.PostUpdateRequest{Tags: tags}, nil
+	}
+
+	if _, _, err := r.client.Posts.UpdateIfUnchanged(p.ID, p.Version(), &docbase.PostUpdateRequest{Tags: tags}, merge); err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+// Undo reverts the actions of the run in reverse order: archived posts are unarchived,
+// comments deleted and added tags removed. It returns the number of actions reverted.
+func (r *Runner) Undo(runID string) (int, error) {
+	records, err := r.readLog(runID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(records) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNoRun, runID)
+	}
+
+	var errs []string
+	undone := 0
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if err := r.undo(records[i]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s of post %d: %v", records[i].Action, records[i].PostID, err))
+			continue
+		}
+
+		undone++
+	}
+
+	if len(errs) > 0 {
+		return undone, fmt.Errorf("undoing %s: %s", runID, strings.Join(errs, "; "))
+	}
+
+	return undone, nil
+}
+
+func (r *Runner) undo(rec logRecord) error {
+	switch rec.Action {
+	case ActionArchive:
+		_, err := r.client.Posts.Unarchive(rec.PostID)
+		return err
+	case ActionComment:
+		_, err := r.client.Comments.Delete(rec.CommentID)
+		return err
+	case ActionTag:
+		p, _, err := docbase.GetUncachedJSON[docbase.Post](r.client, fmt.Sprintf("/posts/%d", rec.PostID), nil)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = r.retag(p, bulktag.RemoveTag(rec.Tag))
+		return err
+	}
+
+	return fmt.Errorf("unknown action %q", rec.Action)
+}
+
+func (r *Runner) readLog(runID string) ([]logRecord, error) {
+	f, err := os.Open(r.Log)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []logRecord
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var rec logRecord
+
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+
+		if rec.RunID == runID {
+			records = append(records, rec)
+		}
+	}
+
+	return records, scanner.Err()
+}
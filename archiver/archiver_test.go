@@ -0,0 +1,198 @@
+package archiver
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+	"github.com/hayashiki/docbase-go/testutil"
+)
+
+const testPolicy = `
+exempt:
+  tags: [handbook]
+rules:
+  - name: old meetings
+    tags: [meeting]
+    unchanged_for: 12mo
+    actions: [archive, tag, comment]
+    tag: archived
+    comment: Archived as it has not been changed for a year.
+  - name: stale drafts
+    draft: true
+    unchanged_for: 90d
+    exempt:
+      groups: [legal]
+    actions: [archive]
+`
+
+var now = time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+
+// post is written an age before now
+func post(key string, age time.Duration, draft bool, tags ...string) fakeserver.Post {
+	p := fakeserver.Post{Key: key, Author: 1, Post: docbase.Post{Title: key, Draft: draft, UpdatedAt: now.Add(-age)}}
+	for _, name := range tags {
+		p.Tags = append(p.Tags, docbase.Tag{Name: name})
+	}
+
+	return p
+}
+
+const year = 366 * 24 * time.Hour
+
+var team = fakeserver.Seed{
+	Users: []docbase.User{fakeserver.Taro},
+	Posts: []fakeserver.Post{
+		post("old meeting", year, false, "meeting", "infra"),
+		post("new meeting", time.Hour, false, "meeting"),
+		post("handbook", year, false, "Meeting", "handbook"),
+		post("stale draft", 100*24*time.Hour, true),
+		post("fresh draft", 10*24*time.Hour, true),
+		{Key: "legal draft", Author: 1, Post: docbase.Post{Title: "legal draft", Draft: true, UpdatedAt: now.Add(-year), Groups: []docbase.SimpleGroup{{Name: "legal"}}}},
+		post("meeting draft", year, true, "meeting"),
+		{Key: "already closed", Author: 1, Post: docbase.Post{Title: "already closed", Archived: true, UpdatedAt: now.Add(-year), Tags: []docbase.Tag{{Name: "meeting"}}}},
+	},
+}
+
+func TestLoadPolicy(t *testing.T) {
+	p, err := LoadPolicy(strings.NewReader(testPolicy))
+
+	if err != nil {
+		t.Fatalf("LoadPolicy returned an error: %v", err)
+	}
+
+	if got := p.Rules[0].UnchangedFor; got != (Age{Months: 12}) {
+		t.Errorf("UnchangedFor is %+v", got)
+	}
+
+	if p.Rules[1].Draft == nil || !*p.Rules[1].Draft || p.Rules[1].Exempt.Groups[0] != "legal" {
+		t.Errorf("Rule is %+v", p.Rules[1])
+	}
+
+	invalid := []string{
+		"rules:\n  - name: x\n    unchanged_for: 3 months\n    actions: [archive]\n",
+		"rules:\n  - name: x\n    actions: [delete]\n",
+		"rules:\n  - name: x\n    actions: [tag]\n",
+		"rules: []\n",
+	}
+	for _, data := range invalid {
+		if _, err := LoadPolicy(strings.NewReader(data)); err == nil {
+			t.Errorf("LoadPolicy should reject %q", data)
+		}
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	policy, _ := LoadPolicy(strings.NewReader(testPolicy))
+
+	r := New(s.Client, filepath.Join(t.TempDir(), "undo.jsonl"))
+	r.now = func() time.Time { return now }
+
+	report, err := r.Run(policy)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	got := make(map[int]Entry)
+	for _, e := range report.Entries {
+		got[e.PostID] = e
+	}
+
+	if len(got) != 3 {
+		t.Errorf("Entries are %+v", report.Entries)
+	}
+
+	// a post matching several rules is handled by the first
+	if got[s.PostIDs["meeting draft"]].Rule != "old meetings" || got[s.PostIDs["stale draft"]].Rule != "stale drafts" {
+		t.Errorf("Entries are %+v", report.Entries)
+	}
+
+	old := s.Post(s.PostIDs["old meeting"])
+	if !old.Archived || len(old.Tags) != 3 || old.Tags[2].Name != "archived" || len(old.Comments) != 1 {
+		t.Errorf("old meeting is %+v", old)
+	}
+
+	for _, name := range []string{"new meeting", "handbook", "fresh draft", "legal draft"} {
+		if p := s.Post(s.PostIDs[name]); p.Archived || len(p.Comments) != 0 {
+			t.Errorf("%s should not be handled: %+v", name, p)
+		}
+	}
+
+	undone, err := r.Undo(report.RunID)
+
+	if err != nil || undone != 7 {
+		t.Fatalf("Undo returned %d, %v, want 7", undone, err)
+	}
+
+	old = s.Post(s.PostIDs["old meeting"])
+	if old.Archived || len(old.Tags) != 2 || len(old.Comments) != 0 {
+		t.Errorf("old meeting should be restored: %+v", old)
+	}
+
+	if s.Post(s.PostIDs["stale draft"]).Archived {
+		t.Errorf("stale draft should be unarchived")
+	}
+
+	if _, err := r.Undo("unknown"); !errors.Is(err, ErrNoRun) {
+		t.Errorf("Error should be ErrNoRun but is %v", err)
+	}
+}
+
+func TestRunner_DryRun(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	policy, _ := LoadPolicy(strings.NewReader(testPolicy))
+
+	r := New(s.Client, "")
+	r.now = func() time.Time { return now }
+	r.DryRun = true
+
+	report, err := r.Run(policy)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if report.Count(StatusPlanned) != 3 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("DryRun should not change anything: %v", reqs)
+	}
+
+	var out strings.Builder
+	report.Print(&out)
+
+	if !strings.Contains(out.String(), "planned old meetings: #") || !strings.Contains(out.String(), "[tag, comment, archive]") {
+		t.Errorf("Print wrote\n%s", out.String())
+	}
+}
+
+func TestMatches_WithoutUpdatedAt(t *testing.T) {
+	var p docbase.Post
+	if err := json.Unmarshal([]byte(testutil.LoadFixture(t, "post-without-updated-at.json")), &p); err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+
+	rule := &Rule{Name: "old meetings", Tags: []string{"meeting"}, UnchangedFor: Age{Months: 12}}
+
+	if matches(rule, &p, p.CreatedAt.AddDate(0, 6, 0)) {
+		t.Errorf("A post created 6 months ago should not match")
+	}
+
+	if !matches(rule, &p, p.CreatedAt.AddDate(2, 0, 0)) {
+		t.Errorf("A post created 2 years ago should match")
+	}
+
+	p.CreatedAt = time.Time{}
+	if matches(rule, &p, now) {
+		t.Errorf("A post without timestamps should not match")
+	}
+}
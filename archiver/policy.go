@@ -0,0 +1,204 @@
+package archiver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions of rules, taken in this order
+const (
+	ActionTag     = "tag"
+	ActionComment = "comment"
+	ActionArchive = "archive"
+)
+
+// Policy is a set of rules. A post is handled by the first rule it matches.
+type Policy struct {
+	Exempt Exemptions `yaml:"exempt"`
+	Rules  []Rule     `yaml:"rules"`
+}
+
+// Exemptions keep posts with any of the tags or in any of the groups from being handled
+type Exemptions struct {
+	Tags   []string `yaml:"tags"`
+	Groups []string `yaml:"groups"`
+}
+
+// Rule selects posts and the actions taken on them
+type Rule struct {
+	Name string `yaml:"name"`
+	// Query narrows the posts with a search, all posts when empty
+	Query string `yaml:"query"`
+	// UnchangedFor matches posts not updated, or created when never updated, for the age, like "90d" or "12mo"
+	UnchangedFor Age `yaml:"unchanged_for"`
+	// Tags matches posts having all of the tags
+	Tags []string `yaml:"tags"`
+	// Draft matches drafts when true and published posts when false
+	Draft   *bool      `yaml:"draft"`
+	Exempt  Exemptions `yaml:"exempt"`
+	Actions []string   `yaml:"actions"`
+	// Tag is added by ActionTag
+	Tag string `yaml:"tag"`
+	// Comment is posted by ActionComment
+	Comment string `yaml:"comment"`
+}
+
+// Validate checks the rule has a name and its actions have what they need
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("rule %s: actions are required", r.Name)
+	}
+
+	for _, a := range r.Actions {
+		switch a {
+		case ActionTag:
+			if strings.TrimSpace(r.Tag) == "" {
+				return fmt.Errorf("rule %s: tag is required for the tag action", r.Name)
+			}
+		case ActionComment:
+			if strings.TrimSpace(r.Comment) == "" {
+				return fmt.Errorf("rule %s: comment is required for the comment action", r.Name)
+			}
+		case ActionArchive:
+		default:
+			return fmt.Errorf("rule %s: unknown action %q", r.Name, a)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) has(action string) bool {
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the rules
+func (p *Policy) Validate() error {
+	if len(p.Rules) == 0 {
+		return errors.New("policy has no rules")
+	}
+
+	for i := range p.Rules {
+		if err := p.Rules[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadPolicy reads a policy like
+//
+//	exempt:
+//	  tags: [handbook]
+//	rules:
+//	  - name: old meetings
+//	    tags: [meeting]
+//	    unchanged_for: 12mo
+//	    actions: [comment, archive]
+//	    comment: Archived as it has not been changed for a year.
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+
+	if err := yaml.NewDecoder(r).Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return p, p.Validate()
+}
+
+// LoadPolicyFile reads a policy file
+func LoadPolicyFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadPolicy(f)
+}
+
+// Age is a calendar period of years, months and days
+type Age struct {
+	Years, Months, Days int
+}
+
+var agePattern = regexp.MustCompile(`^(\d+)\s*(y|mo|w|d)$`)
+
+// ParseAge parses ages like "1y", "12mo", "2w" and "90d"
+func ParseAge(s string) (Age, error) {
+	m := agePattern.FindStringSubmatch(strings.TrimSpace(s))
+
+	if m == nil {
+		return Age{}, fmt.Errorf("invalid age %q, want like 90d, 2w, 12mo or 1y", s)
+	}
+
+	n, _ := strconv.Atoi(m[1])
+
+	switch m[2] {
+	case "y":
+		return Age{Years: n}, nil
+	case "mo":
+		return Age{Months: n}, nil
+	case "w":
+		return Age{Days: 7 * n}, nil
+	}
+
+	return Age{Days: n}, nil
+}
+
+// IsZero reports whether the age is unset
+func (a Age) IsZero() bool {
+	return a == Age{}
+}
+
+// Before returns the time the age before t
+func (a Age) Before(t time.Time) time.Time {
+	return t.AddDate(-a.Years, -a.Months, -a.Days)
+}
+
+func (a Age) String() string {
+	switch {
+	case a.IsZero():
+		return ""
+	case a.Years != 0 && a.Months == 0 && a.Days == 0:
+		return fmt.Sprintf("%dy", a.Years)
+	case a.Years == 0 && a.Months != 0 && a.Days == 0:
+		return fmt.Sprintf("%dmo", a.Months)
+	case a.Years == 0 && a.Months == 0:
+		return fmt.Sprintf("%dd", a.Days)
+	}
+
+	return fmt.Sprintf("%dy%dmo%dd", a.Years, a.Months, a.Days)
+}
+
+// UnmarshalYAML parses the age with ParseAge
+func (a *Age) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := ParseAge(value.Value)
+
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
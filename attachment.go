@@ -4,8 +4,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
-	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -46,7 +46,7 @@ func (f *File) Encode(filePath string) error {
 		return err
 	}
 
-	f.Name = file.Name()
+	f.Name = filepath.Base(file.Name())
 	fi, _ := file.Stat()
 	size := fi.Size()
 	data := make([]byte, size)
@@ -68,13 +68,7 @@ func (f FileContent) Write() []byte {
 }
 
 func (s *attachmentService) Download(attachmentID string) (*FileContent, *Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/attachments/%s", attachmentID))
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := s.client.NewRequest(http.MethodGet, fmt.Sprintf("/attachments/%s", attachmentID), nil)
 
 	if err != nil {
 		return nil, nil, err
@@ -104,24 +98,5 @@ func (s *attachmentService) Upload(filesPath []string) (*AttachmentResponse, *Re
 		files = append(files, file)
 	}
 
-	u, err := url.Parse("/attachments")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodPost, u.String(), files)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	atRes := &AttachmentResponse{}
-	resp, err := s.client.Do(req, atRes)
-
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return atRes, resp, nil
+	return doJSON[AttachmentResponse](s.client, http.MethodPost, "/attachments", files)
 }
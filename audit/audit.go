@@ -0,0 +1,222 @@
+// Package audit reports team members who need attention for security reasons.
+package audit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/report"
+)
+
+const (
+	defaultInactiveAfter = 90 * 24 * time.Hour
+	userListPerPage      = 100
+)
+
+// Kinds of findings
+const (
+	KindNoTwoStep = "no_two_step_authentication" // an owner or admin without two-step authentication
+	KindInactive  = "inactive"                   // no access within Auditor.InactiveAfter
+	KindNoGroups  = "no_groups"                  // a member of no group
+)
+
+// kindTitles are the headings of the markdown report, in the order of the sections
+var kindTitles = []struct {
+	kind  string
+	title string
+}{
+	{KindNoTwoStep, "Owners and admins without two-step authentication"},
+	{KindInactive, "Inactive users"},
+	{KindNoGroups, "Users in no groups"},
+}
+
+// Finding is an issue of a user
+type Finding struct {
+	Kind           string    `json:"kind"`
+	UserID         int       `json:"user_id"`
+	Username       string    `json:"username"`
+	Name           string    `json:"name"`
+	Role           string    `json:"role"`
+	LastAccessTime time.Time `json:"last_access_time"`
+	Detail         string    `json:"detail"`
+}
+
+// Report is the result of an audit
+type Report struct {
+	GeneratedAt  time.Time `json:"generated_at"`
+	InactiveDays int       `json:"inactive_days"`
+	Users        int       `json:"users"`
+	Findings     []Finding `json:"findings"`
+}
+
+// Count returns the number of findings of the kind
+func (r *Report) Count(kind string) int {
+	return report.Count(r.Findings, func(f Finding) bool { return f.Kind == kind })
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// WriteCSV writes a row per finding with a header
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "user_id", "username", "name", "role", "last_access_time", "detail"})
+
+	for _, f := range r.Findings {
+		cw.Write([]string{f.Kind, strconv.Itoa(f.UserID), f.Username, f.Name, f.Role, formatTime(f.LastAccessTime), f.Detail})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Markdown returns the report as a markdown document
+func (r *Report) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Audited %d users at %s. Users without access for %d days are inactive.\n\n",
+		r.Users, formatTime(r.GeneratedAt), r.InactiveDays)
+
+	b.WriteString("| Finding | Users |\n|---|---|\n")
+	for _, k := range kindTitles {
+		fmt.Fprintf(&b, "| %s | %d |\n", k.title, r.Count(k.kind))
+	}
+
+	for _, k := range kindTitles {
+		fmt.Fprintf(&b, "\n## %s\n\n", k.title)
+
+		if r.Count(k.kind) == 0 {
+			b.WriteString("None.\n")
+			continue
+		}
+
+		b.WriteString("| User | Name | Role | Last access | Detail |\n|---|---|---|---|---|\n")
+		for _, f := range r.Findings {
+			if f.Kind == k.kind {
+				fmt.Fprintf(&b, "| @%s | %s | %s | %s | %s |\n", f.Username, escapeCell(f.Name), f.Role, formatTime(f.LastAccessTime), escapeCell(f.Detail))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// WriteMarkdown writes the report as a markdown document
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	_, err := io.WriteString(w, r.Markdown())
+	return err
+}
+
+// Publish posts the markdown report. The post is private unless req sets the scope,
+// and is created without notice unless req sets it. Title and Body are filled when empty.
+func (r *Report) Publish(c *docbase.Client, req *docbase.PostCreateRequest) (*docbase.Post, error) {
+	post := *req
+
+	if post.Title == "" {
+		post.Title = fmt.Sprintf("Security audit %s", r.GeneratedAt.Format("2006-01-02"))
+	}
+
+	if post.Body == "" {
+		post.Body = r.Markdown()
+	}
+
+	if post.Scope == "" {
+		post.Scope = docbase.ScopePrivate
+	}
+
+	if post.Notice == nil {
+		post.Notice = docbase.Bool(false)
+	}
+
+	created, _, err := c.Posts.Create(&post)
+	return created, err
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// Auditor audits the users of a team
+type Auditor struct {
+	client *docbase.Client
+	now    func() time.Time
+
+	// InactiveAfter is the time since the last access after which a user is inactive
+	InactiveAfter time.Duration
+}
+
+// New returns an Auditor treating 90 days without access as inactive
+func New(c *docbase.Client) *Auditor {
+	return &Auditor{client: c, now: time.Now, InactiveAfter: defaultInactiveAfter}
+}
+
+// Run pages through all users with their groups and reports the findings
+func (a *Auditor) Run() (*Report, error) {
+	report := &Report{GeneratedAt: a.now(), InactiveDays: int(a.InactiveAfter.Hours() / 24)}
+
+	for page := 1; ; page++ {
+		users, _, err := a.client.Users.List(&docbase.UserListOptions{Page: page, PerPage: userListPerPage, IncludeUserGroups: true})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range *users {
+			report.Users++
+			report.Findings = append(report.Findings, a.check(u, report.GeneratedAt)...)
+		}
+
+		if len(*users) < userListPerPage {
+			break
+		}
+	}
+
+	return report, nil
+}
+
+func (a *Auditor) check(u docbase.User, now time.Time) []Finding {
+	var findings []Finding
+
+	finding := func(kind, detail string) {
+		findings = append(findings, Finding{
+			Kind:           kind,
+			UserID:         u.ID,
+			Username:       u.Username,
+			Name:           u.Name,
+			Role:           u.Role,
+			LastAccessTime: u.LastAccessTime,
+			Detail:         detail,
+		})
+	}
+
+	if (u.Role == "owner" || u.Role == "admin") && !u.TwoStepAuthentication {
+		finding(KindNoTwoStep, "two-step authentication is disabled")
+	}
+
+	if u.LastAccessTime.IsZero() {
+		finding(KindInactive, "never accessed")
+	} else if idle := now.Sub(u.LastAccessTime); idle > a.InactiveAfter {
+		finding(KindInactive, fmt.Sprintf("no access for %d days", int(idle.Hours()/24)))
+	}
+
+	if len(u.Groups) == 0 {
+		finding(KindNoGroups, "belongs to no groups")
+	}
+
+	return findings
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+func newAudit(t *testing.T) (*fakeserver.Team, *Report) {
+	now := time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+
+	s := fakeserver.NewTeam(t, fakeserver.Seed{
+		Users: []docbase.User{
+			{ID: 1, Username: "owner", Name: "Owner", Role: "owner", TwoStepAuthentication: true, LastAccessTime: now},
+			{ID: 2, Username: "admin", Name: "Admin | Ops", Role: "admin", LastAccessTime: now.AddDate(0, 0, -100)},
+			{ID: 3, Username: "user", Name: "User", Role: "user", LastAccessTime: now.AddDate(0, 0, -10)},
+			{ID: 4, Username: "newbie", Name: "Newbie", Role: "user"},
+		},
+		Groups: []fakeserver.Group{{Name: "DocBase", Members: []int{1, 3}}},
+	})
+
+	a := New(s.Client)
+	a.now = func() time.Time { return now }
+
+	report, err := a.Run()
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	return s, report
+}
+
+func TestAuditor_Run(t *testing.T) {
+	_, report := newAudit(t)
+
+	if report.Users != 4 || report.InactiveDays != 90 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	got := make(map[string][]string)
+	for _, f := range report.Findings {
+		got[f.Kind] = append(got[f.Kind], f.Username)
+	}
+
+	want := map[string]string{
+		KindNoTwoStep: "admin",
+		KindInactive:  "admin newbie",
+		KindNoGroups:  "admin newbie",
+	}
+
+	for kind, users := range want {
+		if strings.Join(got[kind], " ") != users {
+			t.Errorf("%s findings are %v, want %v", kind, got[kind], users)
+		}
+	}
+
+	for _, f := range report.Findings {
+		if f.Kind == KindInactive && f.Username == "admin" && f.Detail != "no access for 100 days" {
+			t.Errorf("Detail is %q", f.Detail)
+		}
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	_, report := newAudit(t)
+
+	var js bytes.Buffer
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatalf("WriteJSON returned an error: %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded.Findings) != len(report.Findings) {
+		t.Errorf("WriteJSON wrote %s", js.String())
+	}
+
+	var cs bytes.Buffer
+	if err := report.WriteCSV(&cs); err != nil {
+		t.Fatalf("WriteCSV returned an error: %v", err)
+	}
+
+	rows, err := csv.NewReader(&cs).ReadAll()
+	if err != nil || len(rows) != len(report.Findings)+1 || rows[0][0] != "kind" || rows[1][2] != "admin" {
+		t.Errorf("WriteCSV wrote %v, %v", rows, err)
+	}
+
+	md := report.Markdown()
+	for _, want := range []string{
+		"| Owners and admins without two-step authentication | 1 |",
+		"| @admin | Admin \\| Ops | admin |",
+		"| @newbie | Newbie | user |  | never accessed |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown should contain %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestReport_Publish(t *testing.T) {
+	s, report := newAudit(t)
+
+	post, err := report.Publish(s.Client, &docbase.PostCreateRequest{Tags: []string{"security"}})
+
+	if err != nil {
+		t.Fatalf("Publish returned an error: %v", err)
+	}
+
+	got := s.Post(post.ID)
+	if got.Title != "Security audit 2020-03-27" || got.Scope != docbase.ScopePrivate || got.Body != report.Markdown() || len(got.Tags) != 1 {
+		t.Errorf("Published post is %+v", got)
+	}
+}
@@ -0,0 +1,455 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// Format is the version of the archive format written by this package
+const Format = 1
+
+// Kinds of backups
+const (
+	KindFull        = "full"
+	KindIncremental = "incremental"
+)
+
+// Files of an archive besides the manifest. Records are NDJSON, a JSON value per line,
+// and the attachments are kept as they were downloaded, as "attachments/<ID>/<name>".
+const (
+	manifestFile    = "manifest.json"
+	usersFile       = "users.ndjson"
+	groupsFile      = "groups.ndjson"
+	tagsFile        = "tags.ndjson"
+	postsFile       = "posts.ndjson"
+	attachmentsPath = "attachments/"
+)
+
+var (
+	// ErrFormat is returned for an archive written in a newer or unknown format
+	ErrFormat = errors.New("unsupported archive format")
+	// ErrChecksum is returned when a file does not match its checksum in the manifest
+	ErrChecksum = errors.New("checksum mismatch")
+	// ErrCorrupt is returned when an archive is truncated or has unexpected files
+	ErrCorrupt = errors.New("archive is corrupt")
+	// ErrEncrypted is returned when an encrypted archive is opened without a passphrase
+	ErrEncrypted = errors.New("archive is encrypted")
+	// ErrPassphrase is returned when an archive cannot be decrypted with the passphrase
+	ErrPassphrase = errors.New("wrong passphrase")
+)
+
+// FileSum is the size and SHA-256 checksum of a file in an archive
+type FileSum struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes an archive. It is the first file of the archive.
+type Manifest struct {
+	Format int    `json:"format"`
+	ID     string `json:"id"`
+	Team   string `json:"team"`
+	Kind   string `json:"kind"`
+	// BaseID is the ID of the backup an incremental backup is based on
+	BaseID string `json:"base_id,omitempty"`
+	// Since is the time posts changed after are in an incremental backup
+	Since     *time.Time `json:"since,omitempty"`
+	StartedAt time.Time  `json:"started_at"`
+	Encrypted bool       `json:"encrypted"`
+
+	Users       int `json:"users"`
+	Groups      int `json:"groups"`
+	Tags        int `json:"tags"`
+	Posts       int `json:"posts"`
+	Attachments int `json:"attachments"`
+
+	Files []FileSum `json:"files"`
+}
+
+func (m *Manifest) sum(name string) (FileSum, bool) {
+	for _, f := range m.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return FileSum{}, false
+}
+
+// Archive is an opened backup. Attachments are extracted to a temporary directory
+// removed by Close.
+type Archive struct {
+	Manifest *Manifest
+	Users    []docbase.User
+	// Groups have their members in Users
+	Groups []docbase.Group
+	Tags   []docbase.Tag
+	// Posts have their comments and attachments
+	Posts []*docbase.Post
+
+	dir string
+}
+
+// Close removes the extracted attachments
+func (a *Archive) Close() error {
+	return os.RemoveAll(a.dir)
+}
+
+// attachment returns the path of the extracted attachment, or "" when the archive lacks it
+func (a *Archive) attachment(id string) string {
+	dir := filepath.Join(a.dir, filepath.FromSlash(attachmentsPath), path.Base(id))
+	entries, err := os.ReadDir(dir)
+
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+
+	return filepath.Join(dir, entries[0].Name())
+}
+
+// Open reads the archive, verifying the checksums of all its files.
+// The passphrase is required for encrypted archives and ignored otherwise.
+func Open(name, passphrase string) (*Archive, error) {
+	f, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+
+	if isEncrypted(br) {
+		if passphrase == "" {
+			return nil, ErrEncrypted
+		}
+
+		if r, err = newDecrypter(br, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	gz, err := gzip.NewReader(r)
+
+	if errors.Is(err, ErrPassphrase) || errors.Is(err, ErrCorrupt) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	dir, err := os.MkdirTemp("", "docbase-backup-")
+
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{dir: dir}
+	if err := a.read(tar.NewReader(gz)); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Archive) read(tr *tar.Reader) error {
+	hdr, err := tr.Next()
+
+	if err != nil || hdr.Name != manifestFile {
+		return fmt.Errorf("%w: manifest is missing", ErrCorrupt)
+	}
+
+	a.Manifest = &Manifest{}
+	if err := json.NewDecoder(tr).Decode(a.Manifest); err != nil {
+		return fmt.Errorf("%w: reading manifest: %v", ErrCorrupt, err)
+	}
+
+	if a.Manifest.Format < 1 || a.Manifest.Format > Format {
+		return fmt.Errorf("%w: %d", ErrFormat, a.Manifest.Format)
+	}
+
+	seen := make(map[string]bool)
+
+	for {
+		hdr, err := tr.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+
+		sum, ok := a.Manifest.sum(hdr.Name)
+		if !ok || seen[hdr.Name] {
+			return fmt.Errorf("%w: unexpected file %s", ErrCorrupt, hdr.Name)
+		}
+		seen[hdr.Name] = true
+
+		if err := a.readFile(tr, sum); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range a.Manifest.Files {
+		if !seen[f.Name] {
+			return fmt.Errorf("%w: %s is missing", ErrCorrupt, f.Name)
+		}
+	}
+
+	return nil
+}
+
+func (a *Archive) readFile(r io.Reader, sum FileSum) error {
+	h := sha256.New()
+	r = io.TeeReader(r, h)
+
+	var err error
+	switch {
+	case strings.HasPrefix(sum.Name, attachmentsPath):
+		err = a.extract(r, sum.Name)
+	case sum.Name == usersFile:
+		err = readRecords(r, &a.Users)
+	case sum.Name == groupsFile:
+		err = readRecords(r, &a.Groups)
+	case sum.Name == tagsFile:
+		err = readRecords(r, &a.Tags)
+	case sum.Name == postsFile:
+		err = readRecords(r, &a.Posts)
+	default:
+		_, err = io.Copy(io.Discard, r)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: reading %s: %v", ErrCorrupt, sum.Name, err)
+	}
+
+	if hexSum(h) != sum.SHA256 {
+		return fmt.Errorf("%w: %s", ErrChecksum, sum.Name)
+	}
+
+	return nil
+}
+
+// extract writes the attachment, named "attachments/<ID>/<name>" in the archive
+func (a *Archive) extract(r io.Reader, name string) error {
+	id, file := path.Base(path.Dir(name)), path.Base(name)
+	if id == "." || id == ".." || file == ".." || path.Dir(path.Dir(name))+"/" != attachmentsPath {
+		return fmt.Errorf("invalid attachment name %s", name)
+	}
+
+	dir := filepath.Join(a.dir, filepath.FromSlash(attachmentsPath), id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, file), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// readRecords decodes a JSON value per line into the slice v points to
+func readRecords[T any](r io.Reader, v *[]T) error {
+	dec := json.NewDecoder(r)
+
+	for {
+		var rec T
+		err := dec.Decode(&rec)
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		*v = append(*v, rec)
+	}
+}
+
+// staging collects the files of an archive in a directory with their checksums,
+// so that the manifest can be written first
+type staging struct {
+	dir   string
+	files []FileSum
+}
+
+// add writes a file with fn, recording its checksum
+func (s *staging) add(name string, fn func(w io.Writer) error) error {
+	p := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	cw := &countWriter{w: io.MultiWriter(f, h)}
+
+	if err := fn(cw); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	s.files = append(s.files, FileSum{Name: name, Size: cw.n, SHA256: hexSum(h)})
+	return nil
+}
+
+// addRecords writes the records as NDJSON
+func addRecords[T any](s *staging, name string, records []T) error {
+	return s.add(name, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// write writes the archive to name atomically
+func (s *staging) write(name string, m *Manifest, passphrase string) error {
+	m.Files = s.files
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".backup-*.tmp")
+
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.writeTo(tmp, m, passphrase); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *staging) writeTo(w io.Writer, m *Manifest, passphrase string) error {
+	var enc *encrypter
+	if passphrase != "" {
+		var err error
+		if enc, err = newEncrypter(w, passphrase); err != nil {
+			return err
+		}
+		w = enc
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeEntry(tw, manifestFile, int64(len(manifest)), m.StartedAt, bytes.NewReader(manifest)); err != nil {
+		return err
+	}
+
+	for _, sum := range s.files {
+		f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(sum.Name)))
+
+		if err != nil {
+			return err
+		}
+
+		err = writeEntry(tw, sum.Name, sum.Size, m.StartedAt, f)
+		f.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if enc != nil {
+		return enc.Close()
+	}
+
+	return nil
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,207 @@
+// Package backup writes a team to a portable archive and restores it, to the same or another team.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+)
+
+// listPerPage is the maximum page size of the users and groups lists
+const listPerPage = 100
+
+// Runner backs up a team
+type Runner struct {
+	client *docbase.Client
+	now    func() time.Time
+
+	// Passphrase encrypts the archive when set
+	Passphrase string
+	// Base makes the backup incremental: only posts changed since the base backup started
+	// are kept. Users, groups and tags are always kept in full. Deleted posts are not
+	// tracked, they remain in the restored chain.
+	Base *Manifest
+}
+
+// New returns a Runner of full backups
+func New(c *docbase.Client) *Runner {
+	return &Runner{client: c, now: time.Now}
+}
+
+// Run writes the backup to the archive file name, replacing it only once it is complete
+func (r *Runner) Run(name string) (*Manifest, error) {
+	started := r.now()
+	m := &Manifest{
+		Format:    Format,
+		ID:        started.UTC().Format("20060102T150405.000Z"),
+		Team:      r.client.Team,
+		Kind:      KindFull,
+		StartedAt: started,
+		Encrypted: r.Passphrase != "",
+	}
+
+	if r.Base != nil {
+		since := r.Base.StartedAt
+		m.Kind = KindIncremental
+		m.BaseID = r.Base.ID
+		m.Since = &since
+	}
+
+	dir, err := os.MkdirTemp("", "docbase-backup-")
+
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	s := &staging{dir: dir}
+
+	for _, step := range []func(*staging, *Manifest) error{r.users, r.groups, r.tags, r.posts} {
+		if err := step(s, m); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.write(name, m, r.Passphrase); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *Runner) users(s *staging, m *Manifest) error {
+	var all []docbase.User
+
+	for page := 1; ; page++ {
+		users, _, err := r.client.Users.List(&docbase.UserListOptions{Page: page, PerPage: listPerPage})
+
+		if err != nil {
+			return fmt.Errorf("listing users: %w", err)
+		}
+
+		all = append(all, *users...)
+
+		if len(*users) < listPerPage {
+			break
+		}
+	}
+
+	m.Users = len(all)
+	return addRecords(s, usersFile, all)
+}
+
+func (r *Runner) groups(s *staging, m *Manifest) error {
+	var all []docbase.Group
+
+	for page := 1; ; page++ {
+		groups, _, err := r.client.Groups.List(&docbase.GroupListOptions{Page: page, PerPage: listPerPage})
+
+		if err != nil {
+			return fmt.Errorf("listing groups: %w", err)
+		}
+
+		// the list has no members nor descriptions
+		for _, sg := range *groups {
+			g, _, err := r.client.Groups.Get(sg.ID)
+
+			if err != nil {
+				return fmt.Errorf("getting group %s: %w", sg.Name, err)
+			}
+
+			all = append(all, *g)
+		}
+
+		if len(*groups) < listPerPage {
+			break
+		}
+	}
+
+	m.Groups = len(all)
+	return addRecords(s, groupsFile, all)
+}
+
+func (r *Runner) tags(s *staging, m *Manifest) error {
+	tags, _, err := r.client.Tags.List()
+
+	if err != nil {
+		return fmt.Errorf("listing tags: %w", err)
+	}
+
+	m.Tags = len(*tags)
+	return addRecords(s, tagsFile, *tags)
+}
+
+func (r *Runner) posts(s *staging, m *Manifest) error {
+	q := ""
+	if m.Since != nil {
+		// the search has days in the team's time zone, ahead of UTC, so the day in UTC
+		// never misses a post and the time is compared below
+		q = "changed_at:" + m.Since.UTC().Format("2006-01-02") + "~"
+	}
+
+	var posts []*docbase.Post
+	err := postiter.Each(r.client, q, func(p *docbase.Post) error {
+		if m.Since != nil && p.LastUpdatedAt().Before(*m.Since) {
+			return nil
+		}
+
+		posts = append(posts, p)
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("listing posts: %w", err)
+	}
+
+	for _, p := range posts {
+		for _, a := range p.Attachments {
+			if err := r.attachment(s, a); err != nil {
+				return fmt.Errorf("post %d: %w", p.ID, err)
+			}
+		}
+	}
+
+	for _, f := range s.files {
+		if strings.HasPrefix(f.Name, attachmentsPath) {
+			m.Attachments++
+		}
+	}
+
+	m.Posts = len(posts)
+	return addRecords(s, postsFile, posts)
+}
+
+func (r *Runner) attachment(s *staging, a docbase.Attachment) error {
+	id, name := path.Base(a.ID), path.Base(a.Name)
+	if id == "." || id == "/" || id == ".." {
+		return errors.New("attachment without ID")
+	}
+
+	if name == "." || name == "/" || name == ".." {
+		name = "file"
+	}
+
+	// attachments shared by posts are kept once
+	if _, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(attachmentsPath), id)); err == nil {
+		return nil
+	}
+
+	content, _, err := r.client.Attachments.Download(a.ID)
+
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", a.Name, err)
+	}
+
+	return s.add(attachmentsPath+id+"/"+name, func(w io.Writer) error {
+		_, err := w.Write(*content)
+		return err
+	})
+}
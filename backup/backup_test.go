@@ -0,0 +1,358 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+var (
+	created   = time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+	commented = time.Date(2019, 4, 2, 12, 30, 0, 0, time.UTC)
+	image     = []byte("\x89PNG image")
+)
+
+var (
+	sourceTeam = fakeserver.Seed{Users: []docbase.User{fakeserver.Taro, fakeserver.Hanako, {ID: 3, Username: "jiro", Name: "Jiro"}}}
+	// the target team knows the users by other IDs
+	targetTeam = fakeserver.Seed{Users: []docbase.User{{ID: 11, Username: "taro", Name: "Taro"}, {ID: 12, Username: "hanako", Name: "Hanako"}}}
+)
+
+// newSource seeds a group with a description and posts, the design post with an attachment
+func newSource(t *testing.T) *fakeserver.Team {
+	s := fakeserver.NewTeam(t, sourceTeam)
+
+	g, _, err := s.Client.Groups.Create(&docbase.GroupCreateRequest{Name: "dev", Description: "Developers"})
+
+	if err != nil {
+		t.Fatalf("Groups.Create returned an error: %v", err)
+	}
+	s.Client.GroupUsers.Create(g.ID, &docbase.GroupUserCreateRequest{UserIDs: []int{1, 2, 3}})
+
+	a := s.AddAttachment("diagram.png", image)
+	s.Seed(fakeserver.Post{
+		Key:    "design",
+		Author: 1,
+		Post: docbase.Post{
+			Title:       "Design",
+			Body:        "# Design\n" + a.Markdown,
+			Scope:       docbase.ScopeGroup,
+			Tags:        []docbase.Tag{{Name: "design"}},
+			Groups:      []docbase.SimpleGroup{{Name: "dev"}},
+			Attachments: []docbase.Attachment{a},
+			CreatedAt:   created,
+		},
+		Comments: []fakeserver.Comment{{Author: 2, Comment: docbase.Comment{Body: "LGTM", CreatedAt: commented}}},
+	})
+	s.Seed(fakeserver.Post{Key: "closed", Author: 3, Post: docbase.Post{Title: "Closed", Archived: true, CreatedAt: created.Add(time.Hour)}})
+
+	return s
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	src := newSource(t)
+	dir := t.TempDir()
+
+	full, err := New(src.Client).Run(filepath.Join(dir, "full.tar.gz"))
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if full.Kind != KindFull || full.Users != 3 || full.Groups != 1 || full.Posts != 2 || full.Attachments != 1 {
+		t.Errorf("Manifest is %+v", full)
+	}
+
+	// changes after the full backup
+	time.Sleep(time.Millisecond)
+	if _, _, err := src.Client.Posts.Update(src.PostIDs["design"], &docbase.PostUpdateRequest{Title: docbase.String("Design v2")}); err != nil {
+		t.Fatalf("Posts.Update returned an error: %v", err)
+	}
+	src.AddPost(docbase.Post{Title: "Draft", Draft: true, CreatedAt: created.Add(2 * time.Hour), UpdatedAt: time.Now()}, 2)
+
+	r := New(src.Client)
+	r.Base = full
+	r.Passphrase = "correct horse"
+	incr, err := r.Run(filepath.Join(dir, "incr.tar.gz"))
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if incr.Kind != KindIncremental || incr.BaseID != full.ID || incr.Posts != 2 || incr.Attachments != 1 || !incr.Encrypted {
+		t.Errorf("Manifest is %+v", incr)
+	}
+
+	a1, err := Open(filepath.Join(dir, "full.tar.gz"), "")
+
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer a1.Close()
+
+	a2, err := Open(filepath.Join(dir, "incr.tar.gz"), "correct horse")
+
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer a2.Close()
+
+	if len(a1.Posts) != 2 || len(a1.Posts[0].Comments) != 1 || len(a1.Groups[0].Users) != 3 {
+		t.Errorf("Archive is %+v", a1)
+	}
+
+	target := fakeserver.NewTeam(t, targetTeam)
+	report, err := NewRestorer(target.Client).Run(a1, a2)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v\n%+v", err, report)
+	}
+
+	if len(report.Unmapped) != 1 || report.Unmapped[0] != "@jiro" || report.Count(StatusRestored) != 3 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	if got := target.Members("dev"); len(got) != 2 || got[0] != 11 || got[1] != 12 {
+		t.Errorf("Members of dev are %v", got)
+	}
+
+	posts := make(map[string]*docbase.Post)
+	for _, p := range target.Posts() {
+		posts[p.Title] = p
+	}
+
+	design := posts["Design v2"]
+	if design == nil || design.User.ID != 11 || !design.CreatedAt.Equal(created) || design.Scope != docbase.ScopeGroup {
+		t.Fatalf("restored posts are %+v", posts)
+	}
+
+	if len(design.Groups) != 1 || design.Groups[0].Name != "dev" || len(design.Tags) != 1 {
+		t.Errorf("Design has groups %+v and tags %+v", design.Groups, design.Tags)
+	}
+
+	if len(design.Comments) != 1 || design.Comments[0].SimpleUser.ID != 12 || !design.Comments[0].CreatedAt.Equal(commented) {
+		t.Errorf("Design has comments %+v", design.Comments)
+	}
+
+	if strings.Contains(design.Body, src.URL) || !strings.Contains(design.Body, target.URL+"/uploads/") {
+		t.Errorf("Design body should point to the uploaded attachment: %s", design.Body)
+	}
+
+	if p := posts["Closed"]; p == nil || !p.Archived || p.User.ID != 0 {
+		t.Errorf("Closed is %+v", p)
+	}
+
+	if p := posts["Draft"]; p == nil || !p.Draft || p.User.ID != 12 {
+		t.Errorf("Draft is %+v", p)
+	}
+}
+
+func TestRestorer_DryRun(t *testing.T) {
+	src := newSource(t)
+	name := filepath.Join(t.TempDir(), "full.tar.gz")
+
+	if _, err := New(src.Client).Run(name); err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	a, _ := Open(name, "")
+	defer a.Close()
+
+	target := fakeserver.NewTeam(t, targetTeam)
+	r := NewRestorer(target.Client)
+	r.DryRun = true
+	r.Users = map[string]string{"jiro": "hanako"}
+
+	report, err := r.Run(a)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if len(report.Unmapped) != 0 || report.Count(StatusPlanned) != 2 || !report.Groups[0].Created || report.Groups[0].Members != 3 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	if reqs := target.Requests(); len(reqs) != 0 {
+		t.Errorf("DryRun should not change anything: %v", reqs)
+	}
+
+	var out strings.Builder
+	report.Print(&out)
+
+	if !strings.Contains(out.String(), "created  group dev with 3 members") || !strings.Contains(out.String(), "planned  #") {
+		t.Errorf("Print wrote\n%s", out.String())
+	}
+
+	if _, err := r.Run(); !errors.Is(err, ErrChain) {
+		t.Errorf("Error should be ErrChain but is %v", err)
+	}
+}
+
+// failComments answers requests creating comments with a server error and sends the others
+type failComments struct{}
+
+func (failComments) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/comments") {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":"failed","messages":["failed"]}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestRestorer_Resume(t *testing.T) {
+	src := newSource(t)
+	name := filepath.Join(t.TempDir(), "full.tar.gz")
+
+	if _, err := New(src.Client).Run(name); err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	a, _ := Open(name, "")
+	defer a.Close()
+
+	target := fakeserver.NewTeam(t, targetTeam)
+	failing, err := docbase.NewClientWithOptions("fakeTeam", "fakeToken", docbase.WithBaseURL(target.URL),
+		docbase.WithHTTPClient(&http.Client{Transport: failComments{}}))
+
+	if err != nil {
+		t.Fatalf("NewClientWithOptions returned an error: %v", err)
+	}
+
+	ids := filepath.Join(t.TempDir(), "ids.json")
+	r := NewRestorer(failing)
+	r.IDMap = ids
+
+	report, err := r.Run(a)
+
+	if err == nil || report.Count(StatusFailed) != 1 || report.Count(StatusRestored) != 1 {
+		t.Fatalf("Run returned %+v, %v", report, err)
+	}
+
+	r = NewRestorer(target.Client)
+	r.IDMap = ids
+
+	report, err = r.Run(a)
+
+	if err != nil || report.Count(StatusRestored) != 1 || report.Count(StatusSkipped) != 1 {
+		t.Fatalf("Run returned %+v, %v", report, err)
+	}
+
+	if posts := target.Posts(); len(posts) != 2 {
+		t.Fatalf("posts should not be duplicated: %+v", posts)
+	}
+
+	for _, p := range target.Posts() {
+		if p.Title == "Design" && len(p.Comments) != 1 {
+			t.Errorf("Design comments are %+v", p.Comments)
+		}
+	}
+
+	report, err = r.Run(a)
+
+	if err != nil || report.Count(StatusSkipped) != 2 || len(target.Posts()) != 2 {
+		t.Errorf("Run returned %+v, %v", report, err)
+	}
+}
+
+func TestRestorer_BrokenChain(t *testing.T) {
+	src := newSource(t)
+	dir := t.TempDir()
+
+	full, _ := New(src.Client).Run(filepath.Join(dir, "full.tar.gz"))
+	r := New(src.Client)
+	r.Base = full
+	r.Run(filepath.Join(dir, "incr.tar.gz"))
+
+	a, _ := Open(filepath.Join(dir, "incr.tar.gz"), "")
+	defer a.Close()
+
+	if _, err := NewRestorer(src.Client).Run(a); !errors.Is(err, ErrChain) {
+		t.Errorf("Error should be ErrChain but is %v", err)
+	}
+}
+
+func TestOpen_Encrypted(t *testing.T) {
+	src := newSource(t)
+	name := filepath.Join(t.TempDir(), "full.tar.gz.enc")
+
+	r := New(src.Client)
+	r.Passphrase = "correct horse"
+	if _, err := r.Run(name); err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if _, err := Open(name, ""); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Error should be ErrEncrypted but is %v", err)
+	}
+
+	if _, err := Open(name, "battery staple"); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("Error should be ErrPassphrase but is %v", err)
+	}
+
+	b, _ := os.ReadFile(name)
+	os.WriteFile(name, b[:len(b)-10], 0o600)
+
+	if _, err := Open(name, "correct horse"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Error should be ErrCorrupt but is %v", err)
+	}
+}
+
+func TestOpen_Checksum(t *testing.T) {
+	s := &staging{dir: t.TempDir()}
+	addRecords(s, tagsFile, []docbase.Tag{{Name: "design"}})
+	s.files[0].SHA256 = strings.Repeat("0", 64)
+
+	name := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := s.write(name, &Manifest{Format: Format, Kind: KindFull}, ""); err != nil {
+		t.Fatalf("write returned an error: %v", err)
+	}
+
+	if _, err := Open(name, ""); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Error should be ErrChecksum but is %v", err)
+	}
+
+	if err := s.write(name, &Manifest{Format: Format + 1}, ""); err != nil {
+		t.Fatalf("write returned an error: %v", err)
+	}
+
+	if _, err := Open(name, ""); !errors.Is(err, ErrFormat) {
+		t.Errorf("Error should be ErrFormat but is %v", err)
+	}
+}
+
+func TestEncrypter(t *testing.T) {
+	plain := bytes.Repeat([]byte("0123456789abcdef"), chunkSize/8)
+
+	for _, size := range []int{0, 1, chunkSize, chunkSize + 1, len(plain)} {
+		var b bytes.Buffer
+		enc, _ := newEncrypter(&b, "secret")
+		enc.Write(plain[:size])
+		enc.Close()
+
+		dec, err := newDecrypter(&b, "secret")
+
+		if err != nil {
+			t.Fatalf("newDecrypter returned an error: %v", err)
+		}
+
+		var got bytes.Buffer
+		if _, err := got.ReadFrom(dec); err != nil || !bytes.Equal(got.Bytes(), plain[:size]) {
+			t.Errorf("size %d: decrypted %d bytes, %v", size, got.Len(), err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted archive starts with the magic and the salt of the key, followed by
+// chunks of the gzipped tar sealed with AES-256-GCM. Each chunk is prefixed by its
+// sealed length and its nonce is its index, which is unique as the salt is random
+// for each archive. The last chunk is marked in its additional data, so a truncated
+// archive is detected.
+const (
+	magic     = "DOCBASE-BACKUP-ENC1\n"
+	saltSize  = 16
+	chunkSize = 64 << 10
+)
+
+// scrypt parameters recommended for interactive use in 2017
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func nonce(aead cipher.AEAD, index uint64) []byte {
+	n := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(n[len(n)-8:], index)
+	return n
+}
+
+func chunkData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+
+	return []byte{0}
+}
+
+// encrypter seals what is written to it in chunks, the last one on Close
+type encrypter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+}
+
+func newEncrypter(w io.Writer, passphrase string) (*encrypter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, magic); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+
+	return &encrypter{w: w, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encrypter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is kept until more is written, as it may be the last one
+		if len(e.buf) == chunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+
+		m := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+m]
+		p = p[m:]
+		n += m
+	}
+
+	return n, nil
+}
+
+func (e *encrypter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, nonce(e.aead, e.index), e.buf, chunkData(last))
+	e.index++
+	e.buf = e.buf[:0]
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+
+	if _, err := e.w.Write(size[:]); err != nil {
+		return err
+	}
+
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// Close seals the last chunk, it does not close the underlying writer
+func (e *encrypter) Close() error {
+	return e.seal(true)
+}
+
+// decrypter opens the chunks of an encrypted archive
+type decrypter struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+	done  bool
+}
+
+func newDecrypter(r io.Reader, passphrase string) (*decrypter, error) {
+	header := make([]byte, len(magic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	aead, err := newAEAD(passphrase, header[len(magic):])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &decrypter{r: r, aead: aead}, nil
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decrypter) open() error {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		return fmt.Errorf("%w: encrypted archive is truncated", ErrCorrupt)
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n < uint32(d.aead.Overhead()) || n > chunkSize+uint32(d.aead.Overhead()) {
+		return fmt.Errorf("%w: invalid chunk size %d", ErrCorrupt, n)
+	}
+
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return fmt.Errorf("%w: encrypted archive is truncated", ErrCorrupt)
+	}
+
+	nc := nonce(d.aead, d.index)
+	plain, err := d.aead.Open(nil, nc, sealed, chunkData(false))
+
+	if err != nil {
+		plain, err = d.aead.Open(nil, nc, sealed, chunkData(true))
+		if err != nil {
+			if d.index == 0 {
+				return ErrPassphrase
+			}
+
+			return fmt.Errorf("%w: chunk %d does not authenticate", ErrCorrupt, d.index)
+		}
+
+		d.done = true
+	}
+
+	d.index++
+	d.buf = plain
+
+	return nil
+}
+
+// isEncrypted reports whether the archive read by r starts with the magic
+func isEncrypted(r *bufio.Reader) bool {
+	head, _ := r.Peek(len(magic))
+	return bytes.Equal(head, []byte(magic))
+}
@@ -0,0 +1,361 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/importer"
+	"github.com/hayashiki/docbase-go/internal/report"
+	"github.com/hayashiki/docbase-go/internal/repost"
+)
+
+// Statuses of restored posts
+const (
+	StatusRestored = "restored"
+	StatusPlanned  = "planned" // in a dry run
+	StatusSkipped  = "skipped" // restored by a previous run
+	StatusFailed   = "failed"
+)
+
+// ErrChain is returned when the archives are not a full backup followed by its incremental backups
+var ErrChain = errors.New("archives are not a backup chain")
+
+// GroupResult is the restoring of a group
+type GroupResult struct {
+	Name    string `json:"name"`
+	Created bool   `json:"created"`
+	Members int    `json:"members"`
+}
+
+// PostResult is the restoring of a post
+type PostResult struct {
+	SourceID int    `json:"source_id"`
+	ID       int    `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RestoreReport is the result of a restore
+type RestoreReport struct {
+	DryRun bool          `json:"dry_run"`
+	Groups []GroupResult `json:"groups"`
+	Posts  []PostResult  `json:"posts"`
+	// Unmapped lists the users of the backup missing in the team. Their posts and
+	// comments are restored as the owner of the token and they are not added to groups.
+	Unmapped []string `json:"unmapped"`
+}
+
+// Count returns the number of posts of the status
+func (r *RestoreReport) Count(status string) int {
+	return report.Count(r.Posts, func(p PostResult) bool { return p.Status == status })
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *RestoreReport) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// Print writes a line per group and post
+func (r *RestoreReport) Print(w io.Writer) error {
+	var b strings.Builder
+	report.Heading(&b, "Restore", r.DryRun)
+
+	for _, u := range r.Unmapped {
+		fmt.Fprintf(&b, "  unmapped user %s\n", u)
+	}
+
+	for _, g := range r.Groups {
+		action := "existing"
+		if g.Created {
+			action = "created"
+		}
+		fmt.Fprintf(&b, "  %-8s group %s with %d members\n", action, g.Name, g.Members)
+	}
+
+	for _, p := range r.Posts {
+		fmt.Fprintf(&b, "  %-8s #%d %s", p.Status, p.SourceID, p.Title)
+		if p.ID != 0 {
+			fmt.Fprintf(&b, " as #%d", p.ID)
+		}
+		fmt.Fprintf(&b, "%s\n", report.ErrorSuffix(p.Error))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Restorer restores backups to a team
+type Restorer struct {
+	client *docbase.Client
+
+	// DryRun reports what would be restored without changing the team
+	DryRun bool
+	// Users maps usernames of the backup to usernames of the team, for users who
+	// are named differently there. Other users are matched by their username.
+	Users map[string]string
+	// IDMap is the checkpoint file mapping the IDs of backed up posts to the restored
+	// posts and the number of their comments created. A restore which stopped resumes
+	// with the same IDMap instead of creating the posts again.
+	IDMap string
+}
+
+// NewRestorer returns a Restorer to the team of the client
+func NewRestorer(c *docbase.Client) *Restorer {
+	return &Restorer{client: c}
+}
+
+// sourcePost is a post with the archive holding its attachments
+type sourcePost struct {
+	*docbase.Post
+	archive *Archive
+}
+
+// Run restores a full backup followed by its incremental backups, in order.
+// Missing groups are created and members added to them. Posts are re-created with
+// new IDs, their original authors and PublishedAt timestamps, and their comments.
+// Posting on behalf of the authors takes a token of an owner or admin of the team.
+// A post which cannot be restored is reported with its error, and the restore carries
+// on with the rest of the archives. Without an IDMap, running again duplicates the
+// posts restored before.
+func (r *Restorer) Run(archives ...*Archive) (*RestoreReport, error) {
+	if err := checkChain(archives); err != nil {
+		return nil, err
+	}
+
+	ids, err := importer.LoadIDMap(r.IDMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	last := archives[len(archives)-1]
+	report := &RestoreReport{DryRun: r.DryRun, Unmapped: []string{}}
+
+	users := r.mapUsers(last.Users, report)
+
+	if err := r.restoreGroups(last.Groups, users, report); err != nil {
+		return report, err
+	}
+
+	for _, p := range mergePosts(archives) {
+		report.Posts = append(report.Posts, r.restorePost(p, users, ids))
+	}
+
+	if n := report.Count(StatusFailed); n > 0 {
+		return report, fmt.Errorf("%d posts failed", n)
+	}
+
+	return report, nil
+}
+
+func checkChain(archives []*Archive) error {
+	if len(archives) == 0 {
+		return fmt.Errorf("%w: no archives", ErrChain)
+	}
+
+	if archives[0].Manifest.Kind != KindFull {
+		return fmt.Errorf("%w: %s is not a full backup", ErrChain, archives[0].Manifest.ID)
+	}
+
+	for i := 1; i < len(archives); i++ {
+		m := archives[i].Manifest
+		if m.Kind != KindIncremental || m.BaseID != archives[i-1].Manifest.ID {
+			return fmt.Errorf("%w: %s does not follow %s", ErrChain, m.ID, archives[i-1].Manifest.ID)
+		}
+	}
+
+	return nil
+}
+
+// mergePosts returns the last backed up version of each post, oldest first
+func mergePosts(archives []*Archive) []sourcePost {
+	byID := make(map[int]sourcePost)
+	for _, a := range archives {
+		for _, p := range a.Posts {
+			byID[p.ID] = sourcePost{Post: p, archive: a}
+		}
+	}
+
+	posts := make([]sourcePost, 0, len(byID))
+	for _, p := range byID {
+		posts = append(posts, p)
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.Before(posts[j].CreatedAt)
+		}
+
+		return posts[i].ID < posts[j].ID
+	})
+
+	return posts
+}
+
+// mapUsers maps the IDs of the users of the backup to the IDs of the team's users
+func (r *Restorer) mapUsers(source []docbase.User, report *RestoreReport) map[int]int {
+	ids := make(map[int]int)
+
+	for _, u := range source {
+		username := u.Username
+		if mapped, ok := r.Users[username]; ok {
+			username = mapped
+		}
+
+		target, err := r.client.UserDirectory.ByUsername(username)
+
+		if err != nil {
+			report.Unmapped = append(report.Unmapped, "@"+u.Username)
+			continue
+		}
+
+		ids[u.ID] = target.ID
+	}
+
+	sort.Strings(report.Unmapped)
+	return ids
+}
+
+func (r *Restorer) restoreGroups(groups []docbase.Group, users map[int]int, report *RestoreReport) error {
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	created := false
+
+	for _, g := range groups {
+		result := GroupResult{Name: g.Name}
+
+		var members []int
+		for _, u := range g.Users {
+			if id, ok := users[u.ID]; ok {
+				members = append(members, id)
+			}
+		}
+		result.Members = len(members)
+
+		id, err := r.client.GroupDirectory.ID(g.Name)
+
+		if errors.Is(err, docbase.ErrGroupNotFound) {
+			result.Created = true
+
+			if !r.DryRun {
+				group, _, err := r.client.Groups.Create(&docbase.GroupCreateRequest{Name: g.Name, Description: g.Description})
+
+				if err != nil {
+					return fmt.Errorf("creating group %s: %w", g.Name, err)
+				}
+
+				id = group.ID
+				created = true
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if !r.DryRun && len(members) > 0 {
+			if _, err := r.client.GroupUsers.Create(id, &docbase.GroupUserCreateRequest{UserIDs: members}); err != nil {
+				return fmt.Errorf("adding members to group %s: %w", g.Name, err)
+			}
+		}
+
+		report.Groups = append(report.Groups, result)
+	}
+
+	if created {
+		return r.client.GroupDirectory.Refresh()
+	}
+
+	return nil
+}
+
+func (r *Restorer) restorePost(p sourcePost, users map[int]int, ids *importer.IDMap) PostResult {
+	result := PostResult{SourceID: p.ID, Title: p.Title, Status: StatusRestored}
+	key := strconv.Itoa(p.ID)
+
+	if restored, ok := ids.Get(key); ok && restored.Comments >= len(p.Comments) {
+		result.ID, result.Status = restored.ID, StatusSkipped
+
+		// the checkpoint does not tell whether archiving failed, and archiving again is harmless
+		if !r.DryRun && p.Archived {
+			if _, err := r.client.Posts.Archive(restored.ID); err != nil {
+				result.Status, result.Error = StatusFailed, fmt.Sprintf("archiving: %v", err)
+			}
+		}
+
+		return result
+	}
+
+	if r.DryRun {
+		result.Status = StatusPlanned
+		return result
+	}
+
+	err := r.createPost(p, users, ids, key)
+
+	if restored, ok := ids.Get(key); ok {
+		result.ID = restored.ID
+	}
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// createPost creates the post unless a previous run did, and the comments not created yet
+func (r *Restorer) createPost(p sourcePost, users map[int]int, ids *importer.IDMap, key string) error {
+	cp := &repost.Copy{
+		Post: p.Post,
+		Author: func(userID int) string {
+			if id, ok := users[userID]; ok {
+				return strconv.Itoa(id)
+			}
+
+			return ""
+		},
+	}
+
+	if _, ok := ids.Get(key); !ok {
+		for _, a := range p.Attachments {
+			path := p.archive.attachment(a.ID)
+			if path == "" {
+				return fmt.Errorf("attachment %s is not in the backup", a.Name)
+			}
+
+			cp.Files = append(cp.Files, path)
+		}
+
+		restored, err := repost.CreatePost(r.client, cp)
+
+		if err != nil {
+			return err
+		}
+
+		if err := ids.Set(key, importer.Imported{ID: restored.ID, URL: restored.URL}); err != nil {
+			return err
+		}
+	}
+
+	err := importer.CreateComments(r.client, ids, key, len(p.Comments), func(n int) (*docbase.CommentCreateRequest, error) {
+		return repost.CommentRequest(cp, n), nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if p.Archived {
+		restored, _ := ids.Get(key)
+		if _, err := r.client.Posts.Archive(restored.ID); err != nil {
+			return fmt.Errorf("archiving: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+// Package bulktag adds, removes, renames and merges tags over the posts matching a search.
+package bulktag
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+	"github.com/hayashiki/docbase-go/internal/report"
+)
+
+const defaultConcurrency = 4
+
+// Statuses of results
+const (
+	StatusUpdated   = "updated"   // the tags were changed
+	StatusUnchanged = "unchanged" // the operation changes nothing on the post
+	StatusPlanned   = "planned"   // the tags would be changed in a dry run
+	StatusSkipped   = "skipped"   // done in an earlier run of the journal
+	StatusFailed    = "failed"
+)
+
+// Result is the outcome for a post
+type Result struct {
+	Operation string   `json:"operation"`
+	PostID    int      `json:"post_id"`
+	Title     string   `json:"title"`
+	Before    []string `json:"before"`
+	After     []string `json:"after"`
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// done reports whether a later run may skip the post
+func (r Result) done() bool {
+	return r.Status == StatusUpdated || r.Status == StatusUnchanged
+}
+
+// Report is the results of a run ordered by post ID
+type Report struct {
+	Operation string   `json:"operation"`
+	DryRun    bool     `json:"dry_run"`
+	Results   []Result `json:"results"`
+}
+
+// Count returns the number of results of the status
+func (r *Report) Count(status string) int {
+	return report.Count(r.Results, func(res Result) bool { return res.Status == status })
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// Print writes a line per post
+func (r *Report) Print(w io.Writer) error {
+	var b strings.Builder
+	report.Heading(&b, r.Operation, r.DryRun)
+
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "  %-9s #%d %s: [%s] -> [%s]%s\n", res.Status, res.PostID, res.Title,
+			strings.Join(res.Before, ", "), strings.Join(res.After, ", "), report.ErrorSuffix(res.Error))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Runner runs operations. Requests go through the client, so its limiter
+// and retry policy keep the concurrent updates within the rate limit.
+type Runner struct {
+	client *docbase.Client
+
+	// Concurrency is the number of posts updated at once
+	Concurrency int
+	// DryRun reports the changes without making them
+	DryRun bool
+	// Journal is a file the results are appended to as they complete.
+	// Posts done in an earlier run with the same operation are skipped, so a failed run can be resumed.
+	Journal string
+}
+
+// New returns a Runner updating 4 posts at once
+func New(c *docbase.Client) *Runner {
+	return &Runner{client: c, Concurrency: defaultConcurrency}
+}
+
+// Run applies op to the posts matching q. When q is empty, the posts having any
+// of the source tags are searched. Only the tags are sent, so other fields are kept,
+// and a post changed since it was listed gets op applied to its current tags.
+// A post whose update fails is reported as failed while the others are updated, and a
+// run resumed from the journal tries it again.
+func (r *Runner) Run(op Operation, q string) (*Report, error) {
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+
+	queries := []string{q}
+	if q == "" {
+		queries = op.Queries()
+	}
+
+	if len(queries) == 0 {
+		return nil, errors.New("query is required to add a tag")
+	}
+
+	posts, err := r.search(queries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := r.loadJournal(op)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *journalWriter
+	if r.Journal != "" && !r.DryRun {
+		journal, err = openJournal(r.Journal)
+
+		if err != nil {
+			return nil, err
+		}
+		defer journal.Close()
+	}
+
+	report := &Report{Operation: op.String(), DryRun: r.DryRun, Results: make([]Result, len(posts))}
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				res := r.apply(op, posts[i], done)
+				report.Results[i] = res
+
+				if journal != nil && res.Status != StatusSkipped {
+					journal.Write(res)
+				}
+			}
+		}()
+	}
+
+	for i := range posts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if journal != nil && journal.err != nil {
+		return report, fmt.Errorf("writing journal: %w", journal.err)
+	}
+
+	if n := report.Count(StatusFailed); n > 0 {
+		return report, fmt.Errorf("%d posts failed", n)
+	}
+
+	return report, nil
+}
+
+// search collects the posts of all queries, ordered by ID
+func (r *Runner) search(queries []string) ([]*docbase.Post, error) {
+	byID := make(map[int]*docbase.Post)
+
+	for _, q := range queries {
+		posts, err := postiter.All(r.client, q)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range posts {
+			byID[p.ID] = p
+		}
+	}
+
+	posts := make([]*docbase.Post, 0, len(byID))
+	for _, p := range byID {
+		posts = append(posts, p)
+	}
+
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts, nil
+}
+
+func (r *Runner) apply(op Operation, p *docbase.Post, done map[int]bool) Result {
+	before := p.TagNames()
+	after, changed := op.Apply(before)
+	res := Result{Operation: op.String(), PostID: p.ID, Title: p.Title, Before: before, After: after}
+
+	switch {
+	case done[p.ID]:
+		res.Status = StatusSkipped
+		return res
+	case !changed:
+		res.Status = StatusUnchanged
+		return res
+	case r.DryRun:
+		res.Status = StatusPlanned
+		return res
+	}
+
+	merge := func(current *docbase.Post, _ *docbase.PostUpdateRequest) (*docbase.PostUpdateRequest, error) {
+		res.Before = current.TagNames()
+		res.After, _ = op.Apply(res.Before)
+
+		return &docbase.PostUpdateRequest{Tags: res.After}, nil
+	}
+
+	if _, _, err := r.client.Posts.UpdateIfUnchanged(p.ID, p.Version(), &docbase.PostUpdateRequest{Tags: after}, merge); err != nil {
+		res.Status = StatusFailed
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Status = StatusUpdated
+	return res
+}
+
+// loadJournal returns the posts done by op in earlier runs
+func (r *Runner) loadJournal(op Operation) (map[int]bool, error) {
+	done := make(map[int]bool)
+
+	if r.Journal == "" {
+		return done, nil
+	}
+
+	f, err := os.Open(r.Journal)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var res Result
+
+		// a line cut by a crash is ignored, and the post is tried again
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			continue
+		}
+
+		if res.Operation == op.String() && res.done() {
+			done[res.PostID] = true
+		}
+	}
+
+	return done, scanner.Err()
+}
+
+// journalWriter appends results as JSON lines
+type journalWriter struct {
+	mu  sync.Mutex
+	f   *os.File
+	err error
+}
+
+func openJournal(path string) (*journalWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// end a line cut by a crash, so the next result starts on its own line
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+
+	return &journalWriter{f: f}, nil
+}
+
+func (j *journalWriter) Write(res Result) {
+	b, err := json.Marshal(res)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err == nil {
+		_, err = j.f.Write(append(b, '\n'))
+	}
+
+	if err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+func (j *journalWriter) Close() error {
+	return j.f.Close()
+}
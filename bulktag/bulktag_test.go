@@ -0,0 +1,167 @@
+package bulktag
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+func TestOperation_Apply(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		op          Operation
+		tags        []string
+		want        []string
+		wantChanged bool
+	}{
+		{"Add", AddTag("go"), []string{"infra"}, []string{"infra", "go"}, true},
+		{"AddExisting", AddTag("go"), []string{"Go", "infra"}, []string{"Go", "infra"}, false},
+		{"Remove", RemoveTag("go"), []string{"infra", "GO", "aws"}, []string{"infra", "aws"}, true},
+		{"RemoveMissing", RemoveTag("go"), []string{"infra"}, []string{"infra"}, false},
+		{"Rename", RenameTag("golang", "go"), []string{"infra", "golang", "aws"}, []string{"infra", "go", "aws"}, true},
+		{"RenameCase", RenameTag("go", "Go"), []string{"go"}, []string{"Go"}, true},
+		{"RenameToExisting", RenameTag("golang", "go"), []string{"go", "golang"}, []string{"go"}, true},
+		{"Merge", MergeTags("go", "golang", "Go言語"), []string{"go言語", "infra", "golang"}, []string{"go", "infra"}, true},
+	}
+	for _, tc := range testCases {
+		got, changed := tc.op.Apply(tc.tags)
+
+		if !reflect.DeepEqual(got, tc.want) || changed != tc.wantChanged {
+			t.Errorf("%s: Apply returned %v, %v, want %v, %v", tc.desc, got, changed, tc.want, tc.wantChanged)
+		}
+	}
+
+	if err := RenameTag("", "go").Validate(); err == nil {
+		t.Errorf("Validate should require source tags")
+	}
+
+	if err := AddTag(" ").Validate(); err == nil {
+		t.Errorf("Validate should require the tag")
+	}
+}
+
+func newPosts(t *testing.T) (*fakeserver.Team, []int) {
+	s := fakeserver.NewTeam(t, fakeserver.Seed{Users: []docbase.User{fakeserver.Taro}})
+
+	var ids []int
+	for _, tags := range [][]string{{"golang", "infra"}, {"Go言語"}, {"golang", "go"}, {"aws"}} {
+		p := docbase.Post{Title: "Post", Body: "body", Scope: docbase.ScopePrivate}
+		for _, name := range tags {
+			p.Tags = append(p.Tags, docbase.Tag{Name: name})
+		}
+
+		ids = append(ids, s.AddPost(p, 1))
+	}
+
+	return s, ids
+}
+
+func TestRunner_Run(t *testing.T) {
+	s, ids := newPosts(t)
+	r := New(s.Client)
+
+	report, err := r.Run(MergeTags("go", "golang", "Go言語"), "")
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if len(report.Results) != 3 || report.Count(StatusUpdated) != 3 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	want := [][]string{{"go", "infra"}, {"go"}, {"go"}, {"aws"}}
+	for i, id := range ids {
+		p := s.Post(id)
+
+		if got := p.TagNames(); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Tags of post %d are %v, want %v", i, got, want[i])
+		}
+
+		// other fields are kept
+		if p.Title != "Post" || p.Body != "body" || p.Scope != docbase.ScopePrivate {
+			t.Errorf("Post %d was clobbered: %+v", i, p)
+		}
+	}
+
+	if _, err := r.Run(AddTag("go"), ""); err == nil {
+		t.Errorf("Adding a tag should require a query")
+	}
+}
+
+func TestRunner_DryRun(t *testing.T) {
+	s, _ := newPosts(t)
+	r := New(s.Client)
+	r.DryRun = true
+	r.Journal = filepath.Join(t.TempDir(), "journal.jsonl")
+
+	report, err := r.Run(AddTag("reviewed"), "body")
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if report.Count(StatusPlanned) != 4 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("DryRun should not change anything: %v", reqs)
+	}
+
+	if _, err := os.Stat(r.Journal); !os.IsNotExist(err) {
+		t.Errorf("DryRun should not write the journal: %v", err)
+	}
+
+	var out strings.Builder
+	report.Print(&out)
+
+	if !strings.Contains(out.String(), "[golang, infra] -> [golang, infra, reviewed]") {
+		t.Errorf("Print wrote\n%s", out.String())
+	}
+}
+
+func TestRunner_Resume(t *testing.T) {
+	s, ids := newPosts(t)
+	r := New(s.Client)
+	r.Journal = filepath.Join(t.TempDir(), "journal.jsonl")
+
+	op := RenameTag("golang", "go")
+
+	// an earlier run updated the first post, then crashed while writing
+	done, _ := json.Marshal(Result{Operation: op.String(), PostID: ids[0], Status: StatusUpdated})
+	failed, _ := json.Marshal(Result{Operation: op.String(), PostID: ids[2], Status: StatusFailed})
+	os.WriteFile(r.Journal, []byte(string(done)+"\n"+string(failed)+"\n{\"operation"), 0o644)
+
+	report, err := r.Run(op, "")
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if report.Count(StatusSkipped) != 1 || report.Count(StatusUpdated) != 1 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	if got := s.Post(ids[0]).TagNames(); got[0] != "golang" {
+		t.Errorf("Done post should be skipped: %v", got)
+	}
+
+	if got := s.Post(ids[2]).TagNames(); !reflect.DeepEqual(got, []string{"go"}) {
+		t.Errorf("Failed post should be retried: %v", got)
+	}
+
+	b, _ := os.ReadFile(r.Journal)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+
+	var last Result
+	if len(lines) != 4 || json.Unmarshal([]byte(lines[3]), &last) != nil || last.PostID != ids[2] {
+		t.Errorf("Journal should have the result on a new line:\n%s", b)
+	}
+}
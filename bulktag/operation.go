@@ -0,0 +1,146 @@
+package bulktag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operation changes the tags of a post. Tags are matched case-insensitively as DocBase does.
+type Operation struct {
+	kind    string
+	sources []string
+	target  string
+}
+
+// AddTag adds the tag to the posts which lack it
+func AddTag(name string) Operation {
+	return Operation{kind: "add", target: name}
+}
+
+// RemoveTag removes the tag
+func RemoveTag(name string) Operation {
+	return Operation{kind: "remove", sources: []string{name}}
+}
+
+// RenameTag replaces the tag from with to
+func RenameTag(from, to string) Operation {
+	return Operation{kind: "rename", sources: []string{from}, target: to}
+}
+
+// MergeTags replaces all tags of from with into
+func MergeTags(into string, from ...string) Operation {
+	return Operation{kind: "merge", sources: from, target: into}
+}
+
+func (o Operation) String() string {
+	switch o.kind {
+	case "add":
+		return "add " + o.target
+	case "remove":
+		return "remove " + o.sources[0]
+	}
+
+	return fmt.Sprintf("%s %s -> %s", o.kind, strings.Join(o.sources, ", "), o.target)
+}
+
+// Validate checks that the tag names are given
+func (o Operation) Validate() error {
+	if o.kind == "" {
+		return fmt.Errorf("operation is not set")
+	}
+
+	if o.kind != "remove" && strings.TrimSpace(o.target) == "" {
+		return fmt.Errorf("%s: tag is required", o.kind)
+	}
+
+	if o.kind != "add" && len(o.sources) == 0 {
+		return fmt.Errorf("%s: source tags are required", o.kind)
+	}
+
+	for _, s := range o.sources {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("%s: source tags must not be empty", o.kind)
+		}
+	}
+
+	return nil
+}
+
+// Queries returns the searches of the posts having the source tags, one per tag.
+// Adding a tag has no default query.
+func (o Operation) Queries() []string {
+	var qs []string
+	for _, s := range o.sources {
+		qs = append(qs, "tag:"+s)
+	}
+
+	return qs
+}
+
+// Apply returns the tags after the operation, keeping the order of the others,
+// and whether they changed
+func (o Operation) Apply(tags []string) ([]string, bool) {
+	out := make([]string, 0, len(tags)+1)
+	replaced := false
+
+	for _, t := range tags {
+		if !o.isSource(t) {
+			out = append(out, t)
+			continue
+		}
+
+		// the target takes the place of the first source
+		if o.target != "" && !replaced {
+			out = append(out, o.target)
+			replaced = true
+		}
+	}
+
+	if o.kind == "add" {
+		out = append(out, o.target)
+	}
+
+	out = dedup(out)
+
+	return out, !equal(tags, out)
+}
+
+func (o Operation) isSource(tag string) bool {
+	for _, s := range o.sources {
+		if strings.EqualFold(tag, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dedup drops later tags which equal earlier ones case-insensitively
+func dedup(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := tags[:0]
+
+	for _, t := range tags {
+		key := strings.ToLower(t)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
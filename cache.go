@@ -0,0 +1,392 @@
+package docbase
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStore stores GET responses for the response cache.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse)
+	Delete(key string)
+}
+
+// CachedResponse represents a stored GET response
+type CachedResponse struct {
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// httpCache serves GET responses from store
+type httpCache struct {
+	store CacheStore
+	ttl   time.Duration
+
+	mu sync.Mutex
+	// invalidated is when a path was last written to by a request other than GET.
+	// Responses of the path stored before are stale, whatever their query. A path
+	// ending with "/" invalidates the paths below it.
+	invalidated map[string]time.Time
+	// floor replaces the pruned entries of invalidated: responses stored before are stale
+	floor time.Time
+}
+
+// maxInvalidated bounds the paths remembered as written to
+const maxInvalidated = 1024
+
+// WithCache caches GET responses in store.
+// A response younger than ttl is served without a request. Older responses are
+// revalidated with If-None-Match / If-Modified-Since when the server sent an ETag or
+// Last-Modified header, and fetched again otherwise. While the rate limit is
+// exhausted, cached responses are served regardless of their age.
+// Requests with a Cache-Control: no-cache header are always sent, see GetUncachedJSON.
+// Any other request evicts the responses of its resource and of the resources it
+// belongs to, e.g. PUT /posts/1/archive evicts /posts/1 and /posts?q=..., and
+// DELETE /comments/1 evicts all posts as the post of the comment is unknown.
+func WithCache(store CacheStore, ttl time.Duration) Option {
+	return func(o *clientOptions) error {
+		if store == nil {
+			return errors.New("cache store must not be nil")
+		}
+
+		if ttl < 0 {
+			return fmt.Errorf("cache ttl must not be negative: %v", ttl)
+		}
+
+		o.cache = &httpCache{store: store, ttl: ttl}
+		return nil
+	}
+}
+
+func (h *httpCache) fresh(res *CachedResponse) bool {
+	return h.ttl > 0 && time.Since(res.StoredAt) < h.ttl
+}
+
+func (res *CachedResponse) setValidators(r *http.Request) {
+	if etag := res.Header.Get("ETag"); etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+
+	if lastModified := res.Header.Get("Last-Modified"); lastModified != "" {
+		r.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// revalidated returns a copy of res refreshed by a 304 Not Modified response
+func (res *CachedResponse) revalidated(header http.Header) *CachedResponse {
+	h := res.Header.Clone()
+	for _, k := range []string{"ETag", "Last-Modified", "Cache-Control", "Expires"} {
+		if v := header.Get(k); v != "" {
+			h.Set(k, v)
+		}
+	}
+
+	return &CachedResponse{Header: h, Body: res.Body, StoredAt: time.Now()}
+}
+
+// cacheLookup returns the cache key and stored response of a cacheable request
+func (c *Client) cacheLookup(r *http.Request) (string, *CachedResponse) {
+	if c.cache == nil || r.Method != http.MethodGet {
+		return "", nil
+	}
+
+	key := c.requestKey(r)
+	if noCache(r) {
+		return key, nil
+	}
+
+	res, ok := c.cache.store.Get(key)
+
+	if !ok {
+		return key, nil
+	}
+
+	if c.cache.invalidatedAfter(r.URL.Path, res.StoredAt) {
+		c.cache.store.Delete(key)
+		return key, nil
+	}
+
+	return key, res
+}
+
+// noCache reports whether r asks for a response from the server, as GetUncachedJSON does
+func noCache(r *http.Request) bool {
+	return r.Header.Get("Cache-Control") == "no-cache"
+}
+
+// cacheInvalidate evicts the responses a request other than GET may have changed:
+// those of its path and of the paths above it, up to the base URL
+func (c *Client) cacheInvalidate(r *http.Request) {
+	if c.cache == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return
+	}
+
+	now := time.Now()
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if c.cache.invalidated == nil {
+		c.cache.invalidated = make(map[string]time.Time)
+	}
+
+	for p := r.URL.Path; p != c.BaseURL.Path && p != "/" && p != "."; p = path.Dir(p) {
+		c.cache.invalidated[p] = now
+
+		u := *r.URL
+		u.Path, u.RawPath, u.RawQuery = p, "", ""
+		c.cache.store.Delete(c.requestKey(&http.Request{URL: &u}))
+	}
+
+	// comments are shown in their post, which /comments/:id does not name
+	if dir := path.Dir(r.URL.Path); path.Base(dir) == "comments" {
+		c.cache.invalidated[path.Join(path.Dir(dir), "posts")+"/"] = now
+	}
+
+	c.cache.prune()
+}
+
+// prune forgets the older half of invalidated once it holds maxInvalidated paths,
+// raising floor instead so the responses those paths made stale stay stale
+func (h *httpCache) prune() {
+	if len(h.invalidated) <= maxInvalidated {
+		return
+	}
+
+	times := make([]time.Time, 0, len(h.invalidated))
+	for _, at := range h.invalidated {
+		times = append(times, at)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	h.floor = times[len(times)/2]
+	for p, at := range h.invalidated {
+		if !at.After(h.floor) {
+			delete(h.invalidated, p)
+		}
+	}
+}
+
+// invalidatedAfter reports whether p was written to after a response was stored at
+func (h *httpCache) invalidatedAfter(p string, storedAt time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !storedAt.After(h.floor) {
+		return true
+	}
+
+	if at, ok := h.invalidated[p]; ok && !storedAt.After(at) {
+		return true
+	}
+
+	for d := p; ; d = path.Dir(d) {
+		if at, ok := h.invalidated[strings.TrimSuffix(d, "/")+"/"]; ok && !storedAt.After(at) {
+			return true
+		}
+
+		if d == "/" || d == "." {
+			return false
+		}
+	}
+}
+
+// requestKey identifies a GET request. Responses depend on the token's permissions.
+func (c *Client) requestKey(r *http.Request) string {
+	sum := sha256.Sum256([]byte(c.AccessToken))
+	return hex.EncodeToString(sum[:8]) + " " + r.URL.String()
+}
+
+// cacheStore stores the body of resp and rewinds it for decoding
+func (c *Client) cacheStore(key string, resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	c.cache.store.Set(key, &CachedResponse{
+		Header:   resp.Header.Clone(),
+		Body:     body,
+		StoredAt: time.Now(),
+	})
+
+	return nil
+}
+
+// doCached decodes a stored response as if it was sent by the server
+func (c *Client) doCached(r *http.Request, res *CachedResponse, v interface{}) (*Response, error) {
+	response := &Response{
+		Response: &http.Response{
+			Status:     http.StatusText(http.StatusOK),
+			StatusCode: http.StatusOK,
+			Header:     res.Header.Clone(),
+			Body:       ioutil.NopCloser(bytes.NewReader(res.Body)),
+			Request:    r,
+		},
+		Rate:      c.currentRate(),
+		FromCache: true,
+	}
+
+	if v == nil {
+		return response, nil
+	}
+
+	if err := json.Unmarshal(res.Body, v); err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// MemoryCache is a CacheStore keeping the most recently used responses in memory
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key string
+	res *CachedResponse
+}
+
+// NewMemoryCache returns a MemoryCache holding at most capacity responses
+func NewMemoryCache(capacity int) *MemoryCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &MemoryCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.items[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	m.ll.MoveToFront(e)
+	return e.Value.(*memoryCacheItem).res, true
+}
+
+func (m *MemoryCache) Set(key string, res *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[key]; ok {
+		e.Value.(*memoryCacheItem).res = res
+		m.ll.MoveToFront(e)
+		return
+	}
+
+	m.items[key] = m.ll.PushFront(&memoryCacheItem{key: key, res: res})
+
+	for m.ll.Len() > m.capacity {
+		oldest := m.ll.Back()
+		m.ll.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[key]; ok {
+		m.ll.Remove(e)
+		delete(m.items, key)
+	}
+}
+
+// DiskCache is a CacheStore writing each response to a JSON file in Dir
+type DiskCache struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// NewDiskCache returns a DiskCache storing files in dir, creating it when missing
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &DiskCache{Dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *DiskCache) Get(key string) (*CachedResponse, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, err := ioutil.ReadFile(d.path(key))
+
+	if err != nil {
+		return nil, false
+	}
+
+	res := &CachedResponse{}
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, false
+	}
+
+	return res, true
+}
+
+func (d *DiskCache) Set(key string, res *CachedResponse) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, err := json.Marshal(res)
+
+	if err != nil {
+		return
+	}
+
+	// write then rename so a crash never leaves a truncated file
+	tmp := d.path(key) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return
+	}
+
+	os.Rename(tmp, d.path(key))
+}
+
+func (d *DiskCache) Delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	os.Remove(d.path(key))
+}
@@ -0,0 +1,322 @@
+package docbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hayashiki/docbase-go/testutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newCacheTestClient(t *testing.T, handler http.HandlerFunc, store CacheStore, ttl time.Duration) (*Client, func()) {
+	srv := httptest.NewServer(handler)
+
+	cli, err := NewClientWithOptions("fakeTeam", "fakeToken", WithBaseURL(srv.URL), WithCache(store, ttl))
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	return cli, srv.Close
+}
+
+func TestCache_ETag(t *testing.T) {
+	var calls, notModified int
+
+	cli, done := newCacheTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, testutil.LoadFixture(t, "group-response.json"))
+	}, NewMemoryCache(10), 0)
+	defer done()
+
+	first, _, err := cli.Groups.Get(1)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	second, resp, err := cli.Groups.Get(1)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if calls != 2 || notModified != 1 {
+		t.Errorf("Server called %d times with %d not modified, want 2 and 1", calls, notModified)
+	}
+
+	if !resp.FromCache {
+		t.Errorf("Response should be served from cache")
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Cached group %+v, want %+v", second, first)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	var calls int
+
+	cli, done := newCacheTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, testutil.LoadFixture(t, "tag-list-response.json"))
+	}, NewMemoryCache(10), time.Hour)
+	defer done()
+
+	for i := 0; i < 3; i++ {
+		tags, _, err := cli.Tags.List()
+
+		if err != nil {
+			t.Fatalf("Shouldn't have returned an error: %+v", err)
+		}
+
+		if len(*tags) != 2 {
+			t.Errorf("Tags returned %+v", tags)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Server called %d times, want 1", calls)
+	}
+}
+
+func TestCache_StaleWhenRateLimited(t *testing.T) {
+	var calls int
+
+	cli, done := newCacheTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(headerRateLimit, "300")
+		w.Header().Set(headerRateRemaining, "0")
+		w.Header().Set(headerRateReset, strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		fmt.Fprint(w, testutil.LoadFixture(t, "group-response.json"))
+	}, NewMemoryCache(10), 0)
+	defer done()
+
+	if _, _, err := cli.Groups.Get(1); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	group, resp, err := cli.Groups.Get(1)
+
+	if err != nil {
+		t.Fatalf("Stale response should be served: %+v", err)
+	}
+
+	if !resp.FromCache || group.ID != 1 {
+		t.Errorf("Response should be served from cache: %+v", group)
+	}
+
+	if _, _, err := cli.Groups.Get(2); err == nil {
+		t.Errorf("Uncached request should fail while rate limited")
+	}
+
+	if calls != 1 {
+		t.Errorf("Server called %d times, want 1", calls)
+	}
+}
+
+func TestMemoryCache_Evict(t *testing.T) {
+	m := NewMemoryCache(2)
+
+	m.Set("a", &CachedResponse{Body: []byte("a")})
+	m.Set("b", &CachedResponse{Body: []byte("b")})
+	m.Get("a")
+	m.Set("c", &CachedResponse{Body: []byte("c")})
+
+	if _, ok := m.Get("b"); ok {
+		t.Errorf("Least recently used entry should be evicted")
+	}
+
+	for _, k := range []string{"a", "c"} {
+		if _, ok := m.Get(k); !ok {
+			t.Errorf("Entry %s should be kept", k)
+		}
+	}
+
+	m.Delete("a")
+
+	if _, ok := m.Get("a"); ok {
+		t.Errorf("Deleted entry should not be returned")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	d, err := NewDiskCache(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	want := &CachedResponse{
+		Header:   http.Header{"Etag": []string{`"v1"`}},
+		Body:     []byte(`{"id":1}`),
+		StoredAt: time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC),
+	}
+
+	d.Set("key", want)
+
+	got, ok := d.Get("key")
+
+	if !ok {
+		t.Fatalf("Stored entry should be returned")
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiskCache returned %+v, want %+v", got, want)
+	}
+
+	d.Delete("key")
+
+	if _, ok := d.Get("key"); ok {
+		t.Errorf("Deleted entry should not be returned")
+	}
+}
+
+func TestCache_InvalidatedByUpdate(t *testing.T) {
+	var listCalls int
+
+	// the server keeps the post like DocBase and lists it in every search
+	stored := map[string]interface{}{}
+	json.Unmarshal([]byte(testutil.LoadFixture(t, "post-detail-response.json")), &stored)
+
+	cli, done := newCacheTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPatch:
+			json.NewDecoder(r.Body).Decode(&stored)
+			json.NewEncoder(w).Encode(stored)
+		case r.URL.Path == "/posts":
+			listCalls++
+			json.NewEncoder(w).Encode(map[string]interface{}{"posts": []interface{}{stored}, "meta": map[string]interface{}{"total": 1}})
+		default:
+			json.NewEncoder(w).Encode(stored)
+		}
+	}, NewMemoryCache(10), time.Hour)
+	defer done()
+
+	if _, _, err := cli.Posts.Get(1); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if _, _, err := cli.Posts.List(&PostListOptions{Q: "tag:guide"}); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if _, _, err := cli.Posts.Update(1, &PostUpdateRequest{Title: String("新しいタイトル")}); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	post, resp, err := cli.Posts.Get(1)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if resp.FromCache || post.Title != "新しいタイトル" {
+		t.Errorf("Get after Update returned %q from cache %v", post.Title, resp.FromCache)
+	}
+
+	posts, _, err := cli.Posts.List(&PostListOptions{Q: "tag:guide"})
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if listCalls != 2 || posts[0].Title != "新しいタイトル" {
+		t.Errorf("List after Update returned %q with %d calls, want the update from the server", posts[0].Title, listCalls)
+	}
+
+	if _, resp, _ := cli.Posts.Get(1); !resp.FromCache {
+		t.Errorf("Responses stored after the update should be served from cache")
+	}
+}
+
+func TestCache_InvalidatedBySubresources(t *testing.T) {
+	calls := make(map[string]int)
+
+	cli, done := newCacheTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method+" "+r.URL.Path]++
+		switch r.URL.Path {
+		case "/posts":
+			fmt.Fprint(w, `{"posts": [], "meta": {"total": 0}}`)
+		default:
+			fmt.Fprint(w, testutil.LoadFixture(t, "post-detail-response.json"))
+		}
+	}, NewMemoryCache(10), time.Hour)
+	defer done()
+
+	get := func() {
+		cli.Posts.Get(1)
+		cli.Posts.List(&PostListOptions{Q: "tag:guide"})
+	}
+
+	get()
+
+	if _, err := cli.Posts.Archive(1); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	get()
+
+	if calls["GET /posts/1"] != 2 || calls["GET /posts"] != 2 {
+		t.Errorf("Archive should evict the post and the searches: %v", calls)
+	}
+
+	if _, err := cli.Comments.Delete(5); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	get()
+
+	if calls["GET /posts/1"] != 3 || calls["GET /posts"] != 3 {
+		t.Errorf("Deleting a comment should evict the posts: %v", calls)
+	}
+
+	get()
+
+	if calls["GET /posts/1"] != 3 || calls["GET /posts"] != 3 {
+		t.Errorf("Responses stored after the deletion should be served from cache: %v", calls)
+	}
+}
+
+func TestCache_InvalidatedPruned(t *testing.T) {
+	var calls int
+
+	cli, done := newCacheTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			calls++
+		}
+		fmt.Fprint(w, testutil.LoadFixture(t, "group-response.json"))
+	}, NewMemoryCache(10), time.Hour)
+	defer done()
+
+	cli.Groups.Get(1)
+
+	if _, err := cli.GroupUsers.Create(1, &GroupUserCreateRequest{UserIDs: []int{1}}); err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	// writes to other groups push /groups/1 out of the remembered paths
+	for id := 2; id <= maxInvalidated; id++ {
+		cli.GroupUsers.Create(id, &GroupUserCreateRequest{UserIDs: []int{1}})
+	}
+
+	if n := len(cli.cache.invalidated); n > maxInvalidated {
+		t.Errorf("%d paths remembered, want at most %d", n, maxInvalidated)
+	}
+
+	cli.Groups.Get(1)
+
+	if calls != 2 {
+		t.Errorf("The response stored before the write should stay stale once pruned, %d calls", calls)
+	}
+}
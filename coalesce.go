@@ -0,0 +1,86 @@
+package docbase
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// WithRequestCoalescing makes identical GET requests issued concurrently share one HTTP call.
+// Every caller decodes the shared response body into its own value. The request of the
+// first caller is the one sent, so its context governs cancellation.
+func WithRequestCoalescing() Option {
+	return func(o *clientOptions) error {
+		o.coalesce = true
+		return nil
+	}
+}
+
+// flightGroup deduplicates in-flight requests, like golang.org/x/sync/singleflight
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is an in-flight or completed request
+type flightCall struct {
+	wg   sync.WaitGroup
+	dups int
+
+	resp *Response
+	body json.RawMessage
+	err  error
+}
+
+// do calls fn once for key until it returns, sharing its result with callers arriving meanwhile
+func (g *flightGroup) do(key string, fn func() (*Response, json.RawMessage, error)) (*Response, json.RawMessage, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.resp, call.body, call.err
+	}
+
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.resp, call.body, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return call.resp, call.body, call.err
+}
+
+func (c *Client) doCoalesced(r *http.Request, v interface{}) (*Response, error) {
+	resp, body, err := c.flight.do(c.requestKey(r), func() (*Response, json.RawMessage, error) {
+		var body json.RawMessage
+		resp, err := c.do(r, &body)
+		return resp, body, err
+	})
+
+	if resp != nil {
+		// services fill Meta of the response, so each caller gets its own copy
+		cp := *resp
+		resp = &cp
+	}
+
+	if err != nil || v == nil || len(body) == 0 {
+		return resp, err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package docbase
+
+import (
+	"fmt"
+	"github.com/hayashiki/docbase-go/testutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		fmt.Fprint(w, testutil.LoadFixture(t, "post-detail-response.json"))
+	}))
+	defer srv.Close()
+
+	cli, err := NewClientWithOptions("fakeTeam", "fakeToken", WithBaseURL(srv.URL), WithRequestCoalescing())
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	const n = 5
+	posts := make([]*Post, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			posts[i], _, errs[i] = cli.Posts.Get(1)
+		}(i)
+	}
+
+	// wait until every caller joined the in-flight request
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cli.flight.mu.Lock()
+		var dups int
+		for _, call := range cli.flight.calls {
+			dups = call.dups
+		}
+		cli.flight.mu.Unlock()
+
+		if dups == n-1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Callers did not join the in-flight request: %d", dups)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("Server called %d times, want 1", got)
+	}
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Errorf("Get returned an error: %v", errs[i])
+		}
+
+		if posts[i] == posts[0] && i != 0 {
+			t.Errorf("Callers should not share the same pointer")
+		}
+
+		if !reflect.DeepEqual(posts[i], posts[0]) {
+			t.Errorf("Get returned %+v, want %+v", posts[i], posts[0])
+		}
+	}
+
+	if posts[0].ID != 1 {
+		t.Errorf("Get returned %+v", posts[0])
+	}
+}
+
+func TestRequestCoalescing_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": "not_found", "messages": ["Not Found"]}`)
+	}))
+	defer srv.Close()
+
+	cli, _ := NewClientWithOptions("fakeTeam", "fakeToken", WithBaseURL(srv.URL), WithRequestCoalescing())
+
+	_, _, err := cli.Posts.Get(1)
+
+	errResp, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("Error should be of type ErrorResponse but is %T: %+v", err, err)
+	}
+
+	if got, want := errResp.ErrorStr, "not_found"; got != want {
+		t.Errorf("Error: %v, want %v", got, want)
+	}
+}
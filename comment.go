@@ -3,7 +3,6 @@ package docbase
 import (
 	"fmt"
 	"net/http"
-	"net/url"
 	"time"
 )
 
@@ -27,56 +26,21 @@ type Comment struct {
 	SimpleUser `json:"user"`
 }
 
-// CommentCreateRequest identifies Comment for the Create request
+// CommentCreateRequest identifies Comment for the Create request.
+// Unset optional fields are omitted so that the server applies its defaults.
 type CommentCreateRequest struct {
-	Body        string    `json:"body"`
-	Notice      bool      `json:"notice,omitempty"`
-	AuthorID    string    `json:"author_id,omitempty"`
-	PublishedAt time.Time `json:"published_at,omitempty"`
+	Body        string     `json:"body"`
+	Notice      *bool      `json:"notice,omitempty"` // optional, default: true
+	AuthorID    string     `json:"author_id,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
 }
 
 // Create Comment
 func (s *commentService) Create(postID int, commentRequest *CommentCreateRequest) (*Comment, *Response, error) {
-
-	u, err := url.Parse(fmt.Sprintf("/posts/%d/comments", postID))
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodPost, u.String(), commentRequest)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	cResp := &Comment{}
-	resp, err := s.client.Do(req, cResp)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return cResp, resp, err
+	return doJSON[Comment](s.client, http.MethodPost, fmt.Sprintf("/posts/%d/comments", postID), commentRequest)
 }
 
 // Delete Comment
 func (s *commentService) Delete(commentID int) (*Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/comments/%d", commentID))
-
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodDelete, u.String(), nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := s.client.Do(req, nil)
-	if err != nil {
-		return resp, err
-	}
-
-	return resp, err
+	return s.client.call(http.MethodDelete, fmt.Sprintf("/comments/%d", commentID), nil, nil)
 }
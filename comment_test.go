@@ -1,6 +1,7 @@
 package docbase
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/hayashiki/docbase-go/testutil"
 	"net/http"
@@ -115,3 +116,29 @@ func TestCommentService_Delete(t *testing.T) {
 		t.Errorf("Comment Delete request code = %v, expected %v", resp.StatusCode, http.StatusOK)
 	}
 }
+
+func TestCommentCreateRequest_MarshalJSON(t *testing.T) {
+	publishedAt := time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+
+	testCases := []struct {
+		desc string
+		req  *CommentCreateRequest
+		want string
+	}{
+		{"Unset", &CommentCreateRequest{Body: "b"}, `{"body":"b"}`},
+		{"NoticeFalse", &CommentCreateRequest{Body: "b", Notice: Bool(false)}, `{"body":"b","notice":false}`},
+		{"NoticeTrue", &CommentCreateRequest{Body: "b", Notice: Bool(true)}, `{"body":"b","notice":true}`},
+		{"Imported", &CommentCreateRequest{Body: "b", AuthorID: "3", PublishedAt: Time(publishedAt)}, `{"body":"b","author_id":"3","published_at":"2020-03-27T09:25:09Z"}`},
+	}
+	for _, tc := range testCases {
+		b, err := json.Marshal(tc.req)
+
+		if err != nil {
+			t.Errorf("%s: Marshal returned an error: %v", tc.desc, err)
+		}
+
+		if got := string(b); got != tc.want {
+			t.Errorf("%s: Marshal is %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package docbase
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -25,12 +26,6 @@ const (
 	headerRateReset     = "X-RateLimit-Reset"
 )
 
-const (
-	publicScope  = "public"
-	groupScope   = "group"
-	privateScope = "private"
-)
-
 type Client struct {
 	BaseURL     *url.URL
 	AccessToken string
@@ -40,6 +35,13 @@ type Client struct {
 	rateMu    sync.Mutex
 	rateLimit Rate
 
+	userAgent   string
+	retryPolicy *RetryPolicy
+	limiter     Limiter
+	logger      Logger
+	cache       *httpCache
+	flight      *flightGroup
+
 	Posts       PostService
 	Users       UserService
 	Groups      GroupService
@@ -47,6 +49,11 @@ type Client struct {
 	Tags        TagService
 	Comments    CommentService
 	Attachments AttachmentService
+
+	// GroupDirectory resolves group names and IDs
+	GroupDirectory *GroupDirectory
+	// UserDirectory looks up users by ID, username or name
+	UserDirectory *UserDirectory
 }
 
 // Response is http response wrapper for DocBase
@@ -54,6 +61,9 @@ type Response struct {
 	*http.Response
 	Rate
 	Meta
+
+	// FromCache reports whether the body was served from the response cache
+	FromCache bool
 }
 
 func newResponse(r *http.Response) *Response {
@@ -68,17 +78,57 @@ func NewClient(httpClient *http.Client, team, token string) *Client {
 		httpClient = http.DefaultClient
 	}
 
-	baseURL, err := url.Parse(fmt.Sprintf(defaultBaseURL, team))
+	cli, err := newClient(httpClient, team, token)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	return cli
+}
+
+// NewClientWithOptions returns client API configured by opts.
+// Unlike NewClient, invalid input is reported as an error.
+func NewClientWithOptions(team, token string, opts ...Option) (*Client, error) {
+	if team == "" {
+		return nil, errors.New("team is required")
+	}
+
+	if token == "" {
+		return nil, errors.New("token is required")
+	}
+
+	cli, err := newClient(http.DefaultClient, team, token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	o := &clientOptions{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	o.apply(cli)
+
+	return cli, nil
+}
+
+func newClient(httpClient *http.Client, team, token string) (*Client, error) {
+	baseURL, err := url.Parse(fmt.Sprintf(defaultBaseURL, team))
+
+	if err != nil {
+		return nil, err
+	}
+
 	cli := &Client{
 		AccessToken: token,
 		Team:        team,
 		Client:      httpClient,
 		BaseURL:     baseURL,
+		userAgent:   userAgent,
 	}
 
 	cli.Posts = &postService{cli}
@@ -88,8 +138,10 @@ func NewClient(httpClient *http.Client, team, token string) *Client {
 	cli.Tags = &tagService{cli}
 	cli.Attachments = &attachmentService{cli}
 	cli.GroupUsers = &groupUserService{cli}
+	cli.GroupDirectory = NewGroupDirectory(cli, defaultDirectoryTTL)
+	cli.UserDirectory = NewUserDirectory(cli, defaultDirectoryTTL)
 
-	return cli
+	return cli, nil
 }
 
 // NewRequest creates a API request with HTTP method, endpoint path and payload
@@ -117,22 +169,43 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("X-DocBaseToken", c.AccessToken)
 	req.Header.Add("X-Api-Version", apiVersion)
-	req.Header.Add("USER_AGENT", userAgent)
+	req.Header.Add("USER_AGENT", c.userAgent)
 
 	return req, nil
 }
 
 // Do sends request and returns API response
 func (c *Client) Do(r *http.Request, v interface{}) (*Response, error) {
+	if c.flight != nil && r.Method == http.MethodGet && !noCache(r) {
+		return c.doCoalesced(r, v)
+	}
+
+	return c.do(r, v)
+}
+
+func (c *Client) do(r *http.Request, v interface{}) (*Response, error) {
+	key, entry := c.cacheLookup(r)
+
+	if entry != nil && c.cache.fresh(entry) {
+		return c.doCached(r, entry, v)
+	}
 
 	if err := c.checkRateLimitBeforeDo(r); err != nil {
+		if entry != nil {
+			return c.doCached(r, entry, v)
+		}
 		return &Response{
 			Response: err.Response,
 			Rate:     err.Rate,
 		}, err
 	}
 
-	resp, err := c.Client.Do(r)
+	if entry != nil {
+		entry.setValidators(r)
+	}
+
+	resp, err := c.send(r)
+	c.cacheInvalidate(r)
 
 	if err != nil {
 		return nil, err
@@ -140,6 +213,19 @@ func (c *Client) Do(r *http.Request, v interface{}) (*Response, error) {
 
 	defer resp.Body.Close()
 
+	c.updateRate(resp)
+
+	if entry != nil {
+		switch resp.StatusCode {
+		case http.StatusNotModified:
+			entry = entry.revalidated(resp.Header)
+			c.cache.store.Set(key, entry)
+			return c.doCached(r, entry, v)
+		case http.StatusTooManyRequests:
+			return c.doCached(r, entry, v)
+		}
+	}
+
 	response := newResponse(resp)
 
 	err = CheckResponse(resp)
@@ -147,6 +233,12 @@ func (c *Client) Do(r *http.Request, v interface{}) (*Response, error) {
 		return response, err
 	}
 
+	if key != "" && resp.StatusCode == http.StatusOK {
+		if err := c.cacheStore(key, resp); err != nil {
+			return response, err
+		}
+	}
+
 	if v == nil {
 		return response, nil
 	}
@@ -160,7 +252,7 @@ func (c *Client) Do(r *http.Request, v interface{}) (*Response, error) {
 }
 
 func (c *Client) DoUpload(r *http.Request) (FileContent, *Response, error) {
-	resp, err := c.Client.Do(r)
+	resp, err := c.send(r)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -232,11 +324,27 @@ func parseRate(r *http.Response) Rate {
 	return rate
 }
 
-// checkRateLimitBeforeDo referenced from https://github.com/google/go-github/blob/master/github/github.go#L627
-func (c *Client) checkRateLimitBeforeDo(req *http.Request) *RateLimitError {
+// updateRate remembers the latest known rate so that checkRateLimitBeforeDo can refuse requests early
+func (c *Client) updateRate(r *http.Response) {
+	rate := parseRate(r)
+	if rate.err != nil || rate.Reset.IsZero() {
+		return
+	}
+
 	c.rateMu.Lock()
-	rate := c.rateLimit
+	c.rateLimit = rate
 	c.rateMu.Unlock()
+}
+
+func (c *Client) currentRate() Rate {
+	c.rateMu.Lock()
+	defer c.rateMu.Unlock()
+	return c.rateLimit
+}
+
+// checkRateLimitBeforeDo referenced from https://github.com/google/go-github/blob/master/github/github.go#L627
+func (c *Client) checkRateLimitBeforeDo(req *http.Request) *RateLimitError {
+	rate := c.currentRate()
 	if !rate.Reset.Time.IsZero() && rate.Remaining == 0 && time.Now().Before(rate.Reset.Time) {
 		// Create a fake response.
 		resp := &http.Response{
@@ -311,3 +419,12 @@ func (r *ErrorResponse) Error() string {
 		r.Response.Request.Method, sanitizeURL(r.Response.Request.URL),
 		r.Response.StatusCode, r.Messages, r.ErrorStr)
 }
+
+// String returns a pointer to v, for optional fields of requests
+func String(v string) *string { return &v }
+
+// Bool returns a pointer to v, for optional fields of requests
+func Bool(v bool) *bool { return &v }
+
+// Time returns a pointer to v, for optional fields of requests
+func Time(v time.Time) *time.Time { return &v }
@@ -0,0 +1,345 @@
+// Package epub exports posts to an EPUB 3 book, a chapter per post.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+)
+
+// MediaType is the media type of EPUB files
+const MediaType = "application/epub+zip"
+
+// imageTypes are the media types of the attachments embedded in books, by extension
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+// Exporter writes posts to EPUB books
+type Exporter struct {
+	client *docbase.Client
+	md     goldmark.Markdown
+
+	// Title is the title of the book, the team by default
+	Title string
+	// Language is the language of the book, "ja" by default
+	Language string
+	// Comments adds the comments of the posts to their chapters
+	Comments bool
+}
+
+// New returns an Exporter
+func New(c *docbase.Client) *Exporter {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(gmhtml.WithHardWraps(), gmhtml.WithXHTML()),
+	)
+
+	return &Exporter{client: c, md: md, Language: "ja"}
+}
+
+// Fetch returns the posts with the IDs, in the order of the IDs
+func (e *Exporter) Fetch(ids []int) ([]*docbase.Post, error) {
+	posts := make([]*docbase.Post, 0, len(ids))
+
+	for _, id := range ids {
+		p, _, err := e.client.Posts.Get(id)
+
+		if err != nil {
+			return nil, fmt.Errorf("getting post %d: %w", id, err)
+		}
+
+		posts = append(posts, p)
+	}
+
+	return posts, nil
+}
+
+// Search returns the posts matching the query, oldest first
+func (e *Exporter) Search(q string) ([]*docbase.Post, error) {
+	posts, err := postiter.All(e.client, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("listing posts: %w", err)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].CreatedAt.Before(posts[j].CreatedAt) })
+	return posts, nil
+}
+
+// WriteFile writes the book to the file name, replacing it only once it is complete
+func (e *Exporter) WriteFile(name string, posts []*docbase.Post) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".epub-")
+
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := e.Write(f, posts); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Write writes the posts as a book with a chapter per post, in order, and a table of
+// contents. Images attached to the posts are embedded. The authors of the posts are
+// the creators of the book, which is dated by the first post created and the last updated.
+func (e *Exporter) Write(w io.Writer, posts []*docbase.Post) error {
+	if len(posts) == 0 {
+		return fmt.Errorf("no posts to export")
+	}
+
+	b := &book{
+		team:     e.client.Team,
+		title:    e.Title,
+		language: e.Language,
+		images:   make(map[string]string),
+	}
+
+	if b.title == "" {
+		b.title = e.client.Team
+	}
+
+	for _, p := range posts {
+		if err := e.chapter(b, p); err != nil {
+			return fmt.Errorf("post %d: %w", p.ID, err)
+		}
+	}
+
+	return b.write(w, posts)
+}
+
+type book struct {
+	team     string
+	title    string
+	language string
+	chapters []chapter
+	// images maps the URLs of the embedded attachments to their files
+	images map[string]string
+	files  []file
+}
+
+type chapter struct {
+	File  string
+	Title string
+	Post  *docbase.Post
+	// Body is the XHTML of the post
+	Body     string
+	Comments []comment
+}
+
+// file is a file of the book other than a chapter
+type file struct {
+	Name      string
+	MediaType string
+	Content   []byte
+}
+
+func (e *Exporter) chapter(b *book, p *docbase.Post) error {
+	body := p.Body
+
+	for _, a := range p.Attachments {
+		mediaType, ok := imageTypes[strings.ToLower(path.Ext(a.Name))]
+		if !ok || a.URL == "" {
+			continue
+		}
+
+		if _, ok := b.images[a.URL]; !ok {
+			content, _, err := e.client.Attachments.Download(a.ID)
+
+			if err != nil {
+				return fmt.Errorf("downloading %s: %w", a.Name, err)
+			}
+
+			name := "images/" + strconv.Itoa(len(b.images)+1) + strings.ToLower(path.Ext(a.Name))
+			b.images[a.URL] = name
+			b.files = append(b.files, file{Name: name, MediaType: mediaType, Content: *content})
+		}
+
+		// images link to themselves on DocBase, which would leave the reading order
+		linked := regexp.MustCompile(`\[(!\[[^\]]*\]\(` + regexp.QuoteMeta(a.URL) + `\))\]\(` + regexp.QuoteMeta(a.URL) + `\)`)
+		body = linked.ReplaceAllString(body, "$1")
+		body = strings.ReplaceAll(body, a.URL, "../"+b.images[a.URL])
+	}
+
+	c := chapter{File: "chapters/" + strconv.Itoa(len(b.chapters)+1) + ".xhtml", Title: p.Title, Post: p}
+
+	var err error
+	if c.Body, err = e.xhtml(body); err != nil {
+		return err
+	}
+
+	if e.Comments {
+		for _, cm := range p.Comments {
+			rendered, err := e.xhtml(cm.Body)
+
+			if err != nil {
+				return err
+			}
+
+			c.Comments = append(c.Comments, comment{Name: cm.Name, CreatedAt: cm.CreatedAt, Body: template.HTML(rendered)})
+		}
+	}
+
+	b.chapters = append(b.chapters, c)
+	return nil
+}
+
+// xhtml renders the markdown as well-formed XHTML, the HTML of goldmark being parsed
+// and rendered again so entities and unclosed tags do not break the book
+func (e *Exporter) xhtml(md string) (string, error) {
+	var rendered bytes.Buffer
+	if err := e.md.Convert([]byte(md), &rendered); err != nil {
+		return "", err
+	}
+
+	nodes, err := html.ParseFragment(&rendered, &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		if err := html.Render(&out, n); err != nil {
+			return "", err
+		}
+	}
+
+	return out.String(), nil
+}
+
+// identifier derives the identifier of the book from the team and posts, so books of
+// the same posts share it
+func (b *book) identifier(posts []*docbase.Post) string {
+	h := sha1.New()
+	io.WriteString(h, b.team)
+	for _, p := range posts {
+		fmt.Fprintf(h, "/%d", p.ID)
+	}
+
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// dates returns when the first post was created and the last updated
+func dates(posts []*docbase.Post) (time.Time, time.Time) {
+	var created, modified time.Time
+
+	for _, p := range posts {
+		if created.IsZero() || p.CreatedAt.Before(created) {
+			created = p.CreatedAt
+		}
+
+		updated := p.UpdatedAt
+		if updated.IsZero() {
+			updated = p.CreatedAt
+		}
+
+		if updated.After(modified) {
+			modified = updated
+		}
+	}
+
+	return created.UTC(), modified.UTC()
+}
+
+func (b *book) write(w io.Writer, posts []*docbase.Post) error {
+	created, modified := dates(posts)
+	zw := zip.NewWriter(w)
+
+	// the media type comes first and uncompressed, for readers to identify the file
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: modified})
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(mt, MediaType); err != nil {
+		return err
+	}
+
+	opf, err := b.packageDocument(b.identifier(posts), created, modified)
+
+	if err != nil {
+		return err
+	}
+
+	nav, err := b.nav()
+
+	if err != nil {
+		return err
+	}
+
+	entries := []file{
+		{Name: "META-INF/container.xml", Content: []byte(containerXML)},
+		{Name: "OEBPS/content.opf", Content: opf},
+		{Name: "OEBPS/nav.xhtml", Content: nav},
+		{Name: "OEBPS/style.css", Content: []byte(styleCSS)},
+	}
+
+	for _, c := range b.chapters {
+		content, err := b.chapterXHTML(c)
+
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, file{Name: "OEBPS/" + c.File, Content: content})
+	}
+
+	for _, f := range b.files {
+		entries = append(entries, file{Name: "OEBPS/" + f.Name, Content: f.Content})
+	}
+
+	for _, f := range entries {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: modified})
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := fw.Write(f.Content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,243 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+var image = []byte("\x89PNG image")
+
+var team = fakeserver.Seed{
+	Posts: []fakeserver.Post{{
+		Key:    "welcome",
+		Author: 2,
+		Post: docbase.Post{
+			Title:     "Welcome",
+			Body:      "Read this first &amp; ask <b>anything</b>&nbsp;<br>\n\n- [ ] done",
+			Tags:      []docbase.Tag{{Name: "onboarding"}},
+			CreatedAt: time.Date(2019, 4, 2, 10, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC),
+		},
+	}},
+}
+
+// addSetup adds a post showing an image and linking to a pdf, and returns its ID
+func addSetup(s *fakeserver.Team) int {
+	a := s.AddAttachment("diagram.png", image)
+	pdf := s.AddAttachment("runbook.pdf", []byte("%PDF"))
+
+	return s.Seed(fakeserver.Post{
+		Author: 1,
+		Post: docbase.Post{
+			Title:       "Setup <dev>",
+			Body:        "# Setup\n\n" + a.Markdown + "\n\nSee " + pdf.Markdown,
+			Tags:        []docbase.Tag{{Name: "onboarding"}},
+			Attachments: []docbase.Attachment{a, pdf},
+			CreatedAt:   time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC),
+		},
+		Comments: []fakeserver.Comment{{Author: 2, Comment: docbase.Comment{Body: "Thanks!"}}},
+	})
+}
+
+type opf struct {
+	Metadata struct {
+		Identifier string   `xml:"identifier"`
+		Title      string   `xml:"title"`
+		Language   string   `xml:"language"`
+		Creators   []string `xml:"creator"`
+		Date       string   `xml:"date"`
+		Meta       []struct {
+			Property string `xml:"property,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// readBook opens the book and checks every XML document of it is well-formed
+func readBook(t *testing.T, b []byte) map[string][]byte {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+
+	if err != nil {
+		t.Fatalf("the book is not a zip: %v", err)
+	}
+
+	if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("the book should start with an uncompressed mimetype, not %+v", first.FileHeader)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+
+		if ext := path.Ext(f.Name); ext == ".xml" || ext == ".opf" || ext == ".xhtml" {
+			if !bytes.HasPrefix(files[f.Name], []byte(xml.Header)) {
+				t.Errorf("%s should start with the XML declaration", f.Name)
+			}
+
+			dec := xml.NewDecoder(bytes.NewReader(files[f.Name]))
+			for {
+				if _, err := dec.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Errorf("%s is not well-formed: %v\n%s", f.Name, err, files[f.Name])
+					break
+				}
+			}
+		}
+	}
+
+	if string(files["mimetype"]) != MediaType {
+		t.Errorf("mimetype is %q", files["mimetype"])
+	}
+
+	return files
+}
+
+func TestExporter_Write(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	c, ids := s.Client, []int{s.PostIDs["welcome"], addSetup(s)}
+
+	e := New(c)
+	e.Title = "Reading pack"
+	e.Comments = true
+
+	posts, err := e.Fetch(ids)
+
+	if err != nil {
+		t.Fatalf("Fetch returned an error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Write(&buf, posts); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+
+	files := readBook(t, buf.Bytes())
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	xml.Unmarshal(files["META-INF/container.xml"], &container)
+
+	if len(container.Rootfiles) != 1 || container.Rootfiles[0].FullPath != "OEBPS/content.opf" {
+		t.Fatalf("container is %+v", container)
+	}
+
+	var pkg opf
+	if err := xml.Unmarshal(files["OEBPS/content.opf"], &pkg); err != nil {
+		t.Fatalf("content.opf: %v", err)
+	}
+
+	m := pkg.Metadata
+	if !strings.HasPrefix(m.Identifier, "urn:uuid:") || m.Title != "Reading pack" || m.Language != "ja" || m.Date != "2019-04-01" {
+		t.Errorf("metadata is %+v", m)
+	}
+
+	if len(m.Creators) != 2 || m.Creators[0] != "Hanako" || m.Creators[1] != "Taro" {
+		t.Errorf("creators are %v", m.Creators)
+	}
+
+	if len(m.Meta) != 1 || m.Meta[0].Property != "dcterms:modified" || m.Meta[0].Value != "2019-05-01T09:00:00Z" {
+		t.Errorf("meta is %+v", m.Meta)
+	}
+
+	hrefs := make(map[string]string)
+	for _, item := range pkg.Items {
+		hrefs[item.ID] = item.Href
+
+		if _, ok := files["OEBPS/"+item.Href]; !ok {
+			t.Errorf("%s is in the manifest, not in the book", item.Href)
+		}
+
+		if item.Properties == "nav" && item.Href != "nav.xhtml" {
+			t.Errorf("the nav is %s", item.Href)
+		}
+	}
+
+	// chapters are in the order of the IDs
+	if len(pkg.Spine) != 2 || hrefs[pkg.Spine[0].IDRef] != "chapters/1.xhtml" || hrefs[pkg.Spine[1].IDRef] != "chapters/2.xhtml" {
+		t.Fatalf("spine is %+v", pkg.Spine)
+	}
+
+	nav := string(files["OEBPS/nav.xhtml"])
+	if !strings.Contains(nav, `<a href="chapters/1.xhtml">Welcome</a>`) || !strings.Contains(nav, `<a href="chapters/2.xhtml">Setup &lt;dev&gt;</a>`) {
+		t.Errorf("nav is\n%s", nav)
+	}
+
+	welcome := string(files["OEBPS/chapters/1.xhtml"])
+	if !strings.Contains(welcome, "Hanako, 2019-04-02 (updated 2019-05-01)") || strings.Contains(welcome, "<b>") {
+		t.Errorf("welcome is\n%s", welcome)
+	}
+
+	setup := string(files["OEBPS/chapters/2.xhtml"])
+	if !strings.Contains(setup, `<img src="../images/1.png" alt="diagram.png"/>`) || strings.Contains(setup, `<a href="../images/`) {
+		t.Errorf("the image should be embedded without a link:\n%s", setup)
+	}
+
+	if !strings.Contains(setup, `<div class="comment">`) || !strings.Contains(setup, "Thanks!") {
+		t.Errorf("the comment should be in the chapter:\n%s", setup)
+	}
+
+	if !bytes.Equal(files["OEBPS/images/1.png"], image) || hrefs["image-0"] != "images/1.png" {
+		t.Errorf("the image is not embedded: %v", hrefs)
+	}
+
+	if len(pkg.Items) != 5 {
+		t.Errorf("the pdf should not be embedded: %+v", pkg.Items)
+	}
+}
+
+func TestExporter_SearchWriteFile(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	addSetup(s)
+	e := New(s.Client)
+
+	posts, err := e.Search("tag:onboarding")
+
+	if err != nil || len(posts) != 2 || posts[0].Title != "Setup <dev>" {
+		t.Fatalf("Search returned %+v, %v", posts, err)
+	}
+
+	name := filepath.Join(t.TempDir(), "pack.epub")
+	if err := e.WriteFile(name, posts); err != nil {
+		t.Fatalf("WriteFile returned an error: %v", err)
+	}
+
+	zr, err := zip.OpenReader(name)
+
+	if err != nil {
+		t.Fatalf("the book is not a zip: %v", err)
+	}
+	defer zr.Close()
+
+	if len(zr.File) != 8 {
+		t.Errorf("the book has %d files", len(zr.File))
+	}
+
+	if err := e.Write(io.Discard, nil); err == nil {
+		t.Errorf("Write should fail without posts")
+	}
+}
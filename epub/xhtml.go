@@ -0,0 +1,158 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html/template"
+	"time"
+)
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const styleCSS = `body { font-family: serif; line-height: 1.7; }
+h1 { font-size: 1.5em; }
+.meta { color: #666; font-size: 0.85em; }
+img { max-width: 100%; }
+pre { white-space: pre-wrap; font-size: 0.85em; background: #f6f6f6; padding: 0.5em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.2em 0.5em; }
+.comment { border-top: 1px solid #ccc; margin-top: 1em; }
+`
+
+var packageTemplate = template.Must(template.New("package").Parse(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{.Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.ID}}</dc:identifier>
+    <dc:title>{{.Title}}</dc:title>
+    <dc:language>{{.Language}}</dc:language>
+{{- range .Creators}}
+    <dc:creator>{{.}}</dc:creator>
+{{- end}}
+    <dc:date>{{.Created}}</dc:date>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+{{- range $i, $c := .Chapters}}
+    <item id="chapter-{{$i}}" href="{{$c.File}}" media-type="application/xhtml+xml"/>
+{{- end}}
+{{- range $i, $f := .Files}}
+    <item id="image-{{$i}}" href="{{$f.Name}}" media-type="{{$f.MediaType}}"/>
+{{- end}}
+  </manifest>
+  <spine>
+{{- range $i, $c := .Chapters}}
+    <itemref idref="chapter-{{$i}}"/>
+{{- end}}
+  </spine>
+</package>
+`))
+
+var navTemplate = template.Must(template.New("nav").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language}}" lang="{{.Language}}">
+<head>
+<meta charset="UTF-8"/>
+<title>{{.Title}}</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>{{.Title}}</h1>
+<ol>
+{{- range .Chapters}}
+<li><a href="{{.File}}">{{.Title}}</a></li>
+{{- end}}
+</ol>
+</nav>
+</body>
+</html>
+`))
+
+var chapterTemplate = template.Must(template.New("chapter").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language}}" lang="{{.Language}}">
+<head>
+<meta charset="UTF-8"/>
+<title>{{.Title}}</title>
+<link rel="stylesheet" type="text/css" href="../style.css"/>
+</head>
+<body>
+<section epub:type="chapter">
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Post.User.Name}}, {{.Post.CreatedAt.Format "2006-01-02"}}{{if .Updated}} (updated {{.Post.UpdatedAt.Format "2006-01-02"}}){{end}}</p>
+{{.Body}}
+{{- range .Comments}}
+<div class="comment">
+<p class="meta">{{.Name}}, {{.CreatedAt.Format "2006-01-02 15:04"}}</p>
+{{.Body}}
+</div>
+{{- end}}
+</section>
+</body>
+</html>
+`))
+
+// comment is a comment rendered for its chapter
+type comment struct {
+	Name      string
+	CreatedAt time.Time
+	Body      template.HTML
+}
+
+func (b *book) packageDocument(id string, created, modified time.Time) ([]byte, error) {
+	var creators []string
+	seen := make(map[int]bool)
+
+	for _, c := range b.chapters {
+		if u := c.Post.User; u.Name != "" && !seen[u.ID] {
+			seen[u.ID] = true
+			creators = append(creators, u.Name)
+		}
+	}
+
+	return execute(packageTemplate, map[string]interface{}{
+		"ID":       id,
+		"Title":    b.title,
+		"Language": b.language,
+		"Creators": creators,
+		"Created":  created.Format("2006-01-02"),
+		"Modified": modified.Format("2006-01-02T15:04:05Z"),
+		"Chapters": b.chapters,
+		"Files":    b.files,
+	})
+}
+
+func (b *book) nav() ([]byte, error) {
+	return execute(navTemplate, map[string]interface{}{
+		"Title":    b.title,
+		"Language": b.language,
+		"Chapters": b.chapters,
+	})
+}
+
+func (b *book) chapterXHTML(c chapter) ([]byte, error) {
+	return execute(chapterTemplate, map[string]interface{}{
+		"Title":    c.Title,
+		"Language": b.language,
+		"Post":     c.Post,
+		"Updated":  !c.Post.UpdatedAt.IsZero() && c.Post.UpdatedAt.Format("2006-01-02") != c.Post.CreatedAt.Format("2006-01-02"),
+		"Body":     template.HTML(c.Body),
+		"Comments": c.Comments,
+	})
+}
+
+// execute writes the XML declaration, which html/template would escape, and the template
+func execute(t *template.Template, data interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -1,3 +1,11 @@
 module github.com/hayashiki/docbase-go
 
-go 1.17
+go 1.18
+
+require (
+	github.com/yuin/goldmark v1.6.0
+	golang.org/x/crypto v0.14.0
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -9,6 +9,7 @@ import (
 )
 
 // GroupService implements interface with API /groups endpoint.
+// The API provides no endpoint to update, archive or delete a group.
 // See https://help.docbase.io/posts/45703#%E3%82%B0%E3%83%AB%E3%83%BC%E3%83%97
 type GroupService interface {
 	List(opts *GroupListOptions) (*GroupListResponse, *Response, error)
@@ -55,77 +56,20 @@ type GroupListResponse []SimpleGroup
 
 // List Group
 func (s *groupService) List(opts *GroupListOptions) (*GroupListResponse, *Response, error) {
-	u, err := url.Parse("/groups")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	q := u.Query()
+	q := url.Values{}
 	q.Set("per_page", strconv.Itoa(opts.PerPage))
 	q.Set("page", strconv.Itoa(opts.Page))
 	q.Set("q", opts.Name)
-	u.RawQuery = q.Encode()
-
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	res := &GroupListResponse{}
-	resp, err := s.client.Do(req, res)
 
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return res, resp, err
+	return doJSON[GroupListResponse](s.client, http.MethodGet, withQuery("/groups", q), nil)
 }
 
 // Get Group
 func (s *groupService) Get(id int) (*Group, *Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/groups/%d", id))
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	res := &Group{}
-	resp, err := s.client.Do(req, res)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return res, resp, err
+	return doJSON[Group](s.client, http.MethodGet, fmt.Sprintf("/groups/%d", id), nil)
 }
 
 // Create Group
 func (s *groupService) Create(createRequest *GroupCreateRequest) (*Group, *Response, error) {
-	u, err := url.Parse("/groups")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodPost, u.String(), createRequest)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	cResp := &Group{}
-	resp, err := s.client.Do(req, cResp)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return cResp, resp, err
+	return doJSON[Group](s.client, http.MethodPost, "/groups", createRequest)
 }
@@ -0,0 +1,219 @@
+package docbase
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDirectoryTTL = 10 * time.Minute
+	// missRefreshInterval keeps lookups of unknown names from reloading on every call
+	missRefreshInterval = 10 * time.Second
+	groupListPerPage    = 100
+)
+
+// ErrGroupNotFound is returned when no group has the name or ID
+var ErrGroupNotFound = errors.New("group not found")
+
+// GroupRef identifies a group by either ID or name
+type GroupRef struct {
+	ID   int
+	Name string
+}
+
+// GroupByID returns a GroupRef of the group ID
+func GroupByID(id int) GroupRef {
+	return GroupRef{ID: id}
+}
+
+// GroupByName returns a GroupRef of the group name
+func GroupByName(name string) GroupRef {
+	return GroupRef{Name: name}
+}
+
+func (r GroupRef) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	return fmt.Sprintf("#%d", r.ID)
+}
+
+// GroupDirectory resolves group names and IDs. All groups are loaded lazily,
+// kept for the TTL and reloaded when a lookup misses.
+type GroupDirectory struct {
+	client *Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	byID     map[int]SimpleGroup
+	byName   map[string]SimpleGroup
+	loadedAt time.Time
+}
+
+// NewGroupDirectory returns a GroupDirectory caching groups for ttl
+func NewGroupDirectory(c *Client, ttl time.Duration) *GroupDirectory {
+	return &GroupDirectory{client: c, ttl: ttl, now: time.Now}
+}
+
+// Resolve returns the group identified by ref
+func (d *GroupDirectory) Resolve(ref GroupRef) (SimpleGroup, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return SimpleGroup{}, err
+	}
+
+	if g, ok := d.lookup(ref); ok {
+		return g, nil
+	}
+
+	if d.now().Sub(d.loadedAt) >= missRefreshInterval {
+		if err := d.load(); err != nil {
+			return SimpleGroup{}, err
+		}
+
+		if g, ok := d.lookup(ref); ok {
+			return g, nil
+		}
+	}
+
+	return SimpleGroup{}, fmt.Errorf("%w: %v", ErrGroupNotFound, ref)
+}
+
+// ID returns the ID of the group name
+func (d *GroupDirectory) ID(name string) (int, error) {
+	g, err := d.Resolve(GroupByName(name))
+	return g.ID, err
+}
+
+// Name returns the name of the group ID
+func (d *GroupDirectory) Name(id int) (string, error) {
+	g, err := d.Resolve(GroupByID(id))
+	return g.Name, err
+}
+
+// Names resolves refs to names, e.g. for PostCreateRequest.Groups
+func (d *GroupDirectory) Names(refs ...GroupRef) ([]string, error) {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		g, err := d.Resolve(ref)
+
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, g.Name)
+	}
+
+	return names, nil
+}
+
+// All returns all groups
+func (d *GroupDirectory) All() ([]SimpleGroup, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return nil, err
+	}
+
+	groups := make([]SimpleGroup, 0, len(d.byID))
+	for _, g := range d.byID {
+		groups = append(groups, g)
+	}
+
+	return groups, nil
+}
+
+// Refresh reloads all groups
+func (d *GroupDirectory) Refresh() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.load()
+}
+
+// Get returns the group detail identified by ref
+func (d *GroupDirectory) Get(ref GroupRef) (*Group, *Response, error) {
+	g, err := d.Resolve(ref)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.client.Groups.Get(g.ID)
+}
+
+// AddUsers adds users to the group identified by ref
+func (d *GroupDirectory) AddUsers(ref GroupRef, userIDs ...int) (*Response, error) {
+	g, err := d.Resolve(ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d.client.GroupUsers.Create(g.ID, &GroupUserCreateRequest{UserIDs: userIDs})
+}
+
+// RemoveUsers removes users from the group identified by ref
+func (d *GroupDirectory) RemoveUsers(ref GroupRef, userIDs ...int) (*Response, error) {
+	g, err := d.Resolve(ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d.client.GroupUsers.Delete(g.ID, &GroupUserCreateRequest{UserIDs: userIDs})
+}
+
+func (d *GroupDirectory) lookup(ref GroupRef) (SimpleGroup, bool) {
+	if ref.ID != 0 {
+		g, ok := d.byID[ref.ID]
+		return g, ok
+	}
+
+	g, ok := d.byName[ref.Name]
+	return g, ok
+}
+
+func (d *GroupDirectory) loadIfExpired() error {
+	if d.byID != nil && d.now().Sub(d.loadedAt) < d.ttl {
+		return nil
+	}
+
+	return d.load()
+}
+
+// load pages through all groups
+func (d *GroupDirectory) load() error {
+	byID := make(map[int]SimpleGroup)
+	byName := make(map[string]SimpleGroup)
+
+	for page := 1; ; page++ {
+		groups, _, err := d.client.Groups.List(&GroupListOptions{Page: page, PerPage: groupListPerPage})
+
+		if err != nil {
+			return err
+		}
+
+		for _, g := range *groups {
+			byID[g.ID] = g
+			byName[g.Name] = g
+		}
+
+		if len(*groups) < groupListPerPage {
+			break
+		}
+	}
+
+	d.byID = byID
+	d.byName = byName
+	d.loadedAt = d.now()
+
+	return nil
+}
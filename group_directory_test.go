@@ -0,0 +1,127 @@
+package docbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// handleGroups serves groups in pages of groupListPerPage and counts list requests
+func handleGroups(t *testing.T, groups *[]SimpleGroup, calls *int) {
+	mux.HandleFunc("/groups", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		*calls++
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		start := (page - 1) * perPage
+		end := start + perPage
+		if start > len(*groups) {
+			start = len(*groups)
+		}
+		if end > len(*groups) {
+			end = len(*groups)
+		}
+
+		json.NewEncoder(w).Encode((*groups)[start:end])
+	})
+}
+
+func TestGroupDirectory_Resolve(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var groups []SimpleGroup
+	for i := 1; i <= groupListPerPage+1; i++ {
+		groups = append(groups, SimpleGroup{ID: i, Name: fmt.Sprintf("group%d", i)})
+	}
+
+	var calls int
+	handleGroups(t, &groups, &calls)
+
+	now := time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+	d := NewGroupDirectory(client, time.Hour)
+	d.now = func() time.Time { return now }
+
+	id, err := d.ID("group101")
+
+	if err != nil || id != 101 {
+		t.Errorf("ID returned %v, %v, want 101", id, err)
+	}
+
+	name, err := d.Name(1)
+
+	if err != nil || name != "group1" {
+		t.Errorf("Name returned %v, %v, want group1", name, err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Groups should be loaded once over 2 pages: %d calls", calls)
+	}
+
+	// a group created by someone else is found by refreshing on miss
+	groups = append(groups, SimpleGroup{ID: 200, Name: "new group"})
+	now = now.Add(time.Minute)
+
+	if id, err := d.ID("new group"); err != nil || id != 200 {
+		t.Errorf("ID returned %v, %v, want 200", id, err)
+	}
+
+	if calls != 4 {
+		t.Errorf("Groups should be reloaded on miss: %d calls", calls)
+	}
+
+	// unknown names do not reload within missRefreshInterval
+	if _, err := d.ID("unknown"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Error should be ErrGroupNotFound but is %v", err)
+	}
+
+	if calls != 4 {
+		t.Errorf("Groups should not be reloaded right after loading: %d calls", calls)
+	}
+
+	// expired after ttl
+	now = now.Add(2 * time.Hour)
+
+	names, err := d.Names(GroupByID(1), GroupByName("group2"))
+
+	if err != nil || len(names) != 2 || names[0] != "group1" || names[1] != "group2" {
+		t.Errorf("Names returned %v, %v", names, err)
+	}
+
+	if calls != 6 {
+		t.Errorf("Groups should be reloaded after ttl: %d calls", calls)
+	}
+}
+
+func TestGroupDirectory_AddUsers(t *testing.T) {
+	setup()
+	defer teardown()
+
+	groups := []SimpleGroup{{ID: 1, Name: "DocBase"}}
+	var calls int
+	handleGroups(t, &groups, &calls)
+
+	var got GroupUserCreateRequest
+	mux.HandleFunc("/groups/1/users", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{}`)
+	})
+
+	if _, err := client.GroupDirectory.AddUsers(GroupByName("DocBase"), 3, 4); err != nil {
+		t.Fatalf("AddUsers returned an error: %v", err)
+	}
+
+	if len(got.UserIDs) != 2 || got.UserIDs[0] != 3 || got.UserIDs[1] != 4 {
+		t.Errorf("AddUsers sent %+v", got)
+	}
+
+	if _, err := client.GroupDirectory.RemoveUsers(GroupByName("Unknown"), 3); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Error should be ErrGroupNotFound but is %v", err)
+	}
+}
@@ -3,14 +3,16 @@ package docbase
 import (
 	"fmt"
 	"net/http"
-	"net/url"
 )
 
 // GroupUserService implements interface with API /groups/:id/users endpoint.
 // See https://help.docbase.io/posts/45703#%E3%82%B0%E3%83%AB%E3%83%BC%E3%83%97
 type GroupUserService interface {
+	List(id int, opts *GroupUserListOptions) ([]SimpleUser, *Response, error)
 	Create(id int, groupUserCreateRequest *GroupUserCreateRequest) (*Response, error)
 	Delete(id int, groupUserCreateRequest *GroupUserCreateRequest) (*Response, error)
+	Diff(id int, userIDs []int) (*MembershipDiff, error)
+	Sync(id int, userIDs []int) (*MembershipDiff, error)
 }
 
 // groupUserService handles communication with API
@@ -22,46 +24,122 @@ type GroupUserCreateRequest struct {
 	UserIDs []int `json:"user_ids"`
 }
 
-func (c *groupUserService) Create(id int, groupUserCreateRequest *GroupUserCreateRequest) (*Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/groups/%d/users", id))
+// GroupUserListOptions identifies the page of group members.
+// The API returns all members in Group.Users, so pages are cut on the client.
+type GroupUserListOptions struct {
+	Page    int
+	PerPage int
+}
 
-	if err != nil {
-		return nil, err
-	}
+// MembershipDiff represents members to add to and remove from a group
+type MembershipDiff struct {
+	GroupID int
+	Add     []int
+	Remove  []int
+}
+
+// Empty reports whether the membership is already as desired
+func (d *MembershipDiff) Empty() bool {
+	return len(d.Add) == 0 && len(d.Remove) == 0
+}
 
-	req, err := c.client.NewRequest(http.MethodPost, u.String(), groupUserCreateRequest)
+// List returns a page of group members.
+// Response.Total is the number of members.
+func (c *groupUserService) List(id int, opts *GroupUserListOptions) ([]SimpleUser, *Response, error) {
+	group, resp, err := c.client.Groups.Get(id)
 
 	if err != nil {
-		return nil, err
+		return nil, resp, err
 	}
 
-	resp, err := c.client.Do(req, nil)
+	users := group.Users
+	resp.Total = len(users)
 
-	if err != nil {
-		return nil, err
+	if opts != nil && opts.PerPage > 0 {
+		page := opts.Page
+		if page < 1 {
+			page = 1
+		}
+
+		start, end := (page-1)*opts.PerPage, page*opts.PerPage
+		if start > len(users) {
+			start = len(users)
+		}
+		if end > len(users) {
+			end = len(users)
+		}
+
+		users = users[start:end]
 	}
 
-	return resp, err
+	return users, resp, nil
+}
+
+func (c *groupUserService) Create(id int, groupUserCreateRequest *GroupUserCreateRequest) (*Response, error) {
+	return c.client.call(http.MethodPost, fmt.Sprintf("/groups/%d/users", id), groupUserCreateRequest, nil)
 }
 
 func (c *groupUserService) Delete(id int, groupUserCreateRequest *GroupUserCreateRequest) (*Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/groups/%d/users", id))
+	return c.client.call(http.MethodDelete, fmt.Sprintf("/groups/%d/users", id), groupUserCreateRequest, nil)
+}
+
+// Diff compares the current members of the group with userIDs.
+// The group is fetched from the server, bypassing the response cache.
+func (c *groupUserService) Diff(id int, userIDs []int) (*MembershipDiff, error) {
+	group, _, err := GetUncachedJSON[Group](c.client, fmt.Sprintf("/groups/%d", id), nil)
 
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := c.client.NewRequest(http.MethodDelete, u.String(), groupUserCreateRequest)
+	current := make(map[int]bool, len(group.Users))
+	for _, u := range group.Users {
+		current[u.ID] = true
+	}
 
-	if err != nil {
-		return nil, err
+	desired := make(map[int]bool, len(userIDs))
+	diff := &MembershipDiff{GroupID: id}
+
+	for _, uid := range userIDs {
+		if desired[uid] {
+			continue
+		}
+
+		desired[uid] = true
+
+		if !current[uid] {
+			diff.Add = append(diff.Add, uid)
+		}
 	}
 
-	resp, err := c.client.Do(req, nil)
+	for _, u := range group.Users {
+		if !desired[u.ID] {
+			diff.Remove = append(diff.Remove, u.ID)
+		}
+	}
+
+	return diff, nil
+}
+
+// Sync makes userIDs the members of the group, sending only the needed Create and Delete calls
+func (c *groupUserService) Sync(id int, userIDs []int) (*MembershipDiff, error) {
+	diff, err := c.Diff(id, userIDs)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return resp, err
+	if len(diff.Add) > 0 {
+		if _, err := c.Create(id, &GroupUserCreateRequest{UserIDs: diff.Add}); err != nil {
+			return diff, err
+		}
+	}
+
+	if len(diff.Remove) > 0 {
+		if _, err := c.Delete(id, &GroupUserCreateRequest{UserIDs: diff.Remove}); err != nil {
+			return diff, err
+		}
+	}
+
+	return diff, nil
 }
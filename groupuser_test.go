@@ -1,9 +1,12 @@
 package docbase
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestGroupUserCli_Create(t *testing.T) {
@@ -61,3 +64,111 @@ func TestGroupUserCli_Delete(t *testing.T) {
 		t.Errorf("UserGroup Delete request code = %v, expected %v", resp.StatusCode, http.StatusOK)
 	}
 }
+
+// handleGroupMembers serves group 1 with members and applies member changes
+func handleGroupMembers(t *testing.T, members *[]int, requests *[]string) {
+	mux.HandleFunc("/groups/1", func(w http.ResponseWriter, r *http.Request) {
+		group := Group{ID: 1, Name: "DocBase"}
+		for _, id := range *members {
+			group.Users = append(group.Users, SimpleUser{ID: id, Name: fmt.Sprintf("user%d", id)})
+		}
+		json.NewEncoder(w).Encode(group)
+	})
+
+	mux.HandleFunc("/groups/1/users", func(w http.ResponseWriter, r *http.Request) {
+		req := &GroupUserCreateRequest{}
+		json.NewDecoder(r.Body).Decode(req)
+		*requests = append(*requests, fmt.Sprintf("%s %v", r.Method, req.UserIDs))
+		fmt.Fprint(w, `{}`)
+	})
+}
+
+func TestGroupUserCli_List(t *testing.T) {
+	setup()
+	defer teardown()
+
+	members := []int{1, 2, 3, 4, 5}
+	var requests []string
+	handleGroupMembers(t, &members, &requests)
+
+	users, resp, err := client.GroupUsers.List(1, &GroupUserListOptions{Page: 2, PerPage: 2})
+
+	if err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+
+	if len(users) != 2 || users[0].ID != 3 || users[1].ID != 4 {
+		t.Errorf("List returned %+v", users)
+	}
+
+	if resp.Total != 5 {
+		t.Errorf("Total is %d, want 5", resp.Total)
+	}
+
+	users, _, _ = client.GroupUsers.List(1, &GroupUserListOptions{Page: 4, PerPage: 2})
+
+	if len(users) != 0 {
+		t.Errorf("Page out of range returned %+v", users)
+	}
+}
+
+func TestGroupUserCli_Sync(t *testing.T) {
+	setup()
+	defer teardown()
+
+	members := []int{1, 2, 3}
+	var requests []string
+	handleGroupMembers(t, &members, &requests)
+
+	diff, err := client.GroupUsers.Sync(1, []int{2, 3, 4, 4, 5})
+
+	if err != nil {
+		t.Fatalf("Sync returned an error: %v", err)
+	}
+
+	want := &MembershipDiff{GroupID: 1, Add: []int{4, 5}, Remove: []int{1}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Sync returned %+v, want %+v", diff, want)
+	}
+
+	if got, want := requests, []string{"POST [4 5]", "DELETE [1]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sync sent %v, want %v", got, want)
+	}
+
+	requests = nil
+	diff, _ = client.GroupUsers.Sync(1, []int{3, 2, 1})
+
+	if !diff.Empty() || len(requests) != 0 {
+		t.Errorf("Sync without changes sent %v", requests)
+	}
+}
+
+func TestGroupUserCli_Sync_Cached(t *testing.T) {
+	setup()
+	defer teardown()
+
+	members := []int{1, 2}
+	var requests []string
+	handleGroupMembers(t, &members, &requests)
+
+	cached, err := NewClientWithOptions("dummyTeam", "dummyToken", WithBaseURL(server.URL), WithCache(NewMemoryCache(10), time.Minute))
+
+	if err != nil {
+		t.Fatalf("NewClientWithOptions returned an error: %v", err)
+	}
+
+	cached.GroupUsers.List(1, nil)
+
+	// another admin adds a member
+	members = append(members, 3)
+
+	diff, err := cached.GroupUsers.Sync(1, []int{1, 2})
+
+	if err != nil {
+		t.Fatalf("Sync returned an error: %v", err)
+	}
+
+	if want := []int{3}; !reflect.DeepEqual(diff.Remove, want) || len(requests) != 1 {
+		t.Errorf("Sync should remove the member added meanwhile: %+v, sent %v", diff, requests)
+	}
+}
@@ -0,0 +1,255 @@
+// Package confluence imports pages from Confluence HTML space exports, converting
+// them to markdown.
+package confluence
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/importer"
+)
+
+// HierarchyMode is how the parent pages of a page are kept
+type HierarchyMode string
+
+// Hierarchy modes
+const (
+	// HierarchyPrefix prefixes the title with the parent pages, like "[Guides/Setup] Title"
+	HierarchyPrefix HierarchyMode = "prefix"
+	// HierarchyTag keeps the parent pages as a tag, like "Guides/Setup"
+	HierarchyTag HierarchyMode = "tag"
+)
+
+// linkPattern matches the links to other pages of the export, the link in the first
+// group and the page file in the second
+var linkPattern = regexp.MustCompile(`(?:^|[\s(<"'])(([\w.%+~-]+\.html))`)
+
+// Importer creates DocBase posts from Confluence pages
+type Importer struct {
+	client *docbase.Client
+
+	// Hierarchy is how the parent pages are kept, HierarchyPrefix by default
+	Hierarchy HierarchyMode
+	// Tags are added to all imported posts, like the key of the space
+	Tags []string
+	// Authors maps the names Confluence shows to DocBase usernames
+	Authors map[string]string
+	// Scope and Groups are those of the imported posts, everyone by default
+	Scope  docbase.Scope
+	Groups []string
+	// IDMap is the file mapping page files to the imported posts. A page in it is
+	// not imported again.
+	IDMap string
+	// DryRun reports the pages to import without creating them
+	DryRun bool
+}
+
+// New returns an Importer of pages
+func New(c *docbase.Client) *Importer {
+	return &Importer{client: c, Hierarchy: HierarchyPrefix, Scope: docbase.ScopeEveryone}
+}
+
+// Run imports the pages in order. HTML exports carry the authors and creation dates
+// of some pages only; those are kept, given an owner or admin token. Links between
+// pages are rewritten once all are imported. A page failing to import is skipped
+// and reported, to be imported by the next run with the same IDMap.
+func (i *Importer) Run(pages []*Page) (*importer.Report, error) {
+	ids, err := importer.LoadIDMap(i.IDMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rh, err := importer.NewRehoster(i.client)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rh.Close()
+
+	staging, err := os.MkdirTemp("", "docbase-confluence-")
+
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	files := &attachmentFiles{dir: staging, staged: make(map[string]string)}
+	authors := importer.NewAuthors(i.client, i.Authors)
+	report := &importer.Report{DryRun: i.DryRun}
+
+	for _, p := range pages {
+		report.Entries = append(report.Entries, i.importPage(p, ids, authors, rh, files))
+	}
+
+	if !i.DryRun {
+		importer.UpdateLinks(i.client, report, linkPattern, ids, func(n int) (string, string) {
+			return pages[n].ID, pages[n].Body
+		})
+	}
+
+	report.Unmapped = authors.Unmapped()
+
+	if n := report.Count(importer.StatusFailed); n > 0 {
+		return report, fmt.Errorf("%d pages failed", n)
+	}
+
+	return report, nil
+}
+
+func (i *Importer) importPage(p *Page, ids *importer.IDMap, authors *importer.Authors, rh *importer.Rehoster, files *attachmentFiles) importer.Entry {
+	entry := importer.Entry{Source: p.ID, Title: p.Title, Status: importer.StatusImported}
+
+	if imported, ok := ids.Get(p.ID); ok {
+		entry.ID, entry.URL, entry.Status = imported.ID, imported.URL, importer.StatusSkipped
+		return entry
+	}
+
+	req := i.request(p)
+
+	var err error
+	if req.AuthorID, err = authors.AuthorID(p.Author); err != nil {
+		entry.Status = importer.StatusFailed
+		entry.Error = err.Error()
+		return entry
+	}
+
+	if i.DryRun {
+		entry.Status = importer.StatusPlanned
+		return entry
+	}
+
+	body, err := i.rehost(p, rh, files)
+
+	if err == nil {
+		req.Body = body
+
+		var created *docbase.Post
+		if created, _, err = i.client.Posts.Create(req); err == nil {
+			entry.ID, entry.URL = created.ID, created.URL
+			err = ids.Set(p.ID, importer.Imported{ID: created.ID, URL: created.URL})
+		}
+	}
+
+	if err != nil {
+		entry.Status = importer.StatusFailed
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+// request maps the page, but its body and author
+func (i *Importer) request(p *Page) *docbase.PostCreateRequest {
+	req := &docbase.PostCreateRequest{
+		Title:  p.Title,
+		Notice: docbase.Bool(false),
+		Tags:   append([]string{}, i.Tags...),
+		Scope:  i.Scope,
+		Groups: i.Groups,
+	}
+
+	if !p.CreatedAt.IsZero() {
+		req.PublishedAt = docbase.Time(p.CreatedAt)
+	}
+
+	if len(p.Ancestors) > 0 {
+		hierarchy := strings.Join(p.Ancestors, "/")
+
+		switch i.Hierarchy {
+		case HierarchyTag:
+			req.Tags = append(req.Tags, hierarchy)
+		default:
+			req.Title = "[" + hierarchy + "] " + p.Title
+		}
+	}
+
+	return req
+}
+
+// rehost uploads the attachments and images of the page found in the export
+func (i *Importer) rehost(p *Page, rh *importer.Rehoster, files *attachmentFiles) (string, error) {
+	paths := make(map[string]string)
+
+	for _, ref := range importer.Refs(p.Body) {
+		// links to other pages are rewritten, not uploaded
+		if u, err := url.Parse(ref); err == nil && strings.EqualFold(filepath.Ext(u.Path), ".html") {
+			continue
+		}
+
+		local := importer.LocalFile(p.Root, p.Path, ref)
+		if local == "" {
+			continue
+		}
+
+		staged, err := files.stage(local, p.Files[ref])
+
+		if err != nil {
+			return "", err
+		}
+
+		paths[ref] = staged
+	}
+
+	return rh.Rehost(p.Body, paths)
+}
+
+// attachmentFiles copies attachments to files of their original names, as uploads are
+// named after the file and exports keep attachments under their IDs
+type attachmentFiles struct {
+	dir    string
+	staged map[string]string
+}
+
+func (a *attachmentFiles) stage(path, name string) (string, error) {
+	if name == "" || name == filepath.Base(path) {
+		return path, nil
+	}
+
+	if p, ok := a.staged[path]; ok {
+		return p, nil
+	}
+
+	// a directory per file, as names may repeat
+	dir := filepath.Join(a.dir, strconv.Itoa(len(a.staged)+1))
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	p := filepath.Join(dir, filepath.Base(name))
+	if err := copyFile(path, p); err != nil {
+		return "", err
+	}
+
+	a.staged[path] = p
+	return p, nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
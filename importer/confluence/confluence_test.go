@@ -0,0 +1,220 @@
+package confluence
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/importer"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+const pageTemplate = `<!DOCTYPE html>
+<html>
+<head><title>Engineering : %TITLE%</title></head>
+<body>
+<div id="page">
+  <div id="main-header">
+    <div id="breadcrumb-section">
+      <ol id="breadcrumbs">
+        <li class="first"><span><a href="index.html">Engineering</a></span></li>
+        %CRUMBS%
+      </ol>
+    </div>
+    <h1 id="title-heading" class="pagetitle"><span id="title-text"> Engineering : %TITLE% </span></h1>
+  </div>
+  <div id="content" class="view">
+    <div class="page-metadata">
+      Created by <span class='author'> %AUTHOR%</span>%MODIFIED%
+    </div>
+    <div id="main-content" class="wiki-content group">%CONTENT%</div>
+    %ATTACHMENTS%
+  </div>
+</div>
+<div id="footer-logo"><a href="http://www.atlassian.com/">Atlassian</a></div>
+</body>
+</html>`
+
+var pages = []map[string]string{
+	{
+		"file":     "Guides_65537.html",
+		"%TITLE%":  "Guides",
+		"%AUTHOR%": "Taro Yamada", "%MODIFIED%": " on Apr 01, 2019",
+		"%CONTENT%": `<p>Start with <a href="Setup_65538.html">Setup</a>.</p>`,
+	},
+	{
+		"file":     "Setup_65538.html",
+		"%TITLE%":  "Setup",
+		"%CRUMBS%": `<li><span><a href="Guides_65537.html">Guides</a></span></li>`,
+		"%AUTHOR%": "Hanako Sato", "%MODIFIED%": ", last modified by Taro Yamada on May 02, 2019",
+		"%CONTENT%": `<h2>Steps</h2><p><img class="confluence-embedded-image" src="attachments/65538/98305.png" data-linked-resource-default-alias="diagram.png"></p>` +
+			`<p>Back to <a href="Guides_65537.html">Guides</a>, see <a href="https://example.com/">example</a>.</p>`,
+		"%ATTACHMENTS%": `<div class="pageSection group">
+      <div class="pageSectionHeader"><h2 id="attachments" class="pageSectionTitle">Attachments:</h2></div>
+      <div class="greybox" align="left">
+        <img src="images/icons/bullet_blue.gif" height="8" width="8" alt=""/>
+        <a href="attachments/65538/98305.png">diagram.png</a> (image/png)<br/>
+        <img src="images/icons/bullet_blue.gif" height="8" width="8" alt=""/>
+        <a href="attachments/65538/98306.pdf">runbook.pdf</a> (application/pdf)<br/>
+      </div>
+    </div>`,
+	},
+}
+
+func newExport(t *testing.T) string {
+	dir := t.TempDir()
+
+	for _, p := range pages {
+		page := pageTemplate
+		for _, key := range []string{"%TITLE%", "%CRUMBS%", "%AUTHOR%", "%MODIFIED%", "%CONTENT%", "%ATTACHMENTS%"} {
+			page = strings.ReplaceAll(page, key, p[key])
+		}
+
+		os.WriteFile(filepath.Join(dir, p["file"]), []byte(page), 0o644)
+	}
+
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte(`<html><body><h1>Engineering</h1><a href="Guides_65537.html">Guides</a></body></html>`), 0o644)
+	os.MkdirAll(filepath.Join(dir, "attachments", "65538"), 0o755)
+	os.WriteFile(filepath.Join(dir, "attachments", "65538", "98305.png"), []byte("\x89PNG diagram"), 0o644)
+	os.WriteFile(filepath.Join(dir, "attachments", "65538", "98306.pdf"), []byte("%PDF runbook"), 0o644)
+
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	loaded, err := LoadDir(newExport(t))
+
+	if err != nil {
+		t.Fatalf("LoadDir returned an error: %v", err)
+	}
+
+	if len(loaded) != 2 {
+		t.Fatalf("LoadDir returned %d pages", len(loaded))
+	}
+
+	guides, setup := loaded[0], loaded[1]
+
+	if guides.ID != "Guides_65537.html" || guides.Title != "Guides" || len(guides.Ancestors) != 0 || guides.Author != "Taro Yamada" || !guides.CreatedAt.Equal(time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("guides is %+v", guides)
+	}
+
+	if setup.Title != "Setup" || !reflect.DeepEqual(setup.Ancestors, []string{"Guides"}) || setup.Author != "Hanako Sato" || !setup.CreatedAt.IsZero() {
+		t.Errorf("setup is %+v", setup)
+	}
+
+	want := "## Steps\n\n![](attachments/65538/98305.png)\n\nBack to [Guides](Guides_65537.html), see [example](https://example.com/).\n\n" +
+		"## Attachments\n\n- [runbook.pdf](attachments/65538/98306.pdf)\n"
+	if setup.Body != want {
+		t.Errorf("setup body is\n%q, want\n%q", setup.Body, want)
+	}
+
+	if setup.Files["attachments/65538/98305.png"] != "diagram.png" || setup.Files["attachments/65538/98306.pdf"] != "runbook.pdf" {
+		t.Errorf("setup files are %+v", setup.Files)
+	}
+}
+
+func TestImporter_request(t *testing.T) {
+	p := &Page{Title: "Setup", Ancestors: []string{"Guides", "Backend"}}
+	i := &Importer{Tags: []string{"eng"}}
+
+	if req := i.request(p); req.Title != "[Guides/Backend] Setup" || !reflect.DeepEqual(req.Tags, []string{"eng"}) {
+		t.Errorf("prefix request is %+v", req)
+	}
+
+	i.Hierarchy = HierarchyTag
+	if req := i.request(p); req.Title != "Setup" || !reflect.DeepEqual(req.Tags, []string{"eng", "Guides/Backend"}) {
+		t.Errorf("tag request is %+v", req)
+	}
+}
+
+func TestImporter_Run(t *testing.T) {
+	loaded, _ := LoadDir(newExport(t))
+
+	s := fakeserver.NewTeam(t, fakeserver.Seed{Users: []docbase.User{{ID: 1, Username: "taro", Name: "Taro Yamada"}}})
+
+	i := New(s.Client)
+	i.Authors = map[string]string{"Taro Yamada": "taro"}
+	i.IDMap = filepath.Join(t.TempDir(), "ids.json")
+
+	report, err := i.Run(loaded)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v\n%+v", err, report)
+	}
+
+	if report.Count(importer.StatusImported) != 2 || !reflect.DeepEqual(report.Unmapped, []string{"Hanako Sato"}) {
+		t.Errorf("Report is %+v", report)
+	}
+
+	guides := s.Post(report.Entries[0].ID)
+	setup := s.Post(report.Entries[1].ID)
+
+	if guides.User.ID != 1 || !guides.CreatedAt.Equal(time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)) || guides.Body != "Start with [Setup]("+setup.URL+").\n" {
+		t.Errorf("guides is %+v", guides)
+	}
+
+	if setup.Title != "[Guides] Setup" || strings.Contains(setup.Body, "attachments/") || strings.Count(setup.Body, s.URL+"/uploads/") != 2 {
+		t.Errorf("setup attachments should be uploaded: %s", setup.Body)
+	}
+
+	if !strings.Contains(setup.Body, "Back to [Guides]("+guides.URL+"), see [example](https://example.com/).") {
+		t.Errorf("setup links should be rewritten: %s", setup.Body)
+	}
+
+	report, err = i.Run(loaded)
+
+	if err != nil || report.Count(importer.StatusSkipped) != 2 || len(s.Posts()) != 2 {
+		t.Errorf("Run returned %+v, %v", report, err)
+	}
+}
+
+func TestImporter_rehost_OutsideExport(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "secret.png"), []byte("secret"), 0o600)
+
+	s := fakeserver.New(t)
+	rh, err := importer.NewRehoster(s.Client(t))
+
+	if err != nil {
+		t.Fatalf("NewRehoster returned an error: %v", err)
+	}
+	defer rh.Close()
+
+	root := filepath.Join(dir, "export")
+	p := &Page{Body: "![](../secret.png)", Path: filepath.Join(root, "Setup_65538.html"), Root: root}
+	body, err := New(s.Client(t)).rehost(p, rh, &attachmentFiles{dir: t.TempDir(), staged: make(map[string]string)})
+
+	if err != nil || body != p.Body || len(s.Requests()) != 0 {
+		t.Errorf("rehost returned %q, %v and sent %v", body, err, s.Requests())
+	}
+}
+
+func TestAttachmentFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "98305.png")
+	os.WriteFile(path, []byte("diagram"), 0o644)
+
+	files := &attachmentFiles{dir: t.TempDir(), staged: make(map[string]string)}
+
+	staged, err := files.stage(path, "diagram.png")
+
+	if err != nil || filepath.Base(staged) != "diagram.png" {
+		t.Fatalf("stage returned %q, %v", staged, err)
+	}
+
+	if b, _ := os.ReadFile(staged); string(b) != "diagram" {
+		t.Errorf("staged file has %q", b)
+	}
+
+	if again, _ := files.stage(path, "diagram.png"); again != staged {
+		t.Errorf("stage should reuse %q, returned %q", staged, again)
+	}
+
+	if same, _ := files.stage(path, ""); same != path {
+		t.Errorf("stage without a name returned %q", same)
+	}
+}
@@ -0,0 +1,425 @@
+package confluence
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spaces    = regexp.MustCompile(`[ \t\r\n]+`)
+	blankRuns = regexp.MustCompile(`\n{3,}`)
+	// brush is the language of a code macro, like "brush: java; gutter: false"
+	brush = regexp.MustCompile(`brush:\s*([\w+#-]+)`)
+
+	markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+)
+
+// informationMacros map the classes of the panels of the info, note, warning and tip
+// macros to the names of their fenced blocks
+var informationMacros = map[string]string{
+	"confluence-information-macro-information": "info",
+	"confluence-information-macro-note":        "note",
+	"confluence-information-macro-warning":     "warning",
+	"confluence-information-macro-tip":         "tip",
+}
+
+// Markdown converts HTML to DocBase markdown: headings, emphasis, links, images, lists,
+// tables and code blocks. Code macros become fenced code blocks in their language and
+// other macros, like info panels, fenced blocks named after the macro.
+func Markdown(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	body := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Body })
+	if body == nil {
+		body = doc
+	}
+
+	return convert(body), nil
+}
+
+// convert converts the children of the node to markdown
+func convert(n *html.Node) string {
+	c := &converter{}
+	return strings.TrimSpace(blankRuns.ReplaceAllString(c.blocks(n), "\n\n")) + "\n"
+}
+
+type converter struct {
+	inTable bool
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Table: true, atom.Hr: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Dl: true, atom.Details: true, atom.Script: true, atom.Style: true,
+}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockElements[n.DataAtom]
+}
+
+// blocks converts the children of n, grouping the inline ones into paragraphs
+func (c *converter) blocks(n *html.Node) string {
+	var b, inline strings.Builder
+
+	flush := func() {
+		if s := strings.TrimSpace(inline.String()); s != "" {
+			b.WriteString(s + "\n\n")
+		}
+		inline.Reset()
+	}
+
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if isBlock(ch) {
+			flush()
+			b.WriteString(c.block(ch))
+			continue
+		}
+
+		inline.WriteString(c.inline(ch))
+	}
+
+	flush()
+	return b.String()
+}
+
+func (c *converter) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + strings.TrimSpace(c.inlines(n)) + "\n\n"
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Pre:
+		return fence(language(n), text(n))
+	case atom.Blockquote:
+		return prefixLines(strings.TrimSpace(c.blocks(n)), "> ") + "\n\n"
+	case atom.Table:
+		return c.table(n)
+	case atom.Hr:
+		return "---\n\n"
+	case atom.Script, atom.Style:
+		return ""
+	case atom.Div:
+		if md, ok := c.macro(n); ok {
+			return md
+		}
+	}
+
+	return c.blocks(n)
+}
+
+// macro converts the panels of Confluence macros
+func (c *converter) macro(n *html.Node) (string, bool) {
+	classes := strings.Fields(attr(n, "class"))
+
+	for _, class := range classes {
+		if name, ok := informationMacros[class]; ok {
+			body := find(n, hasClass("confluence-information-macro-body"))
+			if body == nil {
+				body = n
+			}
+
+			return fence(name, strings.TrimSpace(c.blocks(body))), true
+		}
+
+		switch class {
+		case "code":
+			if pre := find(n, func(p *html.Node) bool { return p.DataAtom == atom.Pre }); pre != nil {
+				return fence(language(pre), text(pre)), true
+			}
+		case "expand-container":
+			title := find(n, hasClass("expand-control-text"))
+			body := find(n, hasClass("expand-content"))
+
+			if body == nil {
+				body = n
+			}
+
+			md := strings.TrimSpace(c.blocks(body))
+			if title != nil {
+				md = strings.TrimSpace(c.inlines(title)) + "\n\n" + md
+			}
+
+			return fence("expand", md), true
+		case "panel":
+			return fence("panel", strings.TrimSpace(c.blocks(n))), true
+		}
+	}
+
+	if name := attr(n, "data-macro-name"); name != "" {
+		return fence(name, strings.TrimSpace(c.blocks(n))), true
+	}
+
+	return "", false
+}
+
+// inlines converts the children of n as inline content
+func (c *converter) inlines(n *html.Node) string {
+	var b strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		b.WriteString(c.inline(ch))
+	}
+
+	return b.String()
+}
+
+func (c *converter) inline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return markdownEscaper.Replace(spaces.ReplaceAllString(n.Data, " "))
+	}
+
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if c.inTable {
+			return "<br>"
+		}
+		return "  \n"
+	case atom.Strong, atom.B:
+		return wrap("**", c.inlines(n))
+	case atom.Em, atom.I:
+		return wrap("*", c.inlines(n))
+	case atom.Del, atom.S, atom.Strike:
+		return wrap("~~", c.inlines(n))
+	case atom.Code, atom.Tt:
+		return wrap("`", spaces.ReplaceAllString(text(n), " "))
+	case atom.A:
+		href := attr(n, "href")
+		label := strings.TrimSpace(c.inlines(n))
+
+		switch {
+		case href == "" || strings.HasPrefix(href, "#"):
+			return label
+		case label == "":
+			return "<" + href + ">"
+		}
+
+		return "[" + label + "](" + href + ")"
+	case atom.Img:
+		return "![" + attr(n, "alt") + "](" + attr(n, "src") + ")"
+	case atom.Script, atom.Style:
+		return ""
+	}
+
+	return c.inlines(n)
+}
+
+// wrap surrounds s with the marker, keeping the surrounding spaces outside
+func wrap(marker, s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+
+	return lead + marker + trimmed + marker + trail
+}
+
+func (c *converter) list(n *html.Node) string {
+	var b strings.Builder
+	i := 1
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", i)
+			i++
+		}
+
+		// items are tight, their paragraphs and nested lists on the following lines
+		item := strings.TrimSpace(blankRuns.ReplaceAllString(c.blocks(li), "\n\n"))
+		item = strings.ReplaceAll(item, "\n\n", "\n")
+
+		// an empty item has no line to put the marker before
+		if item == "" {
+			b.WriteString(marker + "\n")
+			continue
+		}
+
+		b.WriteString(marker + prefixLines(item, strings.Repeat(" ", len(marker)))[len(marker):] + "\n")
+	}
+
+	return b.String() + "\n"
+}
+
+func (c *converter) table(n *html.Node) string {
+	var rows [][]string
+	cols := 0
+
+	c.inTable = true
+	defer func() { c.inTable = false }()
+
+	walk(n, func(tr *html.Node) bool {
+		if tr.DataAtom != atom.Tr {
+			return true
+		}
+
+		var row []string
+		for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+				continue
+			}
+
+			row = append(row, c.cell(cell))
+		}
+
+		if len(row) > cols {
+			cols = len(row)
+		}
+
+		rows = append(rows, row)
+		return false
+	})
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+
+	return b.String() + "\n"
+}
+
+// cell converts a table cell to a single line
+func (c *converter) cell(n *html.Node) string {
+	md := strings.TrimSpace(blankRuns.ReplaceAllString(c.blocks(n), "\n\n"))
+	md = strings.ReplaceAll(md, "\n\n", "<br>")
+	md = strings.ReplaceAll(md, "\n", "<br>")
+
+	return strings.ReplaceAll(md, "|", `\|`)
+}
+
+// fence writes a fenced block, longer than the backquotes in s
+func fence(info, s string) string {
+	marker := "```"
+	for strings.Contains(s, marker) {
+		marker += "`"
+	}
+
+	return marker + info + "\n" + strings.TrimRight(s, "\n") + "\n" + marker + "\n\n"
+}
+
+// language returns the language of a code macro
+func language(pre *html.Node) string {
+	if m := brush.FindStringSubmatch(attr(pre, "data-syntaxhighlighter-params")); m != nil {
+		return m[1]
+	}
+
+	for _, class := range strings.Fields(attr(pre, "class")) {
+		if lang := strings.TrimPrefix(class, "language-"); lang != class {
+			return lang
+		}
+	}
+
+	return ""
+}
+
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" || prefix != strings.Repeat(" ", len(prefix)) {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// text returns the text of the node and its descendants as is
+func text(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(d *html.Node) bool {
+		if d.Type == html.TextNode {
+			b.WriteString(d.Data)
+		}
+		if d.DataAtom == atom.Br {
+			b.WriteString("\n")
+		}
+		return true
+	})
+
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func hasClass(class string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		for _, c := range strings.Fields(attr(n, "class")) {
+			if c == class {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func hasID(id string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return attr(n, "id") == id }
+}
+
+// walk calls fn on n and its descendants in document order, not descending
+// into the nodes fn returns false for
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		walk(ch, fn)
+	}
+}
+
+// find returns the first descendant of n matching, n excluded
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if match(ch) {
+			return ch
+		}
+
+		if found := find(ch, match); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
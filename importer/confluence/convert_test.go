@@ -0,0 +1,74 @@
+package confluence
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	cases := []struct {
+		name, html, want string
+	}{
+		{
+			"headings and inline",
+			`<h1>Setup</h1><p>Use <strong>make</strong>, <em>not</em> <code>go run *.go</code>.<br/>See <a href="Other_2.html">other</a> and <a href="#top">top</a>.</p><h3>snake_case</h3>`,
+			"# Setup\n\nUse **make**, *not* `go run *.go`.  \nSee [other](Other_2.html) and top.\n\n### snake\\_case\n",
+		},
+		{
+			"lists",
+			`<ul><li>one<ul><li>nested</li></ul></li><li><p>two</p></li></ul><ol><li>first</li><li>second</li></ol>`,
+			"- one\n  - nested\n- two\n\n1. first\n2. second\n",
+		},
+		{
+			"empty list item",
+			`<ul><li></li></ul>`,
+			"-\n",
+		},
+		{
+			"blank list item",
+			`<ol><li> </li><li>second</li></ol>`,
+			"1. \n2. second\n",
+		},
+		{
+			"table",
+			`<div class="table-wrap"><table class="confluenceTable"><tbody><tr><th>Name</th><th>Value</th></tr><tr><td>a|b</td><td><p>x</p><p>y</p></td></tr><tr><td>only</td></tr></tbody></table></div>`,
+			"| Name | Value |\n| --- | --- |\n| a\\|b | x<br>y |\n| only |  |\n",
+		},
+		{
+			"code macro",
+			`<div class="code panel pdl"><div class="codeContent panelContent pdl"><pre class="syntaxhighlighter-pre" data-syntaxhighlighter-params="brush: java; gutter: false; theme: Confluence">class A {
+  // ` + "```" + `
+}</pre></div></div>`,
+			"````java\nclass A {\n  // ```\n}\n````\n",
+		},
+		{
+			"information macro",
+			`<div class="confluence-information-macro confluence-information-macro-warning"><span class="aui-icon confluence-information-macro-icon"></span><div class="confluence-information-macro-body"><p>Do <strong>not</strong> deploy on Fridays.</p></div></div>`,
+			"```warning\nDo **not** deploy on Fridays.\n```\n",
+		},
+		{
+			"other macros",
+			`<div class="expand-container"><div class="expand-control"><span class="expand-control-text">Details</span></div><div class="expand-content"><p>hidden</p></div></div><div data-macro-name="jira">PROJ-1</div>`,
+			"```expand\nDetails\n\nhidden\n```\n\n```jira\nPROJ-1\n```\n",
+		},
+		{
+			"images and quotes",
+			`<blockquote><p>quoted</p><p>twice</p></blockquote><p><span class="confluence-embedded-file-wrapper"><img class="confluence-embedded-image" src="attachments/1/3.png" alt="diagram"></span></p><hr/>`,
+			"> quoted\n> \n> twice\n\n![diagram](attachments/1/3.png)\n\n---\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Markdown(strings.NewReader(c.html))
+
+			if err != nil {
+				t.Fatalf("Markdown returned an error: %v", err)
+			}
+
+			if got != c.want {
+				t.Errorf("Markdown returned\n%q, want\n%q", got, c.want)
+			}
+		})
+	}
+}
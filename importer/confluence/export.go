@@ -0,0 +1,222 @@
+package confluence
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/hayashiki/docbase-go/importer"
+)
+
+// ErrNotPage is returned by ParsePage for HTML files without page content, like the
+// index of the space
+var ErrNotPage = errors.New("not a Confluence page")
+
+// created matches the metadata of pages, like "Created by Taro Yamada on Apr 01, 2019"
+// or "Created by Taro Yamada, last modified by Hanako on Apr 02, 2019"
+var created = regexp.MustCompile(`Created by (.+?)(?:, last modified| on ([A-Z][a-z]{2} \d{1,2}, \d{4})|$)`)
+
+// Page is a page of a Confluence HTML space export
+type Page struct {
+	// ID is the name of the page file, like "Design_65538.html", which the links
+	// of other pages refer to
+	ID    string
+	Title string
+	// Ancestors are the titles of the parent pages, from the top
+	Ancestors []string
+	Author    string
+	CreatedAt time.Time
+	// Body is the markdown of the page, with the attachments not shown in the
+	// content listed at the end
+	Body string
+	// Files maps the refs of the body to attachments to their original names, as
+	// exports keep attachments under their IDs
+	Files map[string]string
+	// Path is the page file, attachments are looked up next to it
+	Path string
+	// Root is the export directory. Attachments outside of it are not uploaded.
+	Root string
+}
+
+// ParsePage reads a page file of an export
+func ParsePage(r io.Reader) (*Page, error) {
+	doc, err := html.Parse(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	content := find(doc, hasID("main-content"))
+	if content == nil {
+		return nil, ErrNotPage
+	}
+
+	p := &Page{Files: make(map[string]string)}
+	space := ""
+
+	if crumbs := find(doc, hasID("breadcrumbs")); crumbs != nil {
+		walk(crumbs, func(n *html.Node) bool {
+			if n.DataAtom != atom.A {
+				return true
+			}
+
+			if title := strings.TrimSpace(spaces.ReplaceAllString(text(n), " ")); path.Base(attr(n, "href")) == "index.html" {
+				space = title
+			} else if title != "" {
+				p.Ancestors = append(p.Ancestors, title)
+			}
+
+			return false
+		})
+	}
+
+	title := find(doc, hasID("title-text"))
+	if title == nil {
+		title = find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title })
+	}
+
+	if title != nil {
+		p.Title = strings.TrimSpace(spaces.ReplaceAllString(text(title), " "))
+		if space != "" {
+			p.Title = strings.TrimPrefix(p.Title, space+" : ")
+		}
+	}
+
+	if meta := find(doc, hasClass("page-metadata")); meta != nil {
+		if m := created.FindStringSubmatch(strings.TrimSpace(spaces.ReplaceAllString(text(meta), " "))); m != nil {
+			p.Author = strings.TrimSpace(m[1])
+			p.CreatedAt, _ = time.Parse("Jan 2, 2006", m[2])
+		}
+	}
+
+	walk(content, func(n *html.Node) bool {
+		if alias := attr(n, "data-linked-resource-default-alias"); alias != "" && n.DataAtom == atom.Img {
+			p.Files[attr(n, "src")] = alias
+		}
+		return true
+	})
+
+	p.Body = convert(content)
+	p.Body += attachments(doc, p)
+
+	return p, nil
+}
+
+// attachments lists the attachments of the page not shown in its content, and
+// records the names of all of them
+func attachments(doc *html.Node, p *Page) string {
+	heading := find(doc, hasID("attachments"))
+	if heading == nil {
+		return ""
+	}
+
+	section := heading.Parent
+	for section != nil && !hasClass("pageSection")(section) {
+		section = section.Parent
+	}
+
+	if section == nil {
+		return ""
+	}
+
+	shown := make(map[string]bool)
+	for _, ref := range importer.Refs(p.Body) {
+		shown[ref] = true
+	}
+
+	var b strings.Builder
+	walk(section, func(n *html.Node) bool {
+		href := attr(n, "href")
+
+		if n.DataAtom != atom.A || !strings.HasPrefix(href, "attachments/") {
+			return true
+		}
+
+		name := strings.TrimSpace(text(n))
+		if name == "" {
+			name = path.Base(href)
+		}
+
+		p.Files[href] = name
+		if !shown[href] {
+			shown[href] = true
+			b.WriteString("- [" + markdownEscaper.Replace(name) + "](" + href + ")\n")
+		}
+
+		return false
+	})
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	return "\n## Attachments\n\n" + b.String()
+}
+
+// LoadDir reads the pages of an export directory, parents before their children
+func LoadDir(dir string) ([]*Page, error) {
+	var pages []*Page
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != dir && (d.Name() == "attachments" || d.Name() == "images" || d.Name() == "styles") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.ToLower(filepath.Ext(p)) != ".html" {
+			return nil
+		}
+
+		f, err := os.Open(p)
+
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		page, err := ParsePage(f)
+
+		if errors.Is(err, ErrNotPage) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		page.ID = filepath.Base(p)
+		page.Path, page.Root = p, dir
+		if page.Title == "" {
+			page.Title = strings.TrimSuffix(page.ID, filepath.Ext(page.ID))
+		}
+
+		pages = append(pages, page)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		return len(pages[i].Ancestors) < len(pages[j].Ancestors)
+	})
+
+	return pages, nil
+}
@@ -0,0 +1,251 @@
+// Package esa imports posts from esa.io markdown exports.
+package esa
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/importer"
+)
+
+// CategoryMode is how the category of a post is kept
+type CategoryMode string
+
+// Category modes
+const (
+	// CategoryTag keeps the category as a tag, like "dev/design"
+	CategoryTag CategoryMode = "tag"
+	// CategoryTags keeps each level of the category as a tag, like "dev" and "design"
+	CategoryTags CategoryMode = "tags"
+	// CategoryPrefix prefixes the title with the category, like "[dev/design] Title"
+	CategoryPrefix CategoryMode = "prefix"
+)
+
+// ImageHosts are the hosts esa keeps the uploaded files on
+var ImageHosts = []string{"img.esa.io", "files.esa.io"}
+
+// Importer creates DocBase posts from esa posts
+type Importer struct {
+	client *docbase.Client
+
+	// Team is the esa team whose links, like https://team.esa.io/posts/123, are
+	// rewritten to the imported posts. Relative links to /posts/123 are always rewritten.
+	Team string
+	// Category is how categories are kept, CategoryTag by default
+	Category CategoryMode
+	// Authors maps esa screen names to DocBase usernames, for users named differently
+	Authors map[string]string
+	// Scope and Groups are those of the imported posts, everyone by default
+	Scope  docbase.Scope
+	Groups []string
+	// ImageHosts are the hosts of the files to upload again, ImageHosts by default.
+	// Relative images found next to the exported posts are uploaded too.
+	ImageHosts []string
+	// IDMap is the file mapping esa post numbers to the imported posts. A post in it
+	// is not imported again.
+	IDMap string
+	// HTTPClient downloads the images, http.DefaultClient by default
+	HTTPClient *http.Client
+	// DryRun reports the posts to import without creating them
+	DryRun bool
+}
+
+// New returns an Importer of the posts of the esa team
+func New(c *docbase.Client, team string) *Importer {
+	return &Importer{client: c, Team: team, Category: CategoryTag, Scope: docbase.ScopeEveryone, ImageHosts: ImageHosts}
+}
+
+// Run imports the posts with their comments in order, posted as the members mapped
+// from their esa screen names at their original times, for which the token must be an
+// owner's or admin's. Links between posts are rewritten once all are imported. A post
+// which fails stays out of the IDMap, so running again imports just the failed posts.
+func (i *Importer) Run(posts []*Post) (*importer.Report, error) {
+	ids, err := importer.LoadIDMap(i.IDMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rh, err := importer.NewRehoster(i.client)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rh.Close()
+
+	if i.HTTPClient != nil {
+		rh.HTTPClient = i.HTTPClient
+	}
+
+	authors := importer.NewAuthors(i.client, i.Authors)
+	report := &importer.Report{DryRun: i.DryRun}
+
+	for _, p := range posts {
+		report.Entries = append(report.Entries, i.importPost(p, ids, authors, rh))
+	}
+
+	if !i.DryRun {
+		importer.UpdateLinks(i.client, report, i.linkPattern(), ids, func(n int) (string, string) {
+			return strconv.Itoa(posts[n].Number), posts[n].Body
+		})
+	}
+
+	report.Unmapped = authors.Unmapped()
+
+	if n := report.Count(importer.StatusFailed); n > 0 {
+		return report, fmt.Errorf("%d posts failed", n)
+	}
+
+	return report, nil
+}
+
+func (i *Importer) importPost(p *Post, ids *importer.IDMap, authors *importer.Authors, rh *importer.Rehoster) importer.Entry {
+	key := strconv.Itoa(p.Number)
+	entry := importer.Entry{Source: "#" + key, Title: p.Title, Status: importer.StatusImported}
+
+	if imported, ok := ids.Get(key); ok && imported.Comments >= len(p.Comments) {
+		entry.ID, entry.URL, entry.Status = imported.ID, imported.URL, importer.StatusSkipped
+		return entry
+	}
+
+	req := i.request(p)
+
+	var err error
+	if req.AuthorID, err = authors.AuthorID(p.CreatedBy); err != nil {
+		entry.Status = importer.StatusFailed
+		entry.Error = err.Error()
+		return entry
+	}
+
+	if i.DryRun {
+		entry.Status = importer.StatusPlanned
+		return entry
+	}
+
+	err = i.create(p, req, ids, authors, rh)
+
+	if imported, ok := ids.Get(key); ok {
+		entry.ID, entry.URL = imported.ID, imported.URL
+	}
+
+	if err != nil {
+		entry.Status = importer.StatusFailed
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+// request maps the post, but its body and author
+func (i *Importer) request(p *Post) *docbase.PostCreateRequest {
+	req := &docbase.PostCreateRequest{
+		Title:  p.Title,
+		Draft:  docbase.Bool(p.WIP),
+		Notice: docbase.Bool(false),
+		Tags:   append([]string(nil), p.Tags...),
+		Scope:  i.Scope,
+		Groups: i.Groups,
+	}
+
+	if !p.CreatedAt.IsZero() {
+		req.PublishedAt = docbase.Time(p.CreatedAt)
+	}
+
+	if p.Category != "" {
+		switch i.Category {
+		case CategoryPrefix:
+			req.Title = "[" + p.Category + "] " + p.Title
+		case CategoryTags:
+			req.Tags = append(req.Tags, strings.Split(p.Category, "/")...)
+		default:
+			req.Tags = append(req.Tags, p.Category)
+		}
+	}
+
+	return req
+}
+
+// create creates the post unless a previous run did, and the comments not created yet
+func (i *Importer) create(p *Post, req *docbase.PostCreateRequest, ids *importer.IDMap, authors *importer.Authors, rh *importer.Rehoster) error {
+	key := strconv.Itoa(p.Number)
+
+	if _, ok := ids.Get(key); !ok {
+		body, err := i.rehost(p, p.Body, rh)
+
+		if err != nil {
+			return err
+		}
+		req.Body = body
+
+		created, _, err := i.client.Posts.Create(req)
+
+		if err != nil {
+			return err
+		}
+
+		if err := ids.Set(key, importer.Imported{ID: created.ID, URL: created.URL}); err != nil {
+			return err
+		}
+	}
+
+	return importer.CreateComments(i.client, ids, key, len(p.Comments), func(n int) (*docbase.CommentCreateRequest, error) {
+		c := p.Comments[n]
+		body, err := i.rehost(p, c.Body, rh)
+
+		if err != nil {
+			return nil, err
+		}
+
+		authorID, err := authors.AuthorID(c.CreatedBy)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req := &docbase.CommentCreateRequest{Body: body, Notice: docbase.Bool(false), AuthorID: authorID}
+		if !c.CreatedAt.IsZero() {
+			req.PublishedAt = docbase.Time(c.CreatedAt)
+		}
+
+		return req, nil
+	})
+}
+
+// rehost uploads the esa files and the relative images next to the post
+func (i *Importer) rehost(p *Post, body string, rh *importer.Rehoster) (string, error) {
+	files := make(map[string]string)
+
+	for _, ref := range importer.Refs(body) {
+		if importer.HasHost(ref, i.ImageHosts) {
+			path, err := rh.Download(ref)
+
+			if err != nil {
+				return "", err
+			}
+
+			files[ref] = path
+			continue
+		}
+
+		if path := importer.LocalFile(p.Root, p.Path, ref); path != "" {
+			files[ref] = path
+		}
+	}
+
+	return rh.Rehost(body, files)
+}
+
+// linkPattern matches links to posts of the team and relative links, the link in the
+// first group and the number in the second
+func (i *Importer) linkPattern() *regexp.Regexp {
+	host := ""
+	if i.Team != "" {
+		host = `(?:https?://` + regexp.QuoteMeta(i.Team) + `\.esa\.io)?`
+	}
+
+	return regexp.MustCompile(`(?:^|[\s(<"'])(` + host + `/posts/(\d+))`)
+}
@@ -0,0 +1,226 @@
+package esa
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go/importer"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+const designPost = `---
+title: "Design review"
+category: dev/design
+tags: "design, review"
+wip: false
+created_by: taro_esa
+created_at: 2019-04-01 10:00:00 +0900
+number: 1
+---
+
+# Review
+![diagram](%IMAGE%)
+![local](images/local.png)
+See https://docs.esa.io/posts/2#comment-3 and [the draft](/posts/2).
+`
+
+const draftPost = `---
+title: Draft
+tags:
+  - draft
+published: false
+created_by: hanako
+created_at: 2019-04-02 09:30:00 +0900
+comments:
+  - body: Looks good
+    created_by: taro_esa
+    created_at: 2019-04-03 12:00:00 +0900
+---
+Back to [the review](https://docs.esa.io/posts/1).
+`
+
+const ghostPost = `---
+title: Memo
+created_by: ghost
+number: 3
+---
+memo
+`
+
+var (
+	image  = []byte("\x89PNG image")
+	local  = []byte("\x89PNG local")
+	tokyo  = time.FixedZone("", 9*60*60)
+	posted = time.Date(2019, 4, 1, 10, 0, 0, 0, tokyo)
+)
+
+func newExport(t *testing.T) (string, string) {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(image)
+	}))
+	t.Cleanup(images.Close)
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "dev", "design", "images"), 0o755)
+
+	imageURL := images.URL + "/uploads/production/attachments/1/diagram.png"
+	os.WriteFile(filepath.Join(dir, "dev", "design", "1.md"), []byte(strings.Replace(designPost, "%IMAGE%", imageURL, 1)), 0o644)
+	os.WriteFile(filepath.Join(dir, "dev", "design", "images", "local.png"), local, 0o644)
+	os.WriteFile(filepath.Join(dir, "2.md"), []byte(draftPost), 0o644)
+	os.WriteFile(filepath.Join(dir, "memo.md"), []byte(ghostPost), 0o644)
+
+	return dir, images.URL
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse(strings.NewReader(designPost))
+
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+
+	if p.Number != 1 || p.Title != "Design review" || p.Category != "dev/design" || p.WIP || p.CreatedBy != "taro_esa" {
+		t.Errorf("Post is %+v", p)
+	}
+
+	if !reflect.DeepEqual(p.Tags, []string{"design", "review"}) || !p.CreatedAt.Equal(posted) || !strings.HasPrefix(p.Body, "# Review") {
+		t.Errorf("Post is %+v", p)
+	}
+
+	d, err := Parse(strings.NewReader(draftPost))
+
+	if err != nil || !d.WIP || len(d.Tags) != 1 || len(d.Comments) != 1 || d.Comments[0].CreatedBy != "taro_esa" {
+		t.Errorf("Parse returned %+v, %v", d, err)
+	}
+
+	for _, invalid := range []string{"no front matter", "---\ntitle: x\n", "---\ncreated_at: yesterday\n---\n"} {
+		if _, err := Parse(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Parse should fail for %q", invalid)
+		}
+	}
+}
+
+func TestImporter_request(t *testing.T) {
+	p := &Post{Title: "Design review", Category: "dev/design", Tags: []string{"design"}}
+
+	tests := []struct {
+		mode  CategoryMode
+		title string
+		tags  []string
+	}{
+		{CategoryTag, "Design review", []string{"design", "dev/design"}},
+		{CategoryTags, "Design review", []string{"design", "dev", "design"}},
+		{CategoryPrefix, "[dev/design] Design review", []string{"design"}},
+	}
+
+	for _, tt := range tests {
+		i := &Importer{Category: tt.mode}
+		req := i.request(p)
+
+		if req.Title != tt.title || !reflect.DeepEqual(req.Tags, tt.tags) {
+			t.Errorf("%s: request is %+v", tt.mode, req)
+		}
+	}
+}
+
+func TestImporter_Run(t *testing.T) {
+	dir, imageHost := newExport(t)
+	posts, err := LoadDir(dir)
+
+	if err != nil {
+		t.Fatalf("LoadDir returned an error: %v", err)
+	}
+
+	if len(posts) != 3 || posts[1].Number != 2 || posts[1].Path != filepath.Join(dir, "2.md") {
+		t.Fatalf("LoadDir returned %+v", posts)
+	}
+
+	s := fakeserver.NewTeam(t, fakeserver.Seed{})
+	i := New(s.Client, "docs")
+	i.Authors = map[string]string{"taro_esa": "taro"}
+	u, _ := url.Parse(imageHost)
+	i.ImageHosts = []string{u.Hostname()}
+	i.IDMap = filepath.Join(t.TempDir(), "ids.json")
+
+	report, err := i.Run(posts)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v\n%+v", err, report)
+	}
+
+	if report.Count(importer.StatusImported) != 3 || !reflect.DeepEqual(report.Unmapped, []string{"ghost"}) {
+		t.Errorf("Report is %+v", report)
+	}
+
+	design := s.Post(report.Entries[0].ID)
+	draft := s.Post(report.Entries[1].ID)
+
+	if design.User.ID != 1 || !design.CreatedAt.Equal(posted) || design.Draft || len(design.Tags) != 3 || design.Tags[2].Name != "dev/design" {
+		t.Errorf("design is %+v", design)
+	}
+
+	if strings.Contains(design.Body, imageHost) || strings.Contains(design.Body, "images/local.png") || strings.Count(design.Body, s.URL+"/uploads/") != 2 {
+		t.Errorf("design images should be uploaded: %s", design.Body)
+	}
+
+	if !strings.Contains(design.Body, draft.URL+"#comment-3") || !strings.Contains(design.Body, "[the draft]("+draft.URL+")") {
+		t.Errorf("design links should be rewritten: %s", design.Body)
+	}
+
+	if !draft.Draft || draft.User.ID != 2 || !strings.Contains(draft.Body, design.URL) {
+		t.Errorf("draft is %+v", draft)
+	}
+
+	if len(draft.Comments) != 1 || draft.Comments[0].SimpleUser.ID != 1 || !draft.Comments[0].CreatedAt.Equal(time.Date(2019, 4, 3, 12, 0, 0, 0, tokyo)) {
+		t.Errorf("draft comments are %+v", draft.Comments)
+	}
+
+	var uploaded [][]byte
+	for _, ref := range importer.Refs(design.Body) {
+		if strings.HasPrefix(ref, s.URL+"/uploads/") {
+			uploaded = append(uploaded, s.Attachment(strings.TrimPrefix(ref, s.URL+"/uploads/")))
+		}
+	}
+
+	if len(uploaded) != 2 || !bytes.Equal(uploaded[0], image) || !bytes.Equal(uploaded[1], local) {
+		t.Errorf("uploaded files are %q", uploaded)
+	}
+
+	// a second run skips the imported posts
+	report, err = i.Run(posts)
+
+	if err != nil || report.Count(importer.StatusSkipped) != 3 || len(s.Posts()) != 3 {
+		t.Errorf("Run returned %+v, %v", report, err)
+	}
+}
+
+func TestImporter_DryRun(t *testing.T) {
+	dir, _ := newExport(t)
+	posts, _ := LoadDir(dir)
+
+	s := fakeserver.NewTeam(t, fakeserver.Seed{})
+	i := New(s.Client, "docs")
+	i.DryRun = true
+
+	report, err := i.Run(posts)
+
+	if err != nil || report.Count(importer.StatusPlanned) != 3 {
+		t.Fatalf("Run returned %+v, %v", report, err)
+	}
+
+	if !reflect.DeepEqual(report.Unmapped, []string{"ghost", "taro_esa"}) {
+		t.Errorf("Unmapped is %v", report.Unmapped)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("DryRun should not change anything: %v", reqs)
+	}
+}
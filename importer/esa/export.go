@@ -0,0 +1,234 @@
+package esa
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// timeLayouts are the layouts of times in front matter, as exported and as written by YAML
+var timeLayouts = []string{
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	time.RFC3339,
+}
+
+// Post is a post of an esa export
+type Post struct {
+	Number    int
+	Title     string
+	Category  string
+	Tags      []string
+	WIP       bool
+	CreatedBy string
+	CreatedAt time.Time
+	Body      string
+	// Comments are not in markdown exports, they are read when the front matter lists them
+	Comments []Comment
+	// Path is the file the post was read from, relative images are looked up next to it
+	Path string
+	// Root is the export directory. Relative images outside of it are not uploaded.
+	Root string
+}
+
+// Comment is a comment on a post
+type Comment struct {
+	Body      string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+type frontMatter struct {
+	Title     string      `yaml:"title"`
+	Category  string      `yaml:"category"`
+	Tags      interface{} `yaml:"tags"`
+	WIP       *bool       `yaml:"wip"`
+	Published *bool       `yaml:"published"`
+	CreatedBy string      `yaml:"created_by"`
+	CreatedAt string      `yaml:"created_at"`
+	Number    int         `yaml:"number"`
+	Comments  []struct {
+		Body      string `yaml:"body"`
+		CreatedBy string `yaml:"created_by"`
+		CreatedAt string `yaml:"created_at"`
+	} `yaml:"comments"`
+}
+
+// Parse reads a post, a markdown body after front matter like
+//
+//	---
+//	title: "Design review"
+//	category: dev/design
+//	tags: "design, review"
+//	wip: false
+//	created_by: taro
+//	created_at: 2019-04-01 10:00:00 +0900
+//	number: 123
+//	---
+func Parse(r io.Reader) (*Post, error) {
+	br := bufio.NewReader(r)
+	first, err := br.ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if strings.TrimSpace(first) != "---" {
+		return nil, fmt.Errorf("front matter is missing")
+	}
+
+	var head bytes.Buffer
+	for {
+		line, err := br.ReadString('\n')
+
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("front matter is not closed")
+		}
+
+		head.WriteString(line)
+	}
+
+	fm := &frontMatter{}
+	if err := yaml.Unmarshal(head.Bytes(), fm); err != nil {
+		return nil, fmt.Errorf("reading front matter: %w", err)
+	}
+
+	body, err := io.ReadAll(br)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Post{
+		Number:    fm.Number,
+		Title:     fm.Title,
+		Category:  strings.Trim(fm.Category, "/"),
+		CreatedBy: fm.CreatedBy,
+		Body:      strings.TrimPrefix(string(body), "\n"),
+	}
+
+	// older exports have published instead of wip
+	if fm.WIP != nil {
+		p.WIP = *fm.WIP
+	} else if fm.Published != nil {
+		p.WIP = !*fm.Published
+	}
+
+	if p.Tags, err = parseTags(fm.Tags); err != nil {
+		return nil, err
+	}
+
+	if p.CreatedAt, err = parseTime(fm.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	for _, c := range fm.Comments {
+		created, err := parseTime(c.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.Comments = append(p.Comments, Comment{Body: c.Body, CreatedBy: c.CreatedBy, CreatedAt: created})
+	}
+
+	return p, nil
+}
+
+// parseTags reads tags as a list or as a comma separated string
+func parseTags(v interface{}) ([]string, error) {
+	var raw []string
+
+	switch t := v.(type) {
+	case nil:
+	case string:
+		raw = strings.Split(t, ",")
+	case []interface{}:
+		for _, tag := range t {
+			raw = append(raw, fmt.Sprint(tag))
+		}
+	default:
+		return nil, fmt.Errorf("invalid tags %v", v)
+	}
+
+	var tags []string
+	for _, tag := range raw {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// LoadDir reads the .md files of an export directory, ordered by number.
+// Files without a number in their front matter are numbered by their name, like "123.md".
+func LoadDir(dir string) ([]*Post, error) {
+	var posts []*Post
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".md" {
+			return err
+		}
+
+		f, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		p, err := Parse(f)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if p.Number == 0 {
+			p.Number, _ = strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".md"))
+		}
+
+		if p.Number == 0 {
+			return fmt.Errorf("%s: number is missing", path)
+		}
+
+		p.Path, p.Root = path, dir
+		posts = append(posts, p)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Number < posts[j].Number })
+	return posts, nil
+}
@@ -0,0 +1,256 @@
+// Package importer has what the importers from other services share: the report,
+// the file mapping source IDs to created posts, authors and re-hosting of images.
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/report"
+	"gopkg.in/yaml.v3"
+)
+
+// Statuses of entries
+const (
+	StatusImported = "imported"
+	StatusSkipped  = "skipped" // imported by a previous run
+	StatusPlanned  = "planned" // in a dry run
+	StatusFailed   = "failed"
+)
+
+// Entry is the import of a source document
+type Entry struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	ID     int    `json:"id,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the result of an import
+type Report struct {
+	DryRun  bool    `json:"dry_run"`
+	Entries []Entry `json:"entries"`
+	// Unmapped lists the authors without a user in the team. Their documents
+	// are posted as the owner of the token.
+	Unmapped []string `json:"unmapped"`
+}
+
+// Count returns the number of entries of the status
+func (r *Report) Count(status string) int {
+	return report.Count(r.Entries, func(e Entry) bool { return e.Status == status })
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// Print writes a line per entry
+func (r *Report) Print(w io.Writer) error {
+	var b strings.Builder
+	report.Heading(&b, "Import", r.DryRun)
+
+	for _, name := range r.Unmapped {
+		fmt.Fprintf(&b, "  unmapped author %s\n", name)
+	}
+
+	for _, e := range r.Entries {
+		fmt.Fprintf(&b, "  %-8s %s %s", e.Status, e.Source, e.Title)
+		if e.ID != 0 {
+			fmt.Fprintf(&b, " as #%d", e.ID)
+		}
+		fmt.Fprintf(&b, "%s\n", report.ErrorSuffix(e.Error))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Imported is a post created from a source document
+type Imported struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Comments is the number of comments created
+	Comments int `json:"comments,omitempty"`
+}
+
+// IDMap maps the IDs of source documents to the posts created from them. It is saved
+// after each post and comment, so an interrupted import resumes without duplicating them.
+type IDMap struct {
+	path  string
+	Posts map[string]Imported `json:"posts"`
+}
+
+// LoadIDMap reads the mapping file, which may not exist yet
+func LoadIDMap(path string) (*IDMap, error) {
+	m := &IDMap{path: path, Posts: make(map[string]Imported)}
+
+	if path == "" {
+		return m, nil
+	}
+
+	b, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if m.Posts == nil {
+		m.Posts = make(map[string]Imported)
+	}
+
+	return m, nil
+}
+
+// Get returns the post created from the source ID
+func (m *IDMap) Get(sourceID string) (Imported, bool) {
+	p, ok := m.Posts[sourceID]
+	return p, ok
+}
+
+// Set records the post created from the source ID and saves the file
+func (m *IDMap) Set(sourceID string, p Imported) error {
+	m.Posts[sourceID] = p
+	return m.Save()
+}
+
+// Save writes the file atomically. A map without a path is kept in memory.
+func (m *IDMap) Save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, m.path)
+}
+
+// CreateComments creates the n comments of the post created from the source ID, from
+// the first one not created yet on. The request of the i-th comment is made by build.
+func CreateComments(c *docbase.Client, ids *IDMap, sourceID string, n int, build func(i int) (*docbase.CommentCreateRequest, error)) error {
+	p, ok := ids.Get(sourceID)
+
+	if !ok {
+		return fmt.Errorf("%s is not imported", sourceID)
+	}
+
+	for i := p.Comments; i < n; i++ {
+		req, err := build(i)
+
+		if err != nil {
+			return fmt.Errorf("comment %d: %w", i+1, err)
+		}
+
+		if _, _, err := c.Comments.Create(p.ID, req); err != nil {
+			return fmt.Errorf("comment %d: %w", i+1, err)
+		}
+
+		p.Comments = i + 1
+		if err := ids.Set(sourceID, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadAuthors reads a YAML mapping of source user names to DocBase usernames like
+//
+//	taro_esa: taro
+//	hanako.s: hanako
+func LoadAuthors(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make(map[string]string)
+	if err := yaml.Unmarshal(b, &authors); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return authors, nil
+}
+
+// Authors resolves source user names to users of the team. Names without
+// a mapping are looked up as usernames.
+type Authors struct {
+	client   *docbase.Client
+	names    map[string]string
+	unmapped map[string]bool
+}
+
+// NewAuthors returns Authors with the mapping of source user names to usernames
+func NewAuthors(c *docbase.Client, names map[string]string) *Authors {
+	return &Authors{client: c, names: names, unmapped: make(map[string]bool)}
+}
+
+// AuthorID returns the ID of the user to set as AuthorID, or "" when the name
+// has no user, which is then listed by Unmapped. Other failures to look the user
+// up are returned, so documents are not posted as the owner of the token by mistake.
+func (a *Authors) AuthorID(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	username := name
+	if mapped, ok := a.names[name]; ok {
+		username = mapped
+	}
+
+	u, err := a.client.UserDirectory.ByUsername(strings.TrimPrefix(username, "@"))
+
+	if errors.Is(err, docbase.ErrUserNotFound) {
+		a.unmapped[name] = true
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("looking up author %s: %w", name, err)
+	}
+
+	return strconv.Itoa(u.ID), nil
+}
+
+// Unmapped returns the names without a user, sorted
+func (a *Authors) Unmapped() []string {
+	names := make([]string, 0, len(a.unmapped))
+	for name := range a.unmapped {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
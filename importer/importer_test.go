@@ -0,0 +1,242 @@
+package importer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+func TestIDMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids", "esa.json")
+
+	m, err := LoadIDMap(path)
+
+	if err != nil || len(m.Posts) != 0 {
+		t.Fatalf("LoadIDMap returned %+v, %v", m, err)
+	}
+
+	if err := m.Set("12", Imported{ID: 1001, URL: "https://team.docbase.io/posts/1001"}); err != nil {
+		t.Fatalf("Set returned an error: %v", err)
+	}
+
+	m, err = LoadIDMap(path)
+
+	if p, ok := m.Get("12"); err != nil || !ok || p.ID != 1001 {
+		t.Errorf("Get returned %+v, %v, %v", p, ok, err)
+	}
+
+	os.WriteFile(path, []byte("{"), 0o644)
+	if _, err := LoadIDMap(path); err == nil {
+		t.Errorf("LoadIDMap should fail for invalid JSON")
+	}
+}
+
+func TestRefs(t *testing.T) {
+	body := `![diagram](https://img.example.com/a.png "Diagram")
+[spec](files/spec.pdf) and [again](files/spec.pdf)
+<img src="images/b.png" width="200"> <a href='https://example.com/'>site</a>`
+
+	want := []string{"https://img.example.com/a.png", "files/spec.pdf", "images/b.png", "https://example.com/"}
+	if got := Refs(body); !reflect.DeepEqual(got, want) {
+		t.Errorf("Refs returned %q, want %q", got, want)
+	}
+
+	got := ReplaceRefs(body, func(ref string) (string, bool) {
+		return "https://docbase.example.com/" + filepath.Base(ref), strings.Contains(ref, ".p")
+	})
+
+	for _, s := range []string{`![diagram](https://docbase.example.com/a.png "Diagram")`, `[again](https://docbase.example.com/spec.pdf)`, `src="https://docbase.example.com/b.png"`, `href='https://example.com/'`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("ReplaceRefs returned %s, missing %s", got, s)
+		}
+	}
+}
+
+func TestHasHost(t *testing.T) {
+	hosts := []string{"img.esa.io"}
+
+	if !HasHost("https://IMG.esa.io/uploads/a.png", hosts) || HasHost("images/a.png", hosts) || HasHost("https://example.com/a.png", hosts) {
+		t.Errorf("HasHost is wrong")
+	}
+}
+
+func TestLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "export")
+	doc := filepath.Join(root, "posts", "1.md")
+
+	for _, p := range []string{filepath.Join(root, "posts", "images", "a.png"), filepath.Join(root, "b.png"), filepath.Join(dir, "secret")} {
+		os.MkdirAll(filepath.Dir(p), 0o700)
+		os.WriteFile(p, []byte("x"), 0o600)
+	}
+	os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "posts", "link.png"))
+
+	tests := map[string]string{
+		"images/a.png":           filepath.Join(root, "posts", "images", "a.png"),
+		"../b.png":               filepath.Join(root, "b.png"),
+		"../../secret":           "",
+		"images/../../../secret": "",
+		"link.png":               "",
+		"/etc/passwd":            "",
+		"images":                 "",
+		"missing.png":            "",
+		"https://img.esa.io/a":   "",
+	}
+
+	for ref, want := range tests {
+		if got := LocalFile(root, doc, ref); got != want {
+			t.Errorf("LocalFile(%q) returned %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	ids, _ := LoadIDMap("")
+	ids.Set("1", Imported{ID: 101, URL: "https://team.docbase.io/posts/101"})
+
+	re := regexp.MustCompile(`(?:^|[\s(])((?:https://team\.esa\.io)?/posts/(\d+))`)
+	body := "see https://team.esa.io/posts/1#comment-2, [rel](/posts/1) and /posts/2, not https://example.com/posts/1"
+
+	want := "see https://team.docbase.io/posts/101#comment-2, [rel](https://team.docbase.io/posts/101) and /posts/2, not https://example.com/posts/1"
+	if got := RewriteLinks(body, re, ids); got != want {
+		t.Errorf("RewriteLinks returned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateLinks(t *testing.T) {
+	s := fakeserver.New(t)
+	id := s.AddPost(docbase.Post{Title: "b", Body: "see /posts/1"}, 0)
+
+	ids, _ := LoadIDMap("")
+	ids.Set("1", Imported{ID: 101, URL: "https://team.docbase.io/posts/101"})
+	ids.Set("2", Imported{ID: id, URL: "https://team.docbase.io/posts/" + strconv.Itoa(id)})
+
+	cached, err := docbase.NewClientWithOptions("fakeTeam", "fakeToken", docbase.WithBaseURL(s.URL), docbase.WithCache(docbase.NewMemoryCache(10), time.Minute))
+
+	if err != nil {
+		t.Fatalf("NewClientWithOptions returned an error: %v", err)
+	}
+
+	if _, _, err := cached.Posts.Get(id); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+
+	// edited by someone else after the response was cached
+	if _, _, err := s.Client(t).Posts.Update(id, &docbase.PostUpdateRequest{Body: docbase.String("edited, see /posts/1")}); err != nil {
+		t.Fatalf("Update returned an error: %v", err)
+	}
+
+	report := &Report{Entries: []Entry{{Source: "2", Status: StatusImported}}}
+	re := regexp.MustCompile(`(?:^|[\s(])(/posts/(\d+))`)
+
+	UpdateLinks(cached, report, re, ids, func(n int) (string, string) {
+		return "2", "see /posts/1"
+	})
+
+	if report.Entries[0].Status != StatusImported {
+		t.Fatalf("Entry is %+v", report.Entries[0])
+	}
+
+	if got := s.Post(id).Body; got != "edited, see https://team.docbase.io/posts/101" {
+		t.Errorf("Body is %q", got)
+	}
+}
+
+func TestRehoster(t *testing.T) {
+	s := fakeserver.New(t)
+	rh, err := NewRehoster(s.Client(t))
+
+	if err != nil {
+		t.Fatalf("NewRehoster returned an error: %v", err)
+	}
+	defer rh.Close()
+
+	a := s.AddAttachment("remote.png", []byte("remote"))
+	path, err := rh.Download(a.URL)
+
+	if err != nil || filepath.Base(path) != a.ID {
+		t.Fatalf("Download returned %s, %v", path, err)
+	}
+
+	local := filepath.Join(t.TempDir(), "local.png")
+	os.WriteFile(local, []byte("local"), 0o600)
+
+	body := "![r](" + a.URL + ") ![l](local.png) ![l](local.png)"
+	files := map[string]string{a.URL: path, "local.png": local}
+
+	got, err := rh.Rehost(body, files)
+
+	if err != nil {
+		t.Fatalf("Rehost returned an error: %v", err)
+	}
+
+	if strings.Contains(got, a.URL) || strings.Contains(got, "(local.png)") {
+		t.Errorf("Rehost returned %s", got)
+	}
+
+	// files uploaded before are not uploaded again
+	if _, err := rh.Rehost("![l](local.png)", files); err != nil {
+		t.Fatalf("Rehost returned an error: %v", err)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 1 {
+		t.Errorf("Requests are %v", reqs)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	s := fakeserver.New(t)
+	s.AddUser(docbase.User{ID: 1, Username: "taro", Name: "Taro"})
+
+	a := NewAuthors(s.Client(t), map[string]string{"taro_esa": "taro"})
+
+	for name, want := range map[string]string{"taro_esa": "1", "taro": "1", "ghost": "", "": ""} {
+		if got, err := a.AuthorID(name); err != nil || got != want {
+			t.Errorf("AuthorID(%q) returned %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	if got := a.Unmapped(); !reflect.DeepEqual(got, []string{"ghost"}) {
+		t.Errorf("Unmapped returned %v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "authors.yaml")
+	os.WriteFile(path, []byte("taro_esa: taro\nhanako.s: hanako\n"), 0o644)
+
+	if got, err := LoadAuthors(path); err != nil || got["hanako.s"] != "hanako" {
+		t.Errorf("LoadAuthors returned %v, %v", got, err)
+	}
+}
+
+func TestAuthors_LookupFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, err := docbase.NewClientWithOptions("fakeTeam", "fakeToken", docbase.WithBaseURL(srv.URL))
+
+	if err != nil {
+		t.Fatalf("NewClientWithOptions returned an error: %v", err)
+	}
+
+	a := NewAuthors(c, nil)
+
+	if _, err := a.AuthorID("taro"); err == nil {
+		t.Errorf("AuthorID should return the error of the lookup")
+	}
+
+	if got := a.Unmapped(); len(got) != 0 {
+		t.Errorf("An author failed to look up should not be unmapped: %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package qiita
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Item is an article of a Qiita Team export
+type Item struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Tags      []Tag     `json:"tags"`
+	Coediting bool      `json:"coediting"`
+	Group     *Group    `json:"group"`
+	Private   bool      `json:"private"`
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Comments  []Comment `json:"comments"`
+}
+
+// Tag is a tag of an item
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Group is the group an item is shared with
+type Group struct {
+	Name    string `json:"name"`
+	URLName string `json:"url_name"`
+}
+
+// User is the author of an item or comment
+type User struct {
+	ID string `json:"id"`
+}
+
+// Comment is a comment on an item
+type Comment struct {
+	ID        string    `json:"id"`
+	Body      string    `json:"body"`
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Load reads the items of an export, either a list of items or an object with
+// the items in "articles", and orders them by creation
+func Load(r io.Reader) ([]*Item, error) {
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*Item
+
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(b, &items)
+	} else {
+		var export struct {
+			Articles []*Item `json:"articles"`
+		}
+		err = json.Unmarshal(b, &export)
+		items = export.Articles
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.ID == "" {
+			return nil, fmt.Errorf("item %q has no id", item.Title)
+		}
+	}
+
+	sortItems(items)
+	return items, nil
+}
+
+// LoadFile reads a .json export, or the .json files of a .zip export
+func LoadFile(name string) ([]*Item, error) {
+	if strings.ToLower(filepath.Ext(name)) != ".zip" {
+		f, err := os.Open(name)
+
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return Load(f)
+	}
+
+	zr, err := zip.OpenReader(name)
+
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var items []*Item
+	for _, f := range zr.File {
+		if strings.ToLower(path.Ext(f.Name)) != ".json" {
+			continue
+		}
+
+		rc, err := f.Open()
+
+		if err != nil {
+			return nil, err
+		}
+
+		loaded, err := Load(rc)
+		rc.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		items = append(items, loaded...)
+	}
+
+	sortItems(items)
+	return items, nil
+}
+
+func sortItems(items []*Item) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+}
@@ -0,0 +1,262 @@
+// Package qiita imports items from Qiita Team JSON exports.
+package qiita
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/importer"
+)
+
+// ImageHosts are the hosts Qiita keeps the uploaded images on. Files on the
+// team's host, under /files/, are uploaded too.
+var ImageHosts = []string{
+	"qiita-image-store.s3.amazonaws.com",
+	"qiita-image-store.s3.ap-northeast-1.amazonaws.com",
+	"qiita-user-contents.imgix.net",
+}
+
+// Importer creates DocBase posts from Qiita Team items
+type Importer struct {
+	client *docbase.Client
+
+	// Team is the Qiita Team whose links, like https://team.qiita.com/taro/items/0123abcd,
+	// are rewritten to the imported posts. Relative links are always rewritten.
+	Team string
+	// Authors maps Qiita user IDs to DocBase usernames, for users named differently
+	Authors map[string]string
+	// Groups maps the URL names of Qiita groups to DocBase group names, for groups named
+	// differently. Items of a group are posted to the group, private items privately
+	// and others to everyone.
+	Groups map[string]string
+	// CoeditingTag tags coediting items when set
+	CoeditingTag string
+	// ImageHosts are the hosts of the images to upload again, ImageHosts by default
+	ImageHosts []string
+	// IDMap is the checkpoint file mapping item IDs to the imported posts and the number
+	// of their comments created. A run resumes where the previous one stopped.
+	IDMap string
+	// HTTPClient downloads the images, http.DefaultClient by default. Files on the team's
+	// host need a client authenticating to Qiita Team.
+	HTTPClient *http.Client
+	// DryRun reports the items to import without creating them
+	DryRun bool
+}
+
+// New returns an Importer of the items of the Qiita Team
+func New(c *docbase.Client, team string) *Importer {
+	return &Importer{client: c, Team: team, ImageHosts: ImageHosts}
+}
+
+// Run imports the items with their comments in order. Items keep their Qiita authors,
+// mapped by user ID, and creation dates, which an owner or admin token allows setting.
+// Links between items are rewritten once all are imported. The report lists the items
+// which failed, and since they are missing from the IDMap a new run retries them.
+func (i *Importer) Run(items []*Item) (*importer.Report, error) {
+	ids, err := importer.LoadIDMap(i.IDMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rh, err := importer.NewRehoster(i.client)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rh.Close()
+
+	if i.HTTPClient != nil {
+		rh.HTTPClient = i.HTTPClient
+	}
+
+	authors := importer.NewAuthors(i.client, i.Authors)
+	report := &importer.Report{DryRun: i.DryRun}
+
+	for _, item := range items {
+		report.Entries = append(report.Entries, i.importItem(item, ids, authors, rh))
+	}
+
+	if !i.DryRun {
+		importer.UpdateLinks(i.client, report, i.linkPattern(), ids, func(n int) (string, string) {
+			return items[n].ID, items[n].Body
+		})
+	}
+
+	report.Unmapped = authors.Unmapped()
+
+	if n := report.Count(importer.StatusFailed); n > 0 {
+		return report, fmt.Errorf("%d items failed", n)
+	}
+
+	return report, nil
+}
+
+func (i *Importer) importItem(item *Item, ids *importer.IDMap, authors *importer.Authors, rh *importer.Rehoster) importer.Entry {
+	entry := importer.Entry{Source: item.ID, Title: item.Title, Status: importer.StatusImported}
+
+	if imported, ok := ids.Get(item.ID); ok && imported.Comments >= len(item.Comments) {
+		entry.ID, entry.URL, entry.Status = imported.ID, imported.URL, importer.StatusSkipped
+		return entry
+	}
+
+	req := i.request(item)
+
+	var err error
+	if req.AuthorID, err = authors.AuthorID(item.User.ID); err != nil {
+		entry.Status = importer.StatusFailed
+		entry.Error = err.Error()
+		return entry
+	}
+
+	if i.DryRun {
+		entry.Status = importer.StatusPlanned
+		return entry
+	}
+
+	err = i.create(item, req, ids, authors, rh)
+
+	if imported, ok := ids.Get(item.ID); ok {
+		entry.ID, entry.URL = imported.ID, imported.URL
+	}
+
+	if err != nil {
+		entry.Status = importer.StatusFailed
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+// request maps the item, but its body and author
+func (i *Importer) request(item *Item) *docbase.PostCreateRequest {
+	req := &docbase.PostCreateRequest{
+		Title:  item.Title,
+		Notice: docbase.Bool(false),
+		Tags:   []string{},
+		Scope:  docbase.ScopeEveryone,
+	}
+
+	if !item.CreatedAt.IsZero() {
+		req.PublishedAt = docbase.Time(item.CreatedAt)
+	}
+
+	for _, t := range item.Tags {
+		req.Tags = append(req.Tags, t.Name)
+	}
+
+	if item.Coediting && i.CoeditingTag != "" {
+		req.Tags = append(req.Tags, i.CoeditingTag)
+	}
+
+	switch {
+	case item.Private:
+		req.Scope = docbase.ScopePrivate
+	case item.Group != nil:
+		req.Scope = docbase.ScopeGroup
+		req.Groups = []string{i.groupName(item.Group)}
+	}
+
+	return req
+}
+
+func (i *Importer) groupName(g *Group) string {
+	if name, ok := i.Groups[g.URLName]; ok {
+		return name
+	}
+
+	if name, ok := i.Groups[g.Name]; ok {
+		return name
+	}
+
+	return g.Name
+}
+
+// create creates the post unless a previous run did, and the comments not created yet
+func (i *Importer) create(item *Item, req *docbase.PostCreateRequest, ids *importer.IDMap, authors *importer.Authors, rh *importer.Rehoster) error {
+	if _, ok := ids.Get(item.ID); !ok {
+		body, err := i.rehost(item.Body, rh)
+
+		if err != nil {
+			return err
+		}
+		req.Body = body
+
+		created, _, err := i.client.Posts.Create(req)
+
+		if err != nil {
+			return err
+		}
+
+		if err := ids.Set(item.ID, importer.Imported{ID: created.ID, URL: created.URL}); err != nil {
+			return err
+		}
+	}
+
+	return importer.CreateComments(i.client, ids, item.ID, len(item.Comments), func(n int) (*docbase.CommentCreateRequest, error) {
+		c := item.Comments[n]
+		body, err := i.rehost(c.Body, rh)
+
+		if err != nil {
+			return nil, err
+		}
+
+		authorID, err := authors.AuthorID(c.User.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req := &docbase.CommentCreateRequest{Body: body, Notice: docbase.Bool(false), AuthorID: authorID}
+		if !c.CreatedAt.IsZero() {
+			req.PublishedAt = docbase.Time(c.CreatedAt)
+		}
+
+		return req, nil
+	})
+}
+
+// rehost uploads the images of the body
+func (i *Importer) rehost(body string, rh *importer.Rehoster) (string, error) {
+	files := make(map[string]string)
+
+	for _, ref := range importer.Refs(body) {
+		if !importer.HasHost(ref, i.ImageHosts) && !i.isTeamFile(ref) {
+			continue
+		}
+
+		path, err := rh.Download(ref)
+
+		if err != nil {
+			return "", err
+		}
+
+		files[ref] = path
+	}
+
+	return rh.Rehost(body, files)
+}
+
+func (i *Importer) isTeamFile(ref string) bool {
+	if i.Team == "" || !importer.HasHost(ref, []string{i.Team + ".qiita.com"}) {
+		return false
+	}
+
+	u, _ := url.Parse(ref)
+	return strings.HasPrefix(u.Path, "/files/")
+}
+
+// linkPattern matches links to items of the team and relative links, the link in the
+// first group and the item ID in the second
+func (i *Importer) linkPattern() *regexp.Regexp {
+	host := ""
+	if i.Team != "" {
+		host = `(?:https?://` + regexp.QuoteMeta(i.Team) + `\.qiita\.com)?`
+	}
+
+	return regexp.MustCompile(`(?:^|[\s(<"'])(` + host + `/[\w.-]+/items/([0-9a-f]+))`)
+}
@@ -0,0 +1,217 @@
+package qiita
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/importer"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+const export = `{
+  "articles": [
+    {
+      "id": "bbbb2222",
+      "title": "Private notes",
+      "body": "Follows [the design](https://acme.qiita.com/taro/items/aaaa1111).",
+      "tags": [{"name": "notes", "versions": []}],
+      "private": true,
+      "user": {"id": "hanako"},
+      "created_at": "2019-04-02T09:30:00+09:00",
+      "comments": [
+        {"id": "c1", "body": "First", "user": {"id": "taro_q"}, "created_at": "2019-04-03T12:00:00+09:00"},
+        {"id": "c2", "body": "Second", "user": {"id": "hanako"}, "created_at": "2019-04-03T13:00:00+09:00"}
+      ]
+    },
+    {
+      "id": "aaaa1111",
+      "title": "Design",
+      "body": "![diagram](%IMAGE%)\nSee /hanako/items/bbbb2222 and /taro/items/ffff9999.",
+      "tags": [{"name": "design", "versions": []}],
+      "coediting": true,
+      "group": {"name": "Development", "url_name": "dev-team"},
+      "private": false,
+      "user": {"id": "taro_q"},
+      "created_at": "2019-04-01T10:00:00+09:00",
+      "comments": []
+    },
+    {
+      "id": "cccc3333",
+      "title": "Memo",
+      "body": "memo",
+      "tags": [],
+      "group": null,
+      "user": {"id": "ghost"},
+      "created_at": "2019-04-04T10:00:00+09:00"
+    }
+  ]
+}`
+
+var tokyo = time.FixedZone("", 9*60*60)
+
+func newExport(t *testing.T) (string, string) {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG image"))
+	}))
+	t.Cleanup(images.Close)
+
+	name := filepath.Join(t.TempDir(), "export.json")
+	os.WriteFile(name, []byte(strings.Replace(export, "%IMAGE%", images.URL+"/0/1/diagram.png", 1)), 0o644)
+
+	return name, images.URL
+}
+
+var team = fakeserver.Seed{Groups: []fakeserver.Group{{Name: "dev", Members: []int{1, 2}}}}
+
+func newImporter(t *testing.T, s *fakeserver.Team, imageHost string) *Importer {
+	i := New(s.Client, "acme")
+	i.Authors = map[string]string{"taro_q": "taro"}
+	i.Groups = map[string]string{"dev-team": "dev"}
+	i.CoeditingTag = "coediting"
+	i.IDMap = filepath.Join(t.TempDir(), "checkpoint.json")
+
+	u, _ := url.Parse(imageHost)
+	i.ImageHosts = []string{u.Hostname()}
+
+	return i
+}
+
+func TestLoad(t *testing.T) {
+	items, err := Load(strings.NewReader(export))
+
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+
+	if len(items) != 3 || items[0].ID != "aaaa1111" || items[0].Group.URLName != "dev-team" || len(items[1].Comments) != 2 {
+		t.Errorf("Load returned %+v", items)
+	}
+
+	list, err := Load(strings.NewReader(`[{"id": "aaaa1111", "title": "Design"}]`))
+
+	if err != nil || len(list) != 1 {
+		t.Errorf("Load returned %+v, %v", list, err)
+	}
+
+	if _, err := Load(strings.NewReader(`[{"title": "no id"}]`)); err == nil {
+		t.Errorf("Load should fail for items without id")
+	}
+}
+
+func TestLoadFile_Zip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "export.zip")
+	f, _ := os.Create(name)
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("acme/articles.json")
+	w.Write([]byte(export))
+	zw.Close()
+	f.Close()
+
+	items, err := LoadFile(name)
+
+	if err != nil || len(items) != 3 {
+		t.Errorf("LoadFile returned %+v, %v", items, err)
+	}
+}
+
+func TestImporter_request(t *testing.T) {
+	items, _ := Load(strings.NewReader(export))
+	i := &Importer{Groups: map[string]string{"dev-team": "dev"}, CoeditingTag: "coediting"}
+
+	design := i.request(items[0])
+	if design.Scope != docbase.ScopeGroup || !reflect.DeepEqual(design.Groups, []string{"dev"}) || !reflect.DeepEqual(design.Tags, []string{"design", "coediting"}) {
+		t.Errorf("design request is %+v", design)
+	}
+
+	if notes := i.request(items[1]); notes.Scope != docbase.ScopePrivate || len(notes.Groups) != 0 {
+		t.Errorf("notes request is %+v", notes)
+	}
+
+	if memo := i.request(items[2]); memo.Scope != docbase.ScopeEveryone || !memo.PublishedAt.Equal(time.Date(2019, 4, 4, 10, 0, 0, 0, tokyo)) {
+		t.Errorf("memo request is %+v", memo)
+	}
+}
+
+func TestImporter_Run(t *testing.T) {
+	name, imageHost := newExport(t)
+	items, _ := LoadFile(name)
+
+	s := fakeserver.NewTeam(t, team)
+	i := newImporter(t, s, imageHost)
+
+	report, err := i.Run(items)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v\n%+v", err, report)
+	}
+
+	if report.Count(importer.StatusImported) != 3 || !reflect.DeepEqual(report.Unmapped, []string{"ghost"}) {
+		t.Errorf("Report is %+v", report)
+	}
+
+	design := s.Post(report.Entries[0].ID)
+	notes := s.Post(report.Entries[1].ID)
+
+	if design.User.ID != 1 || design.Scope != docbase.ScopeGroup || design.Groups[0].Name != "dev" || len(design.Tags) != 2 {
+		t.Errorf("design is %+v", design)
+	}
+
+	if strings.Contains(design.Body, imageHost) || !strings.Contains(design.Body, "]("+s.URL+"/uploads/") {
+		t.Errorf("design image should be uploaded: %s", design.Body)
+	}
+
+	// the link to an item not in the export is left
+	if !strings.Contains(design.Body, "See "+notes.URL+" and /taro/items/ffff9999.") {
+		t.Errorf("design links should be rewritten: %s", design.Body)
+	}
+
+	if notes.Scope != docbase.ScopePrivate || notes.User.ID != 2 || !strings.Contains(notes.Body, "("+design.URL+")") {
+		t.Errorf("notes is %+v", notes)
+	}
+
+	if len(notes.Comments) != 2 || notes.Comments[0].SimpleUser.ID != 1 || !notes.Comments[1].CreatedAt.Equal(time.Date(2019, 4, 3, 13, 0, 0, 0, tokyo)) {
+		t.Errorf("notes comments are %+v", notes.Comments)
+	}
+}
+
+func TestImporter_Resume(t *testing.T) {
+	name, imageHost := newExport(t)
+	items, _ := LoadFile(name)
+
+	s := fakeserver.NewTeam(t, team)
+	i := newImporter(t, s, imageHost)
+
+	// a previous run stopped after the first comment of the notes
+	notesID := s.AddPost(docbase.Post{Title: "Private notes", Comments: []docbase.Comment{{ID: 1, Body: "First"}}}, 2)
+	ids, _ := importer.LoadIDMap(i.IDMap)
+	ids.Set("bbbb2222", importer.Imported{ID: notesID, URL: "https://acme.docbase.io/posts/1", Comments: 1})
+
+	report, err := i.Run(items)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v\n%+v", err, report)
+	}
+
+	if len(s.Posts()) != 3 || report.Entries[1].ID != notesID || report.Entries[1].Status != importer.StatusImported {
+		t.Errorf("Report is %+v", report)
+	}
+
+	if c := s.Post(notesID).Comments; len(c) != 2 || c[1].Body != "Second" {
+		t.Errorf("notes comments are %+v", c)
+	}
+
+	report, err = i.Run(items)
+
+	if err != nil || report.Count(importer.StatusSkipped) != 3 || len(s.Posts()) != 3 {
+		t.Errorf("Run returned %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,299 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+var (
+	// markdownRef matches images and links, the target in the second group
+	markdownRef = regexp.MustCompile(`(!?\[[^\]]*\]\(\s*<?)([^)\s>]+)(>?(?:\s+"[^"]*")?\s*\))`)
+	// htmlRef matches the sources of img tags and the targets of a tags
+	htmlRef = regexp.MustCompile(`(?i)(<(?:img|a)\s[^>]*?(?:src|href)\s*=\s*["'])([^"']+)(["'])`)
+)
+
+// Refs returns the targets of the images and links of a markdown body, including
+// those of img and a tags, in order and without duplicates
+func Refs(body string) []string {
+	var refs []string
+	seen := make(map[string]bool)
+
+	for _, re := range []*regexp.Regexp{markdownRef, htmlRef} {
+		for _, m := range re.FindAllStringSubmatch(body, -1) {
+			if !seen[m[2]] {
+				seen[m[2]] = true
+				refs = append(refs, m[2])
+			}
+		}
+	}
+
+	return refs
+}
+
+// ReplaceRefs replaces the targets of images and links for which fn returns true
+func ReplaceRefs(body string, fn func(ref string) (string, bool)) string {
+	for _, re := range []*regexp.Regexp{markdownRef, htmlRef} {
+		body = re.ReplaceAllStringFunc(body, func(s string) string {
+			m := re.FindStringSubmatch(s)
+
+			if to, ok := fn(m[2]); ok {
+				return m[1] + to + m[3]
+			}
+
+			return s
+		})
+	}
+
+	return body
+}
+
+// HasHost reports whether ref is an absolute URL on one of the hosts
+func HasHost(ref string, hosts []string) bool {
+	u, err := url.Parse(ref)
+
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, h := range hosts {
+		if strings.EqualFold(u.Hostname(), h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LocalFile returns the file a relative ref of the document at docPath points to, or ""
+// when the ref is not relative, the file does not exist or it is outside root, the export
+// directory, so a document cannot make the import upload any file it names
+func LocalFile(root, docPath, ref string) string {
+	u, err := url.Parse(ref)
+
+	if docPath == "" || err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return ""
+	}
+
+	if root == "" {
+		root = filepath.Dir(docPath)
+	}
+
+	file := filepath.Join(filepath.Dir(docPath), filepath.FromSlash(u.Path))
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return ""
+	}
+
+	// compared once links are followed, so a link cannot point outside either
+	realRoot, err := filepath.EvalSymlinks(root)
+
+	if err != nil {
+		return ""
+	}
+
+	realFile, err := filepath.EvalSymlinks(file)
+
+	if err != nil {
+		return ""
+	}
+
+	rel, err := filepath.Rel(realRoot, realFile)
+
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return ""
+	}
+
+	return file
+}
+
+// Rehoster uploads the files referenced by documents to the team and points the
+// documents to the uploaded files. A file referenced by several documents is uploaded once.
+type Rehoster struct {
+	client *docbase.Client
+	dir    string
+	// downloaded maps remote files to their local paths
+	downloaded map[string]string
+	// uploaded maps local paths to the URLs of the uploaded files
+	uploaded map[string]string
+
+	// HTTPClient downloads remote files
+	HTTPClient *http.Client
+}
+
+// NewRehoster returns a Rehoster downloading to a temporary directory removed by Close
+func NewRehoster(c *docbase.Client) (*Rehoster, error) {
+	dir, err := os.MkdirTemp("", "docbase-import-")
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Rehoster{
+		client:     c,
+		dir:        dir,
+		downloaded: make(map[string]string),
+		uploaded:   make(map[string]string),
+		HTTPClient: http.DefaultClient,
+	}
+
+	return r, nil
+}
+
+// Close removes the downloaded files
+func (r *Rehoster) Close() error {
+	return os.RemoveAll(r.dir)
+}
+
+// Download fetches the remote file, keeping its name, and returns its local path
+func (r *Rehoster) Download(ref string) (string, error) {
+	if p, ok := r.downloaded[ref]; ok {
+		return p, nil
+	}
+
+	res, err := r.HTTPClient.Get(ref)
+
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading %s: %s", ref, res.Status)
+	}
+
+	name := "file"
+	if u, err := url.Parse(ref); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name = base
+		}
+	}
+
+	// a directory per file, as names may repeat
+	dir := filepath.Join(r.dir, strconv.Itoa(len(r.downloaded)+1))
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	p := filepath.Join(dir, name)
+	f, err := os.Create(p)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(f, res.Body); err != nil {
+		f.Close()
+		return "", fmt.Errorf("downloading %s: %w", ref, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	r.downloaded[ref] = p
+	return p, nil
+}
+
+// Rehost uploads the files, which map refs of the body to local paths, and
+// replaces the refs with the URLs of the uploaded files
+func (r *Rehoster) Rehost(body string, files map[string]string) (string, error) {
+	var paths []string
+	for _, ref := range Refs(body) {
+		p, ok := files[ref]
+
+		if _, done := r.uploaded[p]; ok && !done {
+			paths = append(paths, p)
+			r.uploaded[p] = ""
+		}
+	}
+
+	if len(paths) > 0 {
+		uploaded, _, err := r.client.Attachments.Upload(paths)
+
+		if err == nil && len(*uploaded) != len(paths) {
+			err = fmt.Errorf("uploaded %d of %d attachments", len(*uploaded), len(paths))
+		}
+
+		if err != nil {
+			for _, p := range paths {
+				delete(r.uploaded, p)
+			}
+
+			return "", fmt.Errorf("uploading attachments: %w", err)
+		}
+
+		for i, p := range paths {
+			r.uploaded[p] = (*uploaded)[i].URL
+		}
+	}
+
+	return ReplaceRefs(body, func(ref string) (string, bool) {
+		to := r.uploaded[files[ref]]
+		return to, to != ""
+	}), nil
+}
+
+// RewriteLinks replaces the links matched by re with the URLs of the posts created
+// from the linked documents. The first group of re is the link to replace and the
+// second is the source ID. Links to documents not imported are left.
+func RewriteLinks(body string, re *regexp.Regexp, ids *IDMap) string {
+	var b strings.Builder
+	last := 0
+
+	for _, m := range re.FindAllStringSubmatchIndex(body, -1) {
+		p, ok := ids.Get(body[m[4]:m[5]])
+
+		if !ok || p.URL == "" {
+			continue
+		}
+
+		b.WriteString(body[last:m[2]])
+		b.WriteString(p.URL)
+		last = m[3]
+	}
+
+	b.WriteString(body[last:])
+	return b.String()
+}
+
+// UpdateLinks rewrites the links of the imported posts once all are imported, including
+// links to posts imported by previous runs, see RewriteLinks. source returns the IDMap key
+// and the body of the document of the nth entry of the report. Posts are read past the
+// response cache, so a stale body never overwrites a newer one. An entry whose post
+// fails to update is marked failed.
+func UpdateLinks(c *docbase.Client, report *Report, re *regexp.Regexp, ids *IDMap, source func(n int) (key, body string)) {
+	for n := range report.Entries {
+		entry := &report.Entries[n]
+		key, body := source(n)
+		imported, ok := ids.Get(key)
+
+		if !ok || entry.Status == StatusFailed || !re.MatchString(body) {
+			continue
+		}
+
+		current, _, err := docbase.GetUncachedJSON[docbase.Post](c, fmt.Sprintf("/posts/%d", imported.ID), nil)
+
+		if err == nil {
+			rewritten := RewriteLinks(current.Body, re, ids)
+			if rewritten == current.Body {
+				continue
+			}
+
+			_, _, err = c.Posts.Update(imported.ID, &docbase.PostUpdateRequest{Body: docbase.String(rewritten)})
+		}
+
+		if err != nil {
+			entry.Status = StatusFailed
+			entry.Error = fmt.Sprintf("rewriting links: %v", err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package fakeserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// AddAttachment stores a file and returns its attachment, to be set on posts
+func (s *Server) AddAttachment(name string, content []byte) docbase.Attachment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addAttachment(name, content)
+}
+
+// Attachment returns the content of the attachment
+func (s *Server) Attachment(id string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.files[id]
+}
+
+func (s *Server) addAttachment(name string, content []byte) docbase.Attachment {
+	id := fmt.Sprintf("%d%s", s.newID(), path.Ext(name))
+
+	if s.files == nil {
+		s.files = make(map[string][]byte)
+	}
+	s.files[id] = content
+
+	url := s.URL + "/uploads/" + id
+
+	return docbase.Attachment{
+		ID:        id,
+		Name:      name,
+		Size:      len(content),
+		URL:       url,
+		Markdown:  fmt.Sprintf("[![%s](%s)](%s)", name, url, url),
+		CreatedAt: time.Now(),
+	}
+}
+
+func (s *Server) serveAttachments(w http.ResponseWriter, r *http.Request, parts []string) {
+	// the files are served at the URLs of the attachments too
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost && parts[0] == "attachments":
+		var files []docbase.File
+		json.NewDecoder(r.Body).Decode(&files)
+
+		attachments := []docbase.Attachment{}
+		for _, f := range files {
+			content, err := base64.StdEncoding.DecodeString(f.Content)
+
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "bad_request")
+				return
+			}
+
+			attachments = append(attachments, s.addAttachment(f.Name, content))
+		}
+
+		writeJSON(w, http.StatusCreated, attachments)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		content, ok := s.files[parts[1]]
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		w.Write(content)
+	default:
+		writeError(w, http.StatusNotFound, "not_found")
+	}
+}
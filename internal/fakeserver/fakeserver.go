@@ -0,0 +1,322 @@
+// Package fakeserver is an in-memory DocBase API for tests of the packages built on the client.
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// Server serves users, groups, posts, comments and attachments.
+// Posts are searched by author:, tag:, group: and title: terms and words, and other terms are ignored.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[int]*docbase.User
+	groups   map[int]*docbase.Group
+	posts    map[int]*post
+	files    map[string][]byte
+	nextID   int
+	requests []string
+}
+
+// post keeps the author ID, which Post.User has but search needs the username of
+type post struct {
+	docbase.Post
+	authorID int
+}
+
+// New starts a Server, closed when the test ends
+func New(t *testing.T) *Server {
+	s := &Server{
+		users:  make(map[int]*docbase.User),
+		groups: make(map[int]*docbase.Group),
+		posts:  make(map[int]*post),
+		nextID: 1000,
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns a client for the server
+func (s *Server) Client(t *testing.T) *docbase.Client {
+	cli, err := docbase.NewClientWithOptions("fakeTeam", "fakeToken", docbase.WithBaseURL(s.URL))
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	return cli
+}
+
+// AddUser registers a user
+func (s *Server) AddUser(u docbase.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.ID] = &u
+}
+
+// AddGroup registers a group with members and returns its ID
+func (s *Server) AddGroup(name string, userIDs ...int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := &docbase.Group{ID: s.newID(), Name: name, CreatedAt: time.Now()}
+	s.groups[g.ID] = g
+	s.addMembers(g, userIDs)
+
+	return g.ID
+}
+
+// Members returns the member IDs of the group name, sorted
+func (s *Server) Members(name string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int
+	for _, g := range s.groups {
+		if g.Name != name {
+			continue
+		}
+
+		for _, u := range g.Users {
+			ids = append(ids, u.ID)
+		}
+	}
+
+	sort.Ints(ids)
+	return ids
+}
+
+// Requests returns "METHOD /path" of the requests which changed data
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) newID() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) addMembers(g *docbase.Group, userIDs []int) {
+	for _, id := range userIDs {
+		if s.isMember(g, id) {
+			continue
+		}
+
+		if u, ok := s.users[id]; ok {
+			g.Users = append(g.Users, docbase.SimpleUser{ID: u.ID, Name: u.Name, ProfileImageURL: u.ProfileImageURL})
+		}
+	}
+}
+
+func (s *Server) isMember(g *docbase.Group, userID int) bool {
+	for _, u := range g.Users {
+		if u.ID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method != http.MethodGet {
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case parts[0] == "users" && len(parts) == 1:
+		s.listUsers(w, r)
+	case parts[0] == "groups" && len(parts) == 1 && r.Method == http.MethodGet:
+		s.listGroups(w, r)
+	case parts[0] == "groups" && len(parts) == 1 && r.Method == http.MethodPost:
+		s.createGroup(w, r)
+	case parts[0] == "groups" && len(parts) == 2:
+		s.getGroup(w, parts[1])
+	case parts[0] == "groups" && len(parts) == 3 && parts[2] == "users":
+		s.changeMembers(w, r, parts[1])
+	case parts[0] == "posts":
+		s.servePosts(w, r, parts)
+	case parts[0] == "comments" && len(parts) == 2 && r.Method == http.MethodDelete:
+		s.deleteComment(w, parts[1])
+	case parts[0] == "attachments" || parts[0] == "uploads":
+		s.serveAttachments(w, r, parts)
+	case parts[0] == "tags" && len(parts) == 1:
+		s.listTags(w)
+	default:
+		writeError(w, http.StatusNotFound, "not_found")
+	}
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	includeGroups := r.URL.Query().Get("include_user_groups") == "true"
+
+	var users []docbase.User
+	for _, u := range s.users {
+		if q != "" && !strings.Contains(u.Username, q) && !strings.Contains(u.Name, q) {
+			continue
+		}
+
+		user := *u
+		user.Groups = []docbase.SimpleGroup{}
+		if includeGroups {
+			for _, g := range s.sortedGroups() {
+				if s.isMember(g, u.ID) {
+					user.Groups = append(user.Groups, docbase.SimpleGroup{ID: g.ID, Name: g.Name})
+				}
+			}
+		}
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	start, end := pageRange(r, len(users))
+	writeJSON(w, http.StatusOK, users[start:end])
+}
+
+func (s *Server) sortedGroups() []*docbase.Group {
+	var groups []*docbase.Group
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	groups := []docbase.SimpleGroup{}
+	for _, g := range s.sortedGroups() {
+		groups = append(groups, docbase.SimpleGroup{ID: g.ID, Name: g.Name})
+	}
+
+	start, end := pageRange(r, len(groups))
+	writeJSON(w, http.StatusOK, groups[start:end])
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) {
+	req := &docbase.GroupCreateRequest{}
+	json.NewDecoder(r.Body).Decode(req)
+
+	for _, g := range s.groups {
+		if g.Name == req.Name {
+			writeError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+	}
+
+	g := &docbase.Group{ID: s.newID(), Name: req.Name, Description: req.Description, CreatedAt: time.Now(), Users: []docbase.SimpleUser{}}
+	s.groups[g.ID] = g
+
+	writeJSON(w, http.StatusCreated, g)
+}
+
+func (s *Server) group(w http.ResponseWriter, rawID string) *docbase.Group {
+	id, _ := strconv.Atoi(rawID)
+	g, ok := s.groups[id]
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found")
+		return nil
+	}
+
+	return g
+}
+
+func (s *Server) getGroup(w http.ResponseWriter, rawID string) {
+	if g := s.group(w, rawID); g != nil {
+		writeJSON(w, http.StatusOK, g)
+	}
+}
+
+func (s *Server) changeMembers(w http.ResponseWriter, r *http.Request, rawID string) {
+	g := s.group(w, rawID)
+
+	if g == nil {
+		return
+	}
+
+	req := &docbase.GroupUserCreateRequest{}
+	json.NewDecoder(r.Body).Decode(req)
+
+	switch r.Method {
+	case http.MethodPost:
+		s.addMembers(g, req.UserIDs)
+	case http.MethodDelete:
+		removed := make(map[int]bool)
+		for _, id := range req.UserIDs {
+			removed[id] = true
+		}
+
+		var kept []docbase.SimpleUser
+		for _, u := range g.Users {
+			if !removed[u.ID] {
+				kept = append(kept, u)
+			}
+		}
+		g.Users = kept
+	}
+
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func pageRange(r *http.Request, n int) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	if page < 1 {
+		page = 1
+	}
+
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	start, end := (page-1)*perPage, page*perPage
+	if start > n {
+		start = n
+	}
+	if end > n {
+		end = n
+	}
+
+	return start, end
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":    code,
+		"messages": []string{fmt.Sprintf("%d %s", status, http.StatusText(status))},
+	})
+}
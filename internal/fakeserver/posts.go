@@ -0,0 +1,384 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// AddPost registers a post written by the user authorID and returns its ID.
+// CreatedAt and UpdatedAt default to now, and URL to the post on the server.
+func (s *Server) AddPost(p docbase.Post, authorID int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p.ID == 0 {
+		p.ID = s.newID()
+	}
+
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+
+	if p.UpdatedAt.IsZero() {
+		p.UpdatedAt = p.CreatedAt
+	}
+
+	if p.Scope == "" {
+		p.Scope = docbase.ScopeEveryone
+	}
+
+	if p.URL == "" {
+		p.URL = fmt.Sprintf("%s/posts/%d", s.URL, p.ID)
+	}
+
+	if u, ok := s.users[authorID]; ok {
+		p.User = docbase.SimpleUser{ID: u.ID, Name: u.Name, ProfileImageURL: u.ProfileImageURL}
+	}
+
+	s.posts[p.ID] = &post{Post: p, authorID: authorID}
+
+	return p.ID
+}
+
+// AddComment adds a comment written by the user authorID to the post and returns its ID.
+// CreatedAt defaults to now.
+func (s *Server) AddComment(postID int, c docbase.Comment, authorID int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[postID]
+	if !ok {
+		panic(fmt.Sprintf("fakeserver: no post %d to comment on", postID))
+	}
+
+	if c.ID == 0 {
+		c.ID = s.newID()
+	}
+
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+
+	if u, ok := s.users[authorID]; ok {
+		c.SimpleUser = docbase.SimpleUser{ID: u.ID, Name: u.Name, ProfileImageURL: u.ProfileImageURL}
+	}
+
+	p.Comments = append(p.Comments, c)
+
+	return c.ID
+}
+
+// Post returns a copy of the post, or nil when it does not exist
+func (s *Server) Post(id int) *docbase.Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return nil
+	}
+
+	cp := p.Post
+	return &cp
+}
+
+// Posts returns copies of all posts ordered by ID
+func (s *Server) Posts() []*docbase.Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var posts []*docbase.Post
+	for _, p := range s.sortedPosts() {
+		cp := p.Post
+		posts = append(posts, &cp)
+	}
+
+	return posts
+}
+
+func (s *Server) sortedPosts() []*post {
+	var posts []*post
+	for _, p := range s.posts {
+		posts = append(posts, p)
+	}
+
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts
+}
+
+func (s *Server) servePosts(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.listPosts(w, r)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.createPost(w, r)
+	case len(parts) == 2:
+		s.postDetail(w, r, parts[1])
+	case len(parts) == 3 && (parts[2] == "archive" || parts[2] == "unarchive"):
+		if p := s.post(w, parts[1]); p != nil {
+			p.Archived = parts[2] == "archive"
+			w.WriteHeader(http.StatusOK)
+		}
+	case len(parts) == 3 && parts[2] == "comments":
+		s.createComment(w, r, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "not_found")
+	}
+}
+
+func (s *Server) post(w http.ResponseWriter, rawID string) *post {
+	id, _ := strconv.Atoi(rawID)
+	p, ok := s.posts[id]
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found")
+		return nil
+	}
+
+	return p
+}
+
+func (s *Server) listPosts(w http.ResponseWriter, r *http.Request) {
+	terms := strings.Fields(r.URL.Query().Get("q"))
+
+	posts := []docbase.Post{}
+	for _, p := range s.sortedPosts() {
+		if s.matches(p, terms) {
+			posts = append(posts, p.Post)
+		}
+	}
+
+	start, end := pageRange(r, len(posts))
+
+	res := docbase.PostListResponse{Posts: make([]*docbase.Post, 0, end-start)}
+	for i := start; i < end; i++ {
+		res.Posts = append(res.Posts, &posts[i])
+	}
+
+	res.Meta.Total = len(posts)
+	if end < len(posts) {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		q.Set("page", strconv.Itoa(page+1))
+		res.Meta.NextPage = s.URL + "/posts?" + q.Encode()
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) matches(p *post, terms []string) bool {
+	for _, term := range terms {
+		key, value, found := strings.Cut(term, ":")
+
+		if !found {
+			if !strings.Contains(p.Title, term) && !strings.Contains(p.Body, term) {
+				return false
+			}
+			continue
+		}
+
+		switch key {
+		case "author":
+			if u, ok := s.users[p.authorID]; !ok || u.Username != value {
+				return false
+			}
+		case "tag":
+			if !hasTag(p.Tags, value) {
+				return false
+			}
+		case "group":
+			if !inGroup(p.Groups, value) {
+				return false
+			}
+		case "title":
+			if !strings.Contains(p.Title, value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func hasTag(tags []docbase.Tag, name string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func inGroup(groups []docbase.SimpleGroup, name string) bool {
+	for _, g := range groups {
+		if g.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// postFields are the fields of create and update requests, unset when nil
+type postFields struct {
+	Title       *string    `json:"title"`
+	Body        *string    `json:"body"`
+	Draft       *bool      `json:"draft"`
+	Tags        *[]string  `json:"tags"`
+	Scope       *string    `json:"scope"`
+	Groups      *[]string  `json:"groups"`
+	AuthorID    string     `json:"author_id"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+func (s *Server) apply(p *post, f *postFields) {
+	if f.Title != nil {
+		p.Title = *f.Title
+	}
+
+	if f.Body != nil {
+		p.Body = *f.Body
+	}
+
+	if f.Draft != nil {
+		p.Draft = *f.Draft
+	}
+
+	if f.Tags != nil {
+		p.Tags = []docbase.Tag{}
+		for _, name := range *f.Tags {
+			p.Tags = append(p.Tags, docbase.Tag{Name: name})
+		}
+	}
+
+	if f.Scope != nil {
+		p.Scope = docbase.Scope(*f.Scope)
+	}
+
+	if f.Groups != nil {
+		p.Groups = []docbase.SimpleGroup{}
+		for _, name := range *f.Groups {
+			for _, g := range s.sortedGroups() {
+				if g.Name == name {
+					p.Groups = append(p.Groups, docbase.SimpleGroup{ID: g.ID, Name: g.Name})
+				}
+			}
+		}
+	}
+
+	p.UpdatedAt = time.Now()
+}
+
+func (s *Server) createPost(w http.ResponseWriter, r *http.Request) {
+	f := &postFields{}
+	json.NewDecoder(r.Body).Decode(f)
+
+	p := &post{Post: docbase.Post{ID: s.newID(), Scope: docbase.ScopeEveryone, Tags: []docbase.Tag{}, Groups: []docbase.SimpleGroup{}}}
+	s.apply(p, f)
+
+	p.CreatedAt = p.UpdatedAt
+	if f.PublishedAt != nil {
+		p.CreatedAt = *f.PublishedAt
+	}
+
+	if f.AuthorID != "" {
+		p.authorID, _ = strconv.Atoi(f.AuthorID)
+		if u, ok := s.users[p.authorID]; ok {
+			p.User = docbase.SimpleUser{ID: u.ID, Name: u.Name, ProfileImageURL: u.ProfileImageURL}
+		}
+	}
+
+	p.URL = fmt.Sprintf("%s/posts/%d", s.URL, p.ID)
+	s.posts[p.ID] = p
+
+	writeJSON(w, http.StatusCreated, p.Post)
+}
+
+func (s *Server) postDetail(w http.ResponseWriter, r *http.Request, rawID string) {
+	p := s.post(w, rawID)
+
+	if p == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, p.Post)
+	case http.MethodPatch:
+		f := &postFields{}
+		json.NewDecoder(r.Body).Decode(f)
+		s.apply(p, f)
+		writeJSON(w, http.StatusOK, p.Post)
+	case http.MethodDelete:
+		delete(s.posts, p.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "not_found")
+	}
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request, rawID string) {
+	p := s.post(w, rawID)
+
+	if p == nil {
+		return
+	}
+
+	req := &docbase.CommentCreateRequest{}
+	json.NewDecoder(r.Body).Decode(req)
+
+	c := docbase.Comment{ID: s.newID(), Body: req.Body, CreatedAt: time.Now()}
+	if req.PublishedAt != nil {
+		c.CreatedAt = *req.PublishedAt
+	}
+
+	if id, err := strconv.Atoi(req.AuthorID); err == nil {
+		if u, ok := s.users[id]; ok {
+			c.SimpleUser = docbase.SimpleUser{ID: u.ID, Name: u.Name, ProfileImageURL: u.ProfileImageURL}
+		}
+	}
+
+	p.Comments = append(p.Comments, c)
+
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (s *Server) deleteComment(w http.ResponseWriter, rawID string) {
+	id, _ := strconv.Atoi(rawID)
+
+	for _, p := range s.posts {
+		for i, c := range p.Comments {
+			if c.ID == id {
+				p.Comments = append(p.Comments[:i], p.Comments[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "not_found")
+}
+
+func (s *Server) listTags(w http.ResponseWriter) {
+	seen := make(map[string]bool)
+	tags := []docbase.Tag{}
+
+	for _, p := range s.sortedPosts() {
+		for _, t := range p.Tags {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
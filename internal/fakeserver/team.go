@@ -0,0 +1,90 @@
+package fakeserver
+
+import (
+	"testing"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// Taro and Hanako are the users of a Team when Seed.Users is not set
+var (
+	Taro   = docbase.User{ID: 1, Username: "taro", Name: "Taro"}
+	Hanako = docbase.User{ID: 2, Username: "hanako", Name: "Hanako"}
+)
+
+// Seed is the data NewTeam starts a Server with, added in order
+type Seed struct {
+	// Users are Taro and Hanako when nil
+	Users  []docbase.User
+	Groups []Group
+	Posts  []Post
+}
+
+// Group is a seeded group with the IDs of its members
+type Group struct {
+	Name    string
+	Members []int
+}
+
+// Post is a seeded post written by the user Author. Key names it in Team.PostIDs.
+type Post struct {
+	docbase.Post
+	Key      string
+	Author   int
+	Comments []Comment
+}
+
+// Comment is a seeded comment written by the user Author
+type Comment struct {
+	docbase.Comment
+	Author int
+}
+
+// Team is a seeded Server with a client for it
+type Team struct {
+	*Server
+	Client *docbase.Client
+	// PostIDs maps the keys of the seeded posts to their IDs
+	PostIDs map[string]int
+}
+
+// NewTeam starts a Server with the seed, closed when the test ends
+func NewTeam(t *testing.T, seed Seed) *Team {
+	s := New(t)
+	team := &Team{Server: s, Client: s.Client(t), PostIDs: make(map[string]int)}
+
+	users := seed.Users
+	if users == nil {
+		users = []docbase.User{Taro, Hanako}
+	}
+
+	for _, u := range users {
+		team.AddUser(u)
+	}
+
+	for _, g := range seed.Groups {
+		team.AddGroup(g.Name, g.Members...)
+	}
+
+	for _, p := range seed.Posts {
+		team.Seed(p)
+	}
+
+	return team
+}
+
+// Seed adds a post with its comments and returns its ID, for posts needing data
+// added after NewTeam, like the Markdown of attachments
+func (t *Team) Seed(p Post) int {
+	id := t.AddPost(p.Post, p.Author)
+
+	for _, c := range p.Comments {
+		t.AddComment(id, c.Comment, c.Author)
+	}
+
+	if p.Key != "" {
+		t.PostIDs[p.Key] = id
+	}
+
+	return id
+}
@@ -0,0 +1,43 @@
+// Package postiter pages through the posts matching a search.
+package postiter
+
+import (
+	"github.com/hayashiki/docbase-go"
+)
+
+// perPage is the maximum page size of the API
+const perPage = 100
+
+// All returns all posts matching q. Posts are collected before they are returned,
+// so callers may change them without shifting the pages of the search.
+func All(c *docbase.Client, q string) ([]*docbase.Post, error) {
+	var all []*docbase.Post
+
+	err := Each(c, q, func(p *docbase.Post) error {
+		all = append(all, p)
+		return nil
+	})
+
+	return all, err
+}
+
+// Each calls fn for each post matching q, stopping at the first error
+func Each(c *docbase.Client, q string, fn func(*docbase.Post) error) error {
+	for page := 1; ; page++ {
+		posts, resp, err := c.Posts.List(&docbase.PostListOptions{Q: q, Page: page, PerPage: perPage})
+
+		if err != nil {
+			return err
+		}
+
+		for _, p := range posts {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+
+		if resp.NextPage == "" || len(posts) == 0 {
+			return nil
+		}
+	}
+}
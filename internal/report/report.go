@@ -0,0 +1,46 @@
+// Package report has the formatting the reports of runs share.
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Count returns the number of items for which match is true
+func Count[T any](items []T, match func(T) bool) int {
+	n := 0
+	for _, item := range items {
+		if match(item) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// WriteJSON writes v as indented JSON
+func WriteJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// Heading writes the first line of a printed report, marking dry runs
+func Heading(b *strings.Builder, title string, dryRun bool) {
+	b.WriteString(title)
+	if dryRun {
+		b.WriteString(" [dry run]")
+	}
+	b.WriteString("\n")
+}
+
+// ErrorSuffix returns ": err" to end the line of a failure, or nothing without an error
+func ErrorSuffix(err string) string {
+	if err == "" {
+		return ""
+	}
+
+	return ": " + err
+}
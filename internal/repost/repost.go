@@ -0,0 +1,116 @@
+// Package repost re-creates posts from the copies backups and the trash keep of them.
+package repost
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// Copy is a copy of a post to re-create
+type Copy struct {
+	Post *docbase.Post
+	// Files are the local files of the attachments of the post, in their order
+	Files []string
+	// Author returns the AuthorID to post a post or comment of the user of the copy as,
+	// or "" for the owner of the token. The user IDs are kept when it is nil.
+	Author func(userID int) string
+}
+
+func (c *Copy) author(userID int) string {
+	if c.Author != nil {
+		return c.Author(userID)
+	}
+
+	if userID == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(userID)
+}
+
+// CreatePost uploads the files again and creates the post without notice, with its
+// original author and creation time as PublishedAt and its body pointing to the new files
+func CreatePost(c *docbase.Client, cp *Copy) (*docbase.Post, error) {
+	p := cp.Post
+	body, err := uploadAttachments(c, p.Body, p.Attachments, cp.Files)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req := &docbase.PostCreateRequest{
+		Title:    p.Title,
+		Body:     body,
+		Draft:    docbase.Bool(p.Draft),
+		Notice:   docbase.Bool(false),
+		Scope:    p.Scope,
+		Tags:     p.TagNames(),
+		AuthorID: cp.author(p.User.ID),
+	}
+
+	if !p.CreatedAt.IsZero() {
+		req.PublishedAt = docbase.Time(p.CreatedAt)
+	}
+
+	for _, g := range p.Groups {
+		req.Groups = append(req.Groups, g.Name)
+	}
+
+	created, _, err := c.Posts.Create(req)
+	return created, err
+}
+
+// CreateComments creates the comments of the copy on the post, with their original
+// authors and times, stopping at the first failure
+func CreateComments(c *docbase.Client, postID int, cp *Copy) error {
+	for n, cm := range cp.Post.Comments {
+		if _, _, err := c.Comments.Create(postID, CommentRequest(cp, n)); err != nil {
+			return fmt.Errorf("restoring comment %d: %w", cm.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// CommentRequest returns the request creating the nth comment of the copy again
+func CommentRequest(cp *Copy, n int) *docbase.CommentCreateRequest {
+	cm := cp.Post.Comments[n]
+	req := &docbase.CommentCreateRequest{Body: cm.Body, Notice: docbase.Bool(false), AuthorID: cp.author(cm.SimpleUser.ID)}
+
+	if !cm.CreatedAt.IsZero() {
+		req.PublishedAt = docbase.Time(cm.CreatedAt)
+	}
+
+	return req
+}
+
+// uploadAttachments uploads the files and replaces the old URLs of the attachments in the body
+func uploadAttachments(c *docbase.Client, body string, attachments []docbase.Attachment, files []string) (string, error) {
+	if len(attachments) == 0 {
+		return body, nil
+	}
+
+	if len(files) != len(attachments) {
+		return "", fmt.Errorf("%d files for %d attachments", len(files), len(attachments))
+	}
+
+	uploaded, _, err := c.Attachments.Upload(files)
+
+	if err != nil {
+		return "", fmt.Errorf("uploading attachments: %w", err)
+	}
+
+	if len(*uploaded) != len(attachments) {
+		return "", fmt.Errorf("uploaded %d of %d attachments", len(*uploaded), len(attachments))
+	}
+
+	var pairs []string
+	for i, a := range attachments {
+		pairs = append(pairs, a.URL, (*uploaded)[i].URL)
+	}
+
+	return strings.NewReplacer(pairs...).Replace(body), nil
+}
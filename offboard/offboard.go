@@ -0,0 +1,241 @@
+// Package offboard removes a leaving user from the team's groups and takes care of their posts.
+package offboard
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+	"github.com/hayashiki/docbase-go/internal/report"
+)
+
+// Actions recorded in the report
+const (
+	ActionRemoved     = "removed"     // removed from the group
+	ActionListed      = "listed"      // the post is left for review
+	ActionRepublished = "republished" // copied under the service account and the original archived
+)
+
+// GroupEntry records the removal from a group
+type GroupEntry struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PostEntry records what was done with a post
+type PostEntry struct {
+	ID        int           `json:"id"`
+	Title     string        `json:"title"`
+	URL       string        `json:"url"`
+	Scope     docbase.Scope `json:"scope"`
+	Draft     bool          `json:"draft"`
+	Action    string        `json:"action"`
+	NewPostID int           `json:"new_post_id,omitempty"`
+	NewURL    string        `json:"new_url,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Report is the audit record of an offboarding
+type Report struct {
+	UserID     int          `json:"user_id"`
+	Username   string       `json:"username"`
+	DryRun     bool         `json:"dry_run"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Groups     []GroupEntry `json:"groups"`
+	Posts      []PostEntry  `json:"posts"`
+}
+
+// Failures returns the number of actions which failed
+func (r *Report) Failures() int {
+	return report.Count(r.Groups, func(g GroupEntry) bool { return g.Error != "" }) +
+		report.Count(r.Posts, func(p PostEntry) bool { return p.Error != "" })
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// Print writes the report in a readable form
+func (r *Report) Print(w io.Writer) error {
+	var b strings.Builder
+
+	report.Heading(&b, fmt.Sprintf("Offboarding @%s (#%d)", r.Username, r.UserID), r.DryRun)
+
+	fmt.Fprintf(&b, "Groups (%d):\n", len(r.Groups))
+	for _, g := range r.Groups {
+		fmt.Fprintf(&b, "  %s %s%s\n", g.Action, g.Name, report.ErrorSuffix(g.Error))
+	}
+
+	fmt.Fprintf(&b, "Posts (%d):\n", len(r.Posts))
+	for _, p := range r.Posts {
+		state := string(p.Scope)
+		if p.Draft {
+			state += ", draft"
+		}
+
+		fmt.Fprintf(&b, "  %s #%d %s (%s)", p.Action, p.ID, p.Title, state)
+		if p.NewPostID != 0 {
+			fmt.Fprintf(&b, " -> #%d", p.NewPostID)
+		}
+		fmt.Fprintf(&b, "%s\n", report.ErrorSuffix(p.Error))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Offboarder runs offboardings
+type Offboarder struct {
+	client *docbase.Client
+	now    func() time.Time
+
+	// DryRun records what would be done without changing anything
+	DryRun bool
+	// ServiceAccountID is the user ID to republish the posts shared with others under.
+	// Posts are only listed when zero. Drafts and private posts are always only listed.
+	ServiceAccountID int
+}
+
+// New returns an Offboarder which only lists posts
+func New(c *docbase.Client) *Offboarder {
+	return &Offboarder{client: c, now: time.Now}
+}
+
+// Run offboards the user given as "@username", a username or an ID.
+// A group or post which cannot be handled keeps its error in the report and the
+// offboarding goes on, so Report.Failures tells what is left to do by hand.
+func (o *Offboarder) Run(user string) (*Report, error) {
+	u, err := o.client.UserDirectory.Lookup(user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{UserID: u.ID, Username: u.Username, DryRun: o.DryRun, StartedAt: o.now()}
+
+	groups, err := o.groupsOf(u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		entry := GroupEntry{ID: g.ID, Name: g.Name, Action: ActionRemoved}
+
+		if !o.DryRun {
+			if _, err := o.client.GroupUsers.Delete(g.ID, &docbase.GroupUserCreateRequest{UserIDs: []int{u.ID}}); err != nil {
+				entry.Error = err.Error()
+			}
+		}
+
+		report.Groups = append(report.Groups, entry)
+	}
+
+	posts, err := postiter.All(o.client, "author:"+u.Username)
+
+	if err != nil {
+		return report, err
+	}
+
+	for _, p := range posts {
+		report.Posts = append(report.Posts, o.handlePost(p))
+	}
+
+	report.FinishedAt = o.now()
+
+	if n := report.Failures(); n > 0 {
+		return report, fmt.Errorf("%d actions failed", n)
+	}
+
+	return report, nil
+}
+
+// groupsOf fetches the current groups of the user rather than the cached ones
+func (o *Offboarder) groupsOf(u *docbase.User) ([]docbase.SimpleGroup, error) {
+	for page := 1; ; page++ {
+		users, _, err := o.client.Users.List(&docbase.UserListOptions{Q: u.Username, Page: page, PerPage: 100, IncludeUserGroups: true})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, found := range *users {
+			if found.ID == u.ID {
+				return found.Groups, nil
+			}
+		}
+
+		if len(*users) < 100 {
+			return nil, fmt.Errorf("%w: @%s", docbase.ErrUserNotFound, u.Username)
+		}
+	}
+}
+
+// handlePost republishes posts shared with others when a service account is given
+func (o *Offboarder) handlePost(p *docbase.Post) PostEntry {
+	entry := PostEntry{ID: p.ID, Title: p.Title, URL: p.URL, Scope: p.Scope, Draft: p.Draft, Action: ActionListed}
+
+	if o.ServiceAccountID == 0 || p.Draft || p.IsPrivate() {
+		return entry
+	}
+
+	entry.Action = ActionRepublished
+
+	if o.DryRun {
+		return entry
+	}
+
+	created, err := o.republish(p)
+
+	if created != nil {
+		entry.NewPostID = created.ID
+		entry.NewURL = created.URL
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+// republish copies the post under the service account, as the API cannot change the author,
+// and archives the original, which keeps its comments and stars
+func (o *Offboarder) republish(p *docbase.Post) (*docbase.Post, error) {
+	req := &docbase.PostCreateRequest{
+		Title:       p.Title,
+		Body:        p.Body,
+		Notice:      docbase.Bool(false),
+		Scope:       p.Scope,
+		AuthorID:    strconv.Itoa(o.ServiceAccountID),
+		PublishedAt: docbase.Time(p.CreatedAt),
+	}
+
+	for _, t := range p.Tags {
+		req.Tags = append(req.Tags, t.Name)
+	}
+
+	for _, g := range p.Groups {
+		req.Groups = append(req.Groups, g.Name)
+	}
+
+	created, _, err := o.client.Posts.Create(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := o.client.Posts.Archive(p.ID); err != nil {
+		return created, fmt.Errorf("archiving original after republishing as #%d: %w", created.ID, err)
+	}
+
+	return created, nil
+}
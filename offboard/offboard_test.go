@@ -0,0 +1,116 @@
+package offboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+var created = time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+
+var team = fakeserver.Seed{
+	Users:  []docbase.User{fakeserver.Taro, fakeserver.Hanako, {ID: 9, Username: "bot", Name: "Service Account"}},
+	Groups: []fakeserver.Group{{Name: "infra", Members: []int{1, 2}}, {Name: "design", Members: []int{1}}},
+	Posts: []fakeserver.Post{
+		{Key: "public", Author: 1, Post: docbase.Post{Title: "Runbook", Tags: []docbase.Tag{{Name: "ops"}}, CreatedAt: created}},
+		{Key: "group", Author: 1, Post: docbase.Post{Title: "Infra notes", Scope: docbase.ScopeGroup, Groups: []docbase.SimpleGroup{{Name: "infra"}}}},
+		{Key: "private", Author: 1, Post: docbase.Post{Title: "Memo", Scope: docbase.ScopePrivate}},
+		{Key: "draft", Author: 1, Post: docbase.Post{Title: "WIP", Draft: true}},
+		{Key: "other", Author: 2, Post: docbase.Post{Title: "Not mine"}},
+	},
+}
+
+func TestOffboarder_Run(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	o := New(s.Client)
+	o.ServiceAccountID = 9
+
+	report, err := o.Run("@taro")
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if len(s.Members("design")) != 0 || len(s.Members("infra")) != 1 {
+		t.Errorf("taro should be removed from all groups: infra %v, design %v", s.Members("infra"), s.Members("design"))
+	}
+
+	if len(report.Groups) != 2 || report.Groups[0].Action != ActionRemoved {
+		t.Errorf("Groups are %+v", report.Groups)
+	}
+
+	actions := make(map[int]PostEntry)
+	for _, p := range report.Posts {
+		actions[p.ID] = p
+	}
+
+	if len(actions) != 4 {
+		t.Fatalf("Posts are %+v", report.Posts)
+	}
+
+	for _, name := range []string{"private", "draft"} {
+		if got := actions[s.PostIDs[name]].Action; got != ActionListed {
+			t.Errorf("%s post should be listed but is %s", name, got)
+		}
+	}
+
+	public := actions[s.PostIDs["public"]]
+	if public.Action != ActionRepublished || public.NewPostID == 0 {
+		t.Fatalf("public post should be republished: %+v", public)
+	}
+
+	copied := s.Post(public.NewPostID)
+	if copied.User.ID != 9 || copied.Title != "Runbook" || len(copied.Tags) != 1 || !copied.CreatedAt.Equal(s.Post(s.PostIDs["public"]).CreatedAt) {
+		t.Errorf("Republished post is %+v", copied)
+	}
+
+	if !s.Post(s.PostIDs["public"]).Archived {
+		t.Errorf("Original post should be archived")
+	}
+
+	group := s.Post(actions[s.PostIDs["group"]].NewPostID)
+	if group == nil || group.Scope != docbase.ScopeGroup || len(group.Groups) != 1 || group.Groups[0].Name != "infra" {
+		t.Errorf("Group post should keep its groups: %+v", group)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON returned an error: %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Username != "taro" || len(decoded.Posts) != 4 {
+		t.Errorf("WriteJSON wrote %s", buf.String())
+	}
+}
+
+func TestOffboarder_DryRun(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	o := New(s.Client)
+	o.DryRun = true
+	o.ServiceAccountID = 9
+
+	report, err := o.Run("taro")
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("DryRun should not change anything: %v", reqs)
+	}
+
+	var out strings.Builder
+	report.Print(&out)
+
+	for _, want := range []string{"[dry run]", "removed infra", "republished", "listed", "(private)", "draft"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Print should contain %q:\n%s", want, out.String())
+		}
+	}
+}
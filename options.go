@@ -0,0 +1,296 @@
+package docbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option configures a Client created by NewClientWithOptions.
+type Option func(*clientOptions) error
+
+// clientOptions collects options before they are applied to a Client
+type clientOptions struct {
+	baseURL         *url.URL
+	httpClient      *http.Client
+	userAgentSuffix string
+	timeout         time.Duration
+	retryPolicy     *RetryPolicy
+	limiter         Limiter
+	logger          Logger
+	cache           *httpCache
+	coalesce        bool
+}
+
+// Limiter throttles outgoing requests. *rate.Limiter of golang.org/x/time/rate satisfies it.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Logger receives a line per request. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// maxBackoff bounds the backoff of policies without MaxWait
+const maxBackoff = 5 * time.Minute
+
+// RetryPolicy controls how requests failed with network errors,
+// 429 Too Many Requests or 5xx responses are retried.
+// POST and PATCH requests are retried on 429 only, as the server may have
+// created the post or comment before a network error or 5xx.
+type RetryPolicy struct {
+	MaxRetries  int           // number of retries after the first attempt
+	MinWait     time.Duration // backoff before the first retry, doubled for each retry
+	MaxWait     time.Duration // upper bound of a single backoff, 5 minutes when zero but for the reset of a rate limit
+	RetryWrites bool          // retries POST and PATCH on network errors and 5xx too, at the risk of duplicates
+}
+
+// WithBaseURL overrides the API endpoint, e.g. for on-prem proxies or fakes.
+func WithBaseURL(rawURL string) Option {
+	return func(o *clientOptions) error {
+		u, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+
+		if err != nil {
+			return fmt.Errorf("invalid base url: %w", err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid base url: %q", rawURL)
+		}
+
+		o.baseURL = u
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) error {
+		if httpClient == nil {
+			return errors.New("http client must not be nil")
+		}
+
+		o.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithUserAgent appends suffix to the default user agent.
+func WithUserAgent(suffix string) Option {
+	return func(o *clientOptions) error {
+		if strings.TrimSpace(suffix) == "" {
+			return errors.New("user agent suffix must not be empty")
+		}
+
+		o.userAgentSuffix = suffix
+		return nil
+	}
+}
+
+// WithTimeout sets the default timeout of each HTTP request.
+// The HTTP client is copied, so a shared client is never modified.
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		if d <= 0 {
+			return fmt.Errorf("timeout must be positive: %v", d)
+		}
+
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithRetryPolicy enables retries of failed requests.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) error {
+		if policy.MaxRetries < 0 || policy.MinWait < 0 || policy.MaxWait < 0 {
+			return fmt.Errorf("invalid retry policy: %+v", policy)
+		}
+
+		if policy.MaxWait != 0 && policy.MaxWait < policy.MinWait {
+			return fmt.Errorf("invalid retry policy: max wait %v is less than min wait %v", policy.MaxWait, policy.MinWait)
+		}
+
+		o.retryPolicy = &policy
+		return nil
+	}
+}
+
+// WithLimiter throttles requests with l before they are sent.
+func WithLimiter(l Limiter) Option {
+	return func(o *clientOptions) error {
+		if l == nil {
+			return errors.New("limiter must not be nil")
+		}
+
+		o.limiter = l
+		return nil
+	}
+}
+
+// WithLogger logs every request with l.
+func WithLogger(l Logger) Option {
+	return func(o *clientOptions) error {
+		if l == nil {
+			return errors.New("logger must not be nil")
+		}
+
+		o.logger = l
+		return nil
+	}
+}
+
+func (o *clientOptions) apply(c *Client) {
+	if o.baseURL != nil {
+		c.BaseURL = o.baseURL
+	}
+
+	if o.httpClient != nil {
+		c.Client = o.httpClient
+	}
+
+	if o.timeout > 0 {
+		hc := *c.Client
+		hc.Timeout = o.timeout
+		c.Client = &hc
+	}
+
+	if o.userAgentSuffix != "" {
+		c.userAgent = userAgent + " " + o.userAgentSuffix
+	}
+
+	c.retryPolicy = o.retryPolicy
+	c.limiter = o.limiter
+	c.logger = o.logger
+	c.cache = o.cache
+
+	if o.coalesce {
+		c.flight = &flightGroup{}
+	}
+}
+
+// send sends r, waiting for the limiter and retrying according to the retry policy
+func (c *Client) send(r *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(r.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		req := r
+		if attempt > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+
+			if err != nil {
+				return nil, err
+			}
+
+			req = r.Clone(r.Context())
+			req.Body = body
+		}
+
+		c.logf("docbase: %s %s", req.Method, sanitizeURL(req.URL))
+
+		resp, err := c.Client.Do(req)
+
+		// a body read by the first attempt cannot be sent again without GetBody
+		if !replayable(r) || !c.retryPolicy.shouldRetry(attempt, r.Method, resp, err) {
+			return resp, err
+		}
+
+		wait := c.retryPolicy.backoff(attempt, resp)
+		c.logf("docbase: retrying %s %s in %v (attempt %d)", req.Method, sanitizeURL(req.URL), wait, attempt+1)
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// replayable reports whether the body of r can be sent again
+func replayable(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, method string, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.MaxRetries {
+		return false
+	}
+
+	// a rejected request was not processed, whatever its method
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if !p.RetryWrites && !idempotent(method) {
+		return false
+	}
+
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// idempotent reports whether sending a request of the method twice has the effect of sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
+
+// backoff waits until the rate limit resets on 429, otherwise grows exponentially up to
+// MaxWait, or maxBackoff when it is not set
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	limit := p.MaxWait
+	if limit <= 0 {
+		limit = maxBackoff
+	}
+
+	wait := p.MinWait << uint(attempt)
+	if attempt >= 63 || wait>>uint(attempt) != p.MinWait || wait > limit {
+		// overflowed or beyond the bound
+		wait = limit
+	}
+
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if reset := parseRate(resp).Reset; !reset.IsZero() {
+			wait = time.Until(reset.Time)
+		}
+	}
+
+	if p.MaxWait > 0 && wait > p.MaxWait {
+		wait = p.MaxWait
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait
+}
@@ -0,0 +1,282 @@
+package docbase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countLimiter struct {
+	count int
+}
+
+func (l *countLimiter) Wait(ctx context.Context) error {
+	l.count++
+	return nil
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	hc := &http.Client{}
+
+	cli, err := NewClientWithOptions("fakeTeam", "fakeToken",
+		WithBaseURL("http://localhost:8080/proxy/"),
+		WithHTTPClient(hc),
+		WithUserAgent("my-bot/1.0"),
+		WithTimeout(3*time.Second),
+	)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if got, want := cli.BaseURL.String(), "http://localhost:8080/proxy"; got != want {
+		t.Errorf("BaseURL is %v, want %v", got, want)
+	}
+
+	if got, want := cli.Client.Timeout, 3*time.Second; got != want {
+		t.Errorf("Timeout is %v, want %v", got, want)
+	}
+
+	if hc.Timeout != 0 {
+		t.Errorf("WithTimeout modified given http client: %v", hc.Timeout)
+	}
+
+	req, _ := cli.NewRequest(http.MethodGet, "/posts", nil)
+
+	if got, want := req.URL.String(), "http://localhost:8080/proxy/posts"; got != want {
+		t.Errorf("NewRequest URL is %v, want %v", got, want)
+	}
+
+	testHeader(t, req, "USER_AGENT", userAgent+" my-bot/1.0")
+}
+
+func TestNewClientWithOptions_Invalid(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		team  string
+		token string
+		opt   Option
+	}{
+		{"EmptyTeam", "", "fakeToken", nil},
+		{"EmptyToken", "fakeTeam", "", nil},
+		{"BaseURLWithoutScheme", "fakeTeam", "fakeToken", WithBaseURL("localhost:8080")},
+		{"NilHTTPClient", "fakeTeam", "fakeToken", WithHTTPClient(nil)},
+		{"EmptyUserAgent", "fakeTeam", "fakeToken", WithUserAgent(" ")},
+		{"NegativeTimeout", "fakeTeam", "fakeToken", WithTimeout(-time.Second)},
+		{"NegativeRetries", "fakeTeam", "fakeToken", WithRetryPolicy(RetryPolicy{MaxRetries: -1})},
+		{"MaxWaitLessThanMinWait", "fakeTeam", "fakeToken", WithRetryPolicy(RetryPolicy{MinWait: time.Second, MaxWait: time.Millisecond})},
+		{"NilLimiter", "fakeTeam", "fakeToken", WithLimiter(nil)},
+		{"NilLogger", "fakeTeam", "fakeToken", WithLogger(nil)},
+	}
+	for _, tc := range testCases {
+		var opts []Option
+		if tc.opt != nil {
+			opts = append(opts, tc.opt)
+		}
+
+		if _, err := NewClientWithOptions(tc.team, tc.token, opts...); err == nil {
+			t.Errorf("%s: NewClientWithOptions returns no error", tc.desc)
+		}
+	}
+}
+
+func TestClient_Do_Retry(t *testing.T) {
+	var calls int
+	var bodies []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+
+	limiter := &countLimiter{}
+	buf := &bytes.Buffer{}
+
+	cli, err := NewClientWithOptions("fakeTeam", "fakeToken",
+		WithBaseURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 3, MinWait: time.Millisecond, MaxWait: 5 * time.Millisecond}),
+		WithLimiter(limiter),
+		WithLogger(log.New(buf, "", 0)),
+	)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	req, _ := cli.NewRequest(http.MethodPut, "/sample", struct{ Foo string }{Foo: "Bar"})
+
+	resp, err := cli.Do(req, nil)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Do response code = %v, expected %v", resp.StatusCode, http.StatusOK)
+	}
+
+	if calls != 3 {
+		t.Errorf("Server called %d times, want 3", calls)
+	}
+
+	for i, b := range bodies {
+		if b != `{"Foo":"Bar"}` {
+			t.Errorf("Body of attempt %d is %v", i, b)
+		}
+	}
+
+	if limiter.count != 3 {
+		t.Errorf("Limiter waited %d times, want 3", limiter.count)
+	}
+
+	if got := strings.Count(buf.String(), "retrying"); got != 2 {
+		t.Errorf("Logged %d retries, want 2: %s", got, buf.String())
+	}
+}
+
+func TestClient_Do_NoRetryOfPost(t *testing.T) {
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		policy RetryPolicy
+		want   int
+	}{
+		{RetryPolicy{MaxRetries: 3, MinWait: time.Millisecond}, 1},
+		{RetryPolicy{MaxRetries: 3, MinWait: time.Millisecond, RetryWrites: true}, 4},
+	} {
+		calls = 0
+		cli, _ := NewClientWithOptions("fakeTeam", "fakeToken", WithBaseURL(srv.URL), WithRetryPolicy(tc.policy))
+
+		req, _ := cli.NewRequest(http.MethodPost, "/posts", struct{ Foo string }{Foo: "Bar"})
+
+		if _, err := cli.Do(req, nil); err == nil {
+			t.Errorf("Do should return the error of the last attempt")
+		}
+
+		if calls != tc.want {
+			t.Errorf("Server called %d times with %+v, want %d", calls, tc.policy, tc.want)
+		}
+	}
+}
+
+func TestClient_Do_RetryPostRateLimited(t *testing.T) {
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error": "too_many_requests", "messages": ["Too Many Requests"]}`)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+
+	cli, _ := NewClientWithOptions("fakeTeam", "fakeToken",
+		WithBaseURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1, MinWait: time.Millisecond, MaxWait: time.Millisecond}),
+	)
+
+	req, _ := cli.NewRequest(http.MethodPost, "/posts", struct{ Foo string }{Foo: "Bar"})
+
+	if _, err := cli.Do(req, nil); err != nil || calls != 2 {
+		t.Errorf("Do returned %v after %d calls, want no error after 2", err, calls)
+	}
+}
+
+func TestClient_Do_RetryExhausted(t *testing.T) {
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": "too_many_requests", "messages": ["Too Many Requests"]}`)
+	}))
+	defer srv.Close()
+
+	cli, _ := NewClientWithOptions("fakeTeam", "fakeToken",
+		WithBaseURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1, MinWait: time.Millisecond}),
+	)
+
+	req, _ := cli.NewRequest(http.MethodGet, "/sample", nil)
+
+	_, err := cli.Do(req, nil)
+
+	if _, ok := err.(*RateLimitError); !ok {
+		t.Errorf("Error should be of type RateLimitError but is %T: %+v", err, err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Server called %d times, want 2", calls)
+	}
+}
+
+func TestClient_Do_NoRetryWithoutGetBody(t *testing.T) {
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cli, _ := NewClientWithOptions("fakeTeam", "fakeToken",
+		WithBaseURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 3, MinWait: time.Millisecond}),
+	)
+
+	req, _ := cli.NewRequest(http.MethodPut, "/sample", struct{ Foo string }{Foo: "Bar"})
+	req.GetBody = nil
+
+	if _, err := cli.Do(req, nil); err == nil {
+		t.Errorf("Do should return the error of the first attempt")
+	}
+
+	if calls != 1 {
+		t.Errorf("Server called %d times, want 1", calls)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"First", RetryPolicy{MinWait: time.Second}, 0, time.Second},
+		{"Doubled", RetryPolicy{MinWait: time.Second}, 3, 8 * time.Second},
+		{"MaxWait", RetryPolicy{MinWait: time.Second, MaxWait: 5 * time.Second}, 3, 5 * time.Second},
+		{"OverflowWithoutMaxWait", RetryPolicy{MinWait: time.Second}, 40, maxBackoff},
+		{"ShiftedOutWithoutMaxWait", RetryPolicy{MinWait: time.Second}, 70, maxBackoff},
+		{"OverflowWithMaxWait", RetryPolicy{MinWait: time.Second, MaxWait: time.Minute}, 40, time.Minute},
+	}
+	for _, tc := range testCases {
+		if got := tc.policy.backoff(tc.attempt, nil); got != tc.want {
+			t.Errorf("%s: backoff is %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
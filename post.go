@@ -1,6 +1,10 @@
 package docbase
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -16,7 +20,8 @@ type PostService interface {
 	Get(postID int) (*Post, *Response, error)
 	Create(postRequest *PostCreateRequest) (*Post, *Response, error)
 	Update(postID int, postUpdateRequest *PostUpdateRequest) (*Post, *Response, error)
-	Delete(postID string) (*Response, error)
+	UpdateIfUnchanged(postID int, expected PostVersion, postUpdateRequest *PostUpdateRequest, merge MergeFunc) (*Post, *Response, error)
+	Delete(postID int) (*Response, error)
 	Archive(postID int) (*Response, error)
 	Unarchive(postID int) (*Response, error)
 }
@@ -26,29 +31,75 @@ type postService struct {
 	client *Client
 }
 
-// PostCreateRequest identifies Post for the Create request
+// PostCreateRequest identifies Post for the Create request.
+// Unset optional fields are omitted so that the server applies its defaults.
 type PostCreateRequest struct {
-	Title       string    `json:"title"`
-	Body        string    `json:"body"`
-	Draft       bool      `json:"draft"`  // optional, default: false
-	Notice      bool      `json:"notice"` // optional, default: true
-	Tags        []string  `json:"tags"`
-	Scope       string    `json:"scope"` // optional, default: everyone
-	Groups      []string  `json:"groups"`
-	AuthorID    string    `json:"author_id"`
-	PublishedAt time.Time `json:"published_at"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Draft       *bool      `json:"draft,omitempty"`  // optional, default: false
+	Notice      *bool      `json:"notice,omitempty"` // optional, default: true
+	Tags        []string   `json:"tags,omitempty"`
+	Scope       Scope      `json:"scope,omitempty"` // optional, default: everyone
+	Groups      []string   `json:"groups,omitempty"`
+	AuthorID    string     `json:"author_id,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
 }
 
-// PostUpdateRequest identifies Post for the Update request
+// Validate checks the scope and groups combination before the request is sent
+func (r *PostCreateRequest) Validate() error {
+	return validateScope(r.Scope, r.Groups)
+}
+
+// PostUpdateRequest identifies Post for the Update request.
+// Only set fields are sent, so the others stay unchanged on the server.
+// Pointer fields are unset when nil, see String, Bool and Time.
+// Slices are unset when nil, and a non-nil empty slice clears them.
 type PostUpdateRequest struct {
-	Title       string    `json:"title"`
-	Body        string    `json:"body"`
-	Draft       bool      `json:"draft"`  // optional, default: false
-	Notice      bool      `json:"notice"` // optional, default: true
-	Tags        []string  `json:"tags"`
-	Scope       string    `json:"scope"` // optional, default: everyone
-	Groups      []string  `json:"groups"`
-	PublishedAt time.Time `json:"published_at"`
+	Title       *string    `json:"title,omitempty"`
+	Body        *string    `json:"body,omitempty"`
+	Draft       *bool      `json:"draft,omitempty"`
+	Notice      *bool      `json:"notice,omitempty"`
+	Tags        []string   `json:"-"`
+	Scope       *Scope     `json:"scope,omitempty"`
+	Groups      []string   `json:"-"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// Validate checks the scope and groups combination before the request is sent.
+// Groups may be left unset when changing the scope to ScopeGroup, keeping the current groups.
+func (r *PostUpdateRequest) Validate() error {
+	if r.Scope == nil {
+		return nil
+	}
+
+	if *r.Scope == ScopeGroup {
+		if r.Groups != nil && len(r.Groups) == 0 {
+			return fmt.Errorf("groups are required for scope %q", ScopeGroup)
+		}
+		return nil
+	}
+
+	return validateScope(*r.Scope, r.Groups)
+}
+
+// MarshalJSON omits unset fields
+func (r PostUpdateRequest) MarshalJSON() ([]byte, error) {
+	type alias PostUpdateRequest
+	v := struct {
+		alias
+		Tags   *[]string `json:"tags,omitempty"`
+		Groups *[]string `json:"groups,omitempty"`
+	}{alias: alias(r)}
+
+	if r.Tags != nil {
+		v.Tags = &r.Tags
+	}
+
+	if r.Groups != nil {
+		v.Groups = &r.Groups
+	}
+
+	return json.Marshal(v)
 }
 
 type PostListResponse struct {
@@ -69,8 +120,9 @@ type Post struct {
 	Archived      bool          `json:"archived"`
 	URL           string        `json:"url"`
 	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
 	Tags          []Tag         `json:"tags"`
-	Scope         string        `json:"scope"`
+	Scope         Scope         `json:"scope"`
 	SharingURL    string        `json:"sharing_url"`
 	User          SimpleUser    `json:"user"`
 	StarsCount    int           `json:"stars_count"`
@@ -80,6 +132,112 @@ type Post struct {
 	Attachments   []Attachment  `json:"attachments"`
 }
 
+// IsPublic reports whether everyone in the team can read the post
+func (p *Post) IsPublic() bool {
+	return p.Scope == ScopeEveryone
+}
+
+// IsPrivate reports whether only the author can read the post
+func (p *Post) IsPrivate() bool {
+	return p.Scope == ScopePrivate
+}
+
+// VisibleToGroup reports whether members of the group can read the post
+func (p *Post) VisibleToGroup(name string) bool {
+	switch p.Scope {
+	case ScopeEveryone:
+		return true
+	case ScopeGroup:
+		for _, g := range p.Groups {
+			if g.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ErrConflict is matched by ConflictError with errors.Is
+var ErrConflict = errors.New("post was changed since it was fetched")
+
+// maxConflictRetries limits merges of UpdateIfUnchanged
+const maxConflictRetries = 3
+
+// PostVersion identifies a revision of a post for UpdateIfUnchanged
+type PostVersion struct {
+	UpdatedAt time.Time
+	BodyHash  string
+}
+
+// LastUpdatedAt returns when p was last updated, which is its creation for posts
+// without updated_at such as those of old exports
+func (p *Post) LastUpdatedAt() time.Time {
+	if p.UpdatedAt.IsZero() {
+		return p.CreatedAt
+	}
+
+	return p.UpdatedAt
+}
+
+// TagNames returns the names of the tags of p, as taken by PostCreateRequest and PostUpdateRequest
+func (p *Post) TagNames() []string {
+	names := make([]string, 0, len(p.Tags))
+	for _, t := range p.Tags {
+		names = append(names, t.Name)
+	}
+
+	return names
+}
+
+// Version returns the revision of p
+func (p *Post) Version() PostVersion {
+	sum := sha256.Sum256([]byte(p.Title + "\x00" + p.Body))
+
+	return PostVersion{
+		UpdatedAt: p.UpdatedAt,
+		BodyHash:  hex.EncodeToString(sum[:]),
+	}
+}
+
+// matches compares the timestamps when both are known and the title and body hash
+func (v PostVersion) matches(current PostVersion) bool {
+	if !v.UpdatedAt.IsZero() && !current.UpdatedAt.IsZero() && !v.UpdatedAt.Equal(current.UpdatedAt) {
+		return false
+	}
+
+	return v.BodyHash == "" || v.BodyHash == current.BodyHash
+}
+
+// ConflictError is returned by UpdateIfUnchanged when the post was changed by someone else
+type ConflictError struct {
+	PostID   int
+	Expected PostVersion
+	Current  *Post // the post as it is on the server
+	MergeErr error // the error of the MergeFunc which gave up, if any
+}
+
+func (e *ConflictError) Error() string {
+	if e.MergeErr != nil {
+		return fmt.Sprintf("post %d: %v: merge failed: %v", e.PostID, ErrConflict, e.MergeErr)
+	}
+
+	return fmt.Sprintf("post %d: %v", e.PostID, ErrConflict)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Unwrap returns MergeErr, so errors of the MergeFunc are matched with errors.Is too
+func (e *ConflictError) Unwrap() error {
+	return e.MergeErr
+}
+
+// MergeFunc rebuilds an update request against the current post after a conflict.
+// Returning an error gives up the update.
+type MergeFunc func(current *Post, postUpdateRequest *PostUpdateRequest) (*PostUpdateRequest, error)
+
 // PostListOptions identifies as query params of Post List request
 type PostListOptions struct {
 	Q       string `url:"q,omitempty"`
@@ -95,182 +253,95 @@ func (opts *PostListOptions) SetDefaultSort() {
 
 // List Post
 func (s *postService) List(opts *PostListOptions) ([]*Post, *Response, error) {
-
-	u, err := url.Parse("/posts")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
 	opts.SetDefaultSort()
-	q := u.Query()
+	q := url.Values{}
 	q.Set("per_page", strconv.Itoa(opts.PerPage))
 	q.Set("page", strconv.Itoa(opts.Page))
 	q.Set("q", opts.Q)
-	u.RawQuery = q.Encode()
 
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
+	posts, resp, err := doJSON[PostListResponse](s.client, http.MethodGet, withQuery("/posts", q), nil)
 
 	if err != nil {
-		return nil, nil, err
-	}
-
-	posts := &PostListResponse{}
-	resp, err := s.client.Do(req, posts)
-
-	if err != nil {
-		return nil, nil, err
+		return nil, resp, err
 	}
 
 	resp.Total = posts.Meta.Total
 	resp.NextPage = posts.Meta.NextPage
 	resp.PreviousPage = posts.Meta.PreviousPage
 
-	return posts.Posts, resp, err
+	return posts.Posts, resp, nil
 }
 
 // Get Post
 func (s *postService) Get(postID int) (*Post, *Response, error) {
-
-	u, err := url.Parse(fmt.Sprintf("/posts/%d", postID))
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	post := &Post{}
-	resp, err := s.client.Do(req, post)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return post, resp, err
+	return doJSON[Post](s.client, http.MethodGet, fmt.Sprintf("/posts/%d", postID), nil)
 }
 
 // Create Post
 func (s *postService) Create(memoReq *PostCreateRequest) (*Post, *Response, error) {
-	u, err := url.Parse("/posts")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodPost, u.String(), memoReq)
-
-	if err != nil {
+	if err := memoReq.Validate(); err != nil {
 		return nil, nil, err
 	}
 
-	mResp := &Post{}
-	resp, err := s.client.Do(req, mResp)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return mResp, resp, err
+	return doJSON[Post](s.client, http.MethodPost, "/posts", memoReq)
 }
 
 // Update Post
 func (s *postService) Update(postID int, postUpdateRequest *PostUpdateRequest) (*Post, *Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/posts/%d", postID))
-	if err != nil {
+	if err := postUpdateRequest.Validate(); err != nil {
 		return nil, nil, err
 	}
 
-	req, err := s.client.NewRequest(http.MethodPatch, u.String(), postUpdateRequest)
+	return doJSON[Post](s.client, http.MethodPatch, fmt.Sprintf("/posts/%d", postID), postUpdateRequest)
+}
 
-	if err != nil {
-		return nil, nil, err
-	}
+// UpdateIfUnchanged updates the post only when it still matches expected, which is
+// usually taken from a previously fetched post with Post.Version. The post is re-fetched
+// from the server right before patching, bypassing the response cache, so the check
+// narrows the window for lost updates but cannot close it.
+// On conflict, it returns a *ConflictError, or when merge is given, retries with the
+// request merge returns. A merge returning an error ends with a *ConflictError
+// holding it as MergeErr.
+func (s *postService) UpdateIfUnchanged(postID int, expected PostVersion, postUpdateRequest *PostUpdateRequest, merge MergeFunc) (*Post, *Response, error) {
+	for retries := 0; ; retries++ {
+		current, resp, err := GetUncachedJSON[Post](s.client, fmt.Sprintf("/posts/%d", postID), nil)
 
-	mResp := &Post{}
-	resp, err := s.client.Do(req, mResp)
-	if err != nil {
-		return nil, nil, err
-	}
+		if err != nil {
+			return nil, resp, err
+		}
 
-	if err != nil {
-		return nil, nil, err
-	}
+		if expected.matches(current.Version()) {
+			return s.Update(postID, postUpdateRequest)
+		}
 
-	return mResp, resp, err
-}
+		conflict := &ConflictError{PostID: postID, Expected: expected, Current: current}
 
-// Delete Post
-func (s *postService) Delete(postID string) (*Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/posts/%s", postID))
-	if err != nil {
-		return nil, err
-	}
+		if merge == nil || retries >= maxConflictRetries {
+			return nil, resp, conflict
+		}
 
-	req, err := s.client.NewRequest(http.MethodDelete, u.String(), nil)
+		postUpdateRequest, err = merge(current, postUpdateRequest)
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			conflict.MergeErr = err
+			return nil, resp, conflict
+		}
 
-	resp, err := s.client.Do(req, nil)
-	if err != nil {
-		return resp, err
+		expected = current.Version()
 	}
+}
 
-	return resp, err
+// Delete Post
+func (s *postService) Delete(postID int) (*Response, error) {
+	return s.client.call(http.MethodDelete, fmt.Sprintf("/posts/%d", postID), nil, nil)
 }
 
 // Archive Post
 func (s *postService) Archive(postID int) (*Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/posts/%d/archive", postID))
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodPut, u.String(), nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := s.client.Do(req, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, err
+	return s.client.call(http.MethodPut, fmt.Sprintf("/posts/%d/archive", postID), nil, nil)
 }
 
 // Unarchive Post
 func (s *postService) Unarchive(postID int) (*Response, error) {
-	u, err := url.Parse(fmt.Sprintf("/posts/%d/unarchive", postID))
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodPut, u.String(), nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := s.client.Do(req, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, err
+	return s.client.call(http.MethodPut, fmt.Sprintf("/posts/%d/unarchive", postID), nil, nil)
 }
@@ -1,6 +1,8 @@
 package docbase
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hayashiki/docbase-go/testutil"
 	"net/http"
@@ -291,3 +293,314 @@ func TestPostService_Unarchive(t *testing.T) {
 		t.Errorf("Post Unarchive request code = %v, expected %v", resp.StatusCode, http.StatusOK)
 	}
 }
+
+func TestPostService_Delete(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/posts/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := client.Posts.Delete(1)
+
+	if err != nil {
+		t.Errorf("Delete returned an error: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Post Delete request code = %v, expected %v", resp.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestPostUpdateRequest_MarshalJSON(t *testing.T) {
+	publishedAt := time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+
+	testCases := []struct {
+		desc string
+		req  *PostUpdateRequest
+		want string
+	}{
+		{"Empty", &PostUpdateRequest{}, `{}`},
+		{"Title", &PostUpdateRequest{Title: String("new title")}, `{"title":"new title"}`},
+		{"False", &PostUpdateRequest{Draft: Bool(false), Notice: Bool(false)}, `{"draft":false,"notice":false}`},
+		{"ClearTags", &PostUpdateRequest{Tags: []string{}}, `{"tags":[]}`},
+		{"Groups", &PostUpdateRequest{Scope: ScopeGroup.Ptr(), Groups: []string{"DocBase"}}, `{"scope":"group","groups":["DocBase"]}`},
+		{"PublishedAt", &PostUpdateRequest{PublishedAt: Time(publishedAt)}, `{"published_at":"2020-03-27T09:25:09Z"}`},
+	}
+	for _, tc := range testCases {
+		b, err := json.Marshal(tc.req)
+
+		if err != nil {
+			t.Errorf("%s: Marshal returned an error: %v", tc.desc, err)
+		}
+
+		if got := string(b); got != tc.want {
+			t.Errorf("%s: Marshal is %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestPostService_Update_Partial(t *testing.T) {
+	setup()
+	defer teardown()
+
+	// the server keeps the post and applies only the fields sent, like DocBase
+	stored := map[string]interface{}{}
+	json.Unmarshal([]byte(testutil.LoadFixture(t, "post-detail-response.json")), &stored)
+
+	mux.HandleFunc("/posts/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PATCH")
+
+		patch := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&patch)
+		for k, v := range patch {
+			if k == "tags" {
+				var tags []interface{}
+				for _, name := range v.([]interface{}) {
+					tags = append(tags, map[string]interface{}{"name": name})
+				}
+				v = tags
+			}
+			stored[k] = v
+		}
+
+		json.NewEncoder(w).Encode(stored)
+	})
+
+	before, _, err := client.Posts.Update(1, &PostUpdateRequest{})
+
+	if err != nil {
+		t.Fatalf("Update returned an error: %v", err)
+	}
+
+	after, _, err := client.Posts.Update(1, &PostUpdateRequest{Title: String("新しいタイトル")})
+
+	if err != nil {
+		t.Fatalf("Update returned an error: %v", err)
+	}
+
+	if after.Title != "新しいタイトル" {
+		t.Errorf("Title is %v, want updated", after.Title)
+	}
+
+	after.Title = before.Title
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("Untouched fields changed: %+v, want %+v", after, before)
+	}
+
+	after, _, err = client.Posts.Update(1, &PostUpdateRequest{Tags: []string{}})
+
+	if err != nil {
+		t.Fatalf("Update returned an error: %v", err)
+	}
+
+	if len(after.Tags) != 0 || after.Body != before.Body {
+		t.Errorf("Tags should be cleared and body kept: %+v", after)
+	}
+}
+
+func TestPostCreateRequest_MarshalJSON(t *testing.T) {
+	testCases := []struct {
+		desc string
+		req  *PostCreateRequest
+		want string
+	}{
+		{"Unset", &PostCreateRequest{Title: "t", Body: "b"}, `{"title":"t","body":"b"}`},
+		{"NoticeTrue", &PostCreateRequest{Title: "t", Body: "b", Notice: Bool(true)}, `{"title":"t","body":"b","notice":true}`},
+		{"NoticeFalse", &PostCreateRequest{Title: "t", Body: "b", Notice: Bool(false), Draft: Bool(false)}, `{"title":"t","body":"b","draft":false,"notice":false}`},
+		{"Author", &PostCreateRequest{Title: "t", Body: "b", AuthorID: "3"}, `{"title":"t","body":"b","author_id":"3"}`},
+	}
+	for _, tc := range testCases {
+		b, err := json.Marshal(tc.req)
+
+		if err != nil {
+			t.Errorf("%s: Marshal returned an error: %v", tc.desc, err)
+		}
+
+		if got := string(b); got != tc.want {
+			t.Errorf("%s: Marshal is %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestPostCreateRequest_Validate(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		scope   Scope
+		groups  []string
+		wantErr bool
+	}{
+		{"Default", "", nil, false},
+		{"Everyone", "everyone", nil, false},
+		{"Private", "private", nil, false},
+		{"Group", "group", []string{"DocBase"}, false},
+		{"GroupWithoutGroups", "group", nil, true},
+		{"EveryoneWithGroups", "everyone", []string{"DocBase"}, true},
+		{"DefaultWithGroups", "", []string{"DocBase"}, true},
+		{"Unknown", "public", nil, true},
+	}
+	for _, tc := range testCases {
+		req := &PostCreateRequest{Title: "t", Body: "b", Scope: tc.scope, Groups: tc.groups}
+
+		if err := req.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: Validate returned %v, wantErr %v", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPostService_Create_Invalid(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/posts", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Invalid request should not be sent")
+	})
+
+	_, _, err := client.Posts.Create(&PostCreateRequest{Title: "t", Body: "b", Scope: "group"})
+
+	if err == nil {
+		t.Errorf("Create should return a validation error")
+	}
+}
+
+// newVersionedPostServer serves post 1 and bumps updated_at on every PATCH
+func newVersionedPostServer(t *testing.T, patches *int) {
+	post := map[string]interface{}{
+		"id":         1,
+		"title":      "runbook",
+		"body":       "step 1",
+		"updated_at": "2020-03-27T09:25:09+09:00",
+	}
+	version := 0
+
+	mux.HandleFunc("/posts/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			*patches++
+			version++
+			json.NewDecoder(r.Body).Decode(&post)
+			post["updated_at"] = fmt.Sprintf("2020-03-27T09:25:%02d+09:00", 9+version)
+		}
+		json.NewEncoder(w).Encode(post)
+	})
+}
+
+func TestPostService_UpdateIfUnchanged(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var patches int
+	newVersionedPostServer(t, &patches)
+
+	fetched, _, _ := client.Posts.Get(1)
+
+	updated, _, err := client.Posts.UpdateIfUnchanged(1, fetched.Version(), &PostUpdateRequest{Body: String("step 1\nstep 2")}, nil)
+
+	if err != nil {
+		t.Fatalf("UpdateIfUnchanged returned an error: %v", err)
+	}
+
+	if updated.Body != "step 1\nstep 2" || patches != 1 {
+		t.Errorf("Post should be updated once: %+v, %d patches", updated, patches)
+	}
+}
+
+func TestPostService_UpdateIfUnchanged_Conflict(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var patches int
+	newVersionedPostServer(t, &patches)
+
+	fetched, _, _ := client.Posts.Get(1)
+
+	// another editor updates the post
+	client.Posts.Update(1, &PostUpdateRequest{Body: String("step 1\nstep 1.5")})
+
+	_, _, err := client.Posts.UpdateIfUnchanged(1, fetched.Version(), &PostUpdateRequest{Body: String("step 1\nstep 2")}, nil)
+
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Error should be ErrConflict but is %v", err)
+	}
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Current.Body != "step 1\nstep 1.5" {
+		t.Errorf("ConflictError should hold the current post: %+v", conflict)
+	}
+
+	if patches != 1 {
+		t.Errorf("Conflicting update should not be sent: %d patches", patches)
+	}
+}
+
+func TestPostService_UpdateIfUnchanged_Merge(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var patches int
+	newVersionedPostServer(t, &patches)
+
+	fetched, _, _ := client.Posts.Get(1)
+
+	client.Posts.Update(1, &PostUpdateRequest{Body: String("step 1\nstep 1.5")})
+
+	merge := func(current *Post, req *PostUpdateRequest) (*PostUpdateRequest, error) {
+		return &PostUpdateRequest{Body: String(current.Body + "\nstep 2")}, nil
+	}
+
+	updated, _, err := client.Posts.UpdateIfUnchanged(1, fetched.Version(), &PostUpdateRequest{Body: String("step 1\nstep 2")}, merge)
+
+	if err != nil {
+		t.Fatalf("UpdateIfUnchanged returned an error: %v", err)
+	}
+
+	if want := "step 1\nstep 1.5\nstep 2"; updated.Body != want {
+		t.Errorf("Body is %q, want %q", updated.Body, want)
+	}
+
+	errCannotMerge := errors.New("cannot merge")
+	failing := func(current *Post, req *PostUpdateRequest) (*PostUpdateRequest, error) {
+		return nil, errCannotMerge
+	}
+
+	_, _, err = client.Posts.UpdateIfUnchanged(1, fetched.Version(), &PostUpdateRequest{}, failing)
+
+	var conflict *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.Is(err, errCannotMerge) || !errors.As(err, &conflict) || conflict.Current == nil {
+		t.Errorf("Failed merge should return a ConflictError wrapping the merge error: %v", err)
+	}
+}
+
+func TestPostService_UpdateIfUnchanged_Cached(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var patches int
+	newVersionedPostServer(t, &patches)
+
+	cached, err := NewClientWithOptions("dummyTeam", "dummyToken", WithBaseURL(server.URL), WithCache(NewMemoryCache(10), time.Minute), WithRequestCoalescing())
+
+	if err != nil {
+		t.Fatalf("NewClientWithOptions returned an error: %v", err)
+	}
+
+	fetched, _, _ := cached.Posts.Get(1)
+
+	// another client updates the post, leaving the cache of this one
+	client.Posts.Update(1, &PostUpdateRequest{Body: String("step 1\nstep 1.5")})
+
+	_, _, err = cached.Posts.UpdateIfUnchanged(1, fetched.Version(), &PostUpdateRequest{Body: String("step 1\nstep 2")}, nil)
+
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Error should be ErrConflict but is %v", err)
+	}
+
+	if patches != 1 {
+		t.Errorf("Conflicting update should not be sent: %d patches", patches)
+	}
+
+	if post, _, _ := cached.Posts.Get(1); post.Body != "step 1\nstep 1.5" {
+		t.Errorf("The fresh post should be cached: %+v", post)
+	}
+}
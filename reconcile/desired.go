@@ -0,0 +1,172 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Desired is the wanted membership of the groups it lists.
+// Groups which are not listed are left alone.
+type Desired struct {
+	// Aliases maps emails to usernames, as the API does not return emails
+	Aliases map[string]string `yaml:"aliases"`
+	Groups  []Group           `yaml:"groups"`
+}
+
+// Group is a wanted group. Members are usernames, "@username" or aliased emails.
+type Group struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Members     []string `yaml:"members"`
+}
+
+// LoadFile reads a .yaml, .yml or .csv file
+func LoadFile(path string) (*Desired, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return LoadYAML(f)
+	case ".csv":
+		return LoadCSV(f)
+	}
+
+	return nil, fmt.Errorf("unsupported file type: %s", path)
+}
+
+// LoadYAML reads groups like
+//
+//	aliases:
+//	  taro@example.com: taro
+//	groups:
+//	  - name: infra
+//	    description: Infrastructure team
+//	    members: [taro, "@hanako"]
+func LoadYAML(r io.Reader) (*Desired, error) {
+	d := &Desired{}
+
+	if err := yaml.NewDecoder(r).Decode(d); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return d, d.Validate()
+}
+
+// LoadCSV reads rows of the columns "group" and "member", and optionally "description"
+// and "username". A username aliases the member of its row, usually an email exported
+// by HR tools, like Aliases of YAML files. A row with an empty member declares a group
+// without members.
+func LoadCSV(r io.Reader) (*Desired, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+
+	cols := make(map[string]int)
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	groupCol, ok := cols["group"]
+	if !ok {
+		return nil, errors.New("csv has no group column")
+	}
+
+	memberCol, ok := cols["member"]
+	if !ok {
+		return nil, errors.New("csv has no member column")
+	}
+
+	descCol, hasDesc := cols["description"]
+	usernameCol, hasUsername := cols["username"]
+
+	d := &Desired{}
+	index := make(map[string]int)
+
+	for {
+		row, err := cr.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSpace(row[groupCol])
+		i, ok := index[name]
+		if !ok {
+			i = len(d.Groups)
+			index[name] = i
+			d.Groups = append(d.Groups, Group{Name: name})
+		}
+
+		if hasDesc && d.Groups[i].Description == "" {
+			d.Groups[i].Description = strings.TrimSpace(row[descCol])
+		}
+
+		m := strings.TrimSpace(row[memberCol])
+		if m == "" {
+			continue
+		}
+
+		d.Groups[i].Members = append(d.Groups[i].Members, m)
+
+		if !hasUsername {
+			continue
+		}
+
+		username := strings.TrimPrefix(strings.TrimSpace(row[usernameCol]), "@")
+		if username == "" || username == m {
+			continue
+		}
+
+		if prev, ok := d.Aliases[m]; ok && prev != username {
+			return nil, fmt.Errorf("%s is aliased to both %s and %s", m, prev, username)
+		}
+
+		if d.Aliases == nil {
+			d.Aliases = make(map[string]string)
+		}
+
+		d.Aliases[m] = username
+	}
+
+	return d, d.Validate()
+}
+
+// Validate checks that group names are given and unique
+func (d *Desired) Validate() error {
+	seen := make(map[string]bool)
+
+	for _, g := range d.Groups {
+		if g.Name == "" {
+			return errors.New("group name is required")
+		}
+
+		if seen[g.Name] {
+			return fmt.Errorf("group %s is listed twice", g.Name)
+		}
+
+		seen[g.Name] = true
+	}
+
+	return nil
+}
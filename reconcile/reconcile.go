@@ -0,0 +1,326 @@
+// Package reconcile makes DocBase group memberships match a declared file.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+var (
+	// ErrUnresolved is returned by Apply when some members are not found.
+	// Applying anyway would remove them from groups they may belong to.
+	ErrUnresolved = errors.New("members are not resolved")
+	// ErrTooManyRemovals is returned by Apply when the plan removes more members than allowed
+	ErrTooManyRemovals = errors.New("too many removals")
+)
+
+// Member is a user added to or removed from a group
+type Member struct {
+	ID       int
+	Username string
+	Name     string
+}
+
+func (m Member) String() string {
+	if m.Username != "" {
+		return "@" + m.Username
+	}
+
+	if m.Name != "" {
+		return fmt.Sprintf("%s (#%d)", m.Name, m.ID)
+	}
+
+	return fmt.Sprintf("#%d", m.ID)
+}
+
+// Change is the change of a group. GroupID is 0 when the group is to be created.
+type Change struct {
+	Group       string
+	GroupID     int
+	Description string
+	Add         []Member
+	Remove      []Member
+}
+
+// Create reports whether the group is to be created
+func (c *Change) Create() bool {
+	return c.GroupID == 0
+}
+
+// Plan is the changes needed to reach the desired memberships
+type Plan struct {
+	Changes []Change
+	// Unresolved lists members which matched no user, as "group: member"
+	Unresolved []string
+}
+
+// Removals returns the number of members to remove
+func (p *Plan) Removals() int {
+	n := 0
+	for _, c := range p.Changes {
+		n += len(c.Remove)
+	}
+
+	return n
+}
+
+// Empty reports whether nothing is to be changed
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0 && len(p.Unresolved) == 0
+}
+
+// Print writes the plan in a readable form
+func (p *Plan) Print(w io.Writer) error {
+	var b strings.Builder
+
+	if p.Empty() {
+		b.WriteString("No changes.\n")
+	}
+
+	for _, c := range p.Changes {
+		if c.Create() {
+			fmt.Fprintf(&b, "+ create group %s\n", c.Group)
+		} else {
+			fmt.Fprintf(&b, "~ group %s\n", c.Group)
+		}
+
+		for _, m := range c.Add {
+			fmt.Fprintf(&b, "    + %v\n", m)
+		}
+
+		for _, m := range c.Remove {
+			fmt.Fprintf(&b, "    - %v\n", m)
+		}
+	}
+
+	for _, u := range p.Unresolved {
+		fmt.Fprintf(&b, "! unresolved %s\n", u)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Reconciler plans and applies desired memberships
+type Reconciler struct {
+	client *docbase.Client
+
+	// DryRun makes Run print the plan without applying it
+	DryRun bool
+	// MaxRemovals caps the members a plan may remove. Zero forbids removals and negative is unlimited.
+	MaxRemovals int
+	// Out receives the plan in Run, and is io.Discard when nil
+	Out io.Writer
+}
+
+// New returns a Reconciler which allows no removals
+func New(c *docbase.Client) *Reconciler {
+	return &Reconciler{client: c}
+}
+
+// Plan compares the desired state with the groups and members of the team
+func (r *Reconciler) Plan(d *Desired) (*Plan, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string, len(d.Aliases))
+	for email, username := range d.Aliases {
+		aliases[strings.ToLower(email)] = username
+	}
+
+	plan := &Plan{}
+
+	for _, g := range d.Groups {
+		var ids []int
+		members := make(map[int]Member)
+
+		for _, m := range g.Members {
+			u, err := r.resolve(m, aliases)
+
+			if errors.Is(err, docbase.ErrUserNotFound) || errors.Is(err, docbase.ErrAmbiguousUser) {
+				plan.Unresolved = append(plan.Unresolved, fmt.Sprintf("%s: %s (%v)", g.Name, m, err))
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, u.ID)
+			members[u.ID] = Member{ID: u.ID, Username: u.Username, Name: u.Name}
+		}
+
+		change, err := r.change(g, ids, members)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if change != nil {
+			plan.Changes = append(plan.Changes, *change)
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply makes the changes of the plan. Groups are created before members are added.
+func (r *Reconciler) Apply(p *Plan) error {
+	if len(p.Unresolved) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnresolved, strings.Join(p.Unresolved, ", "))
+	}
+
+	if r.MaxRemovals >= 0 && p.Removals() > r.MaxRemovals {
+		return fmt.Errorf("%w: %d members, max %d", ErrTooManyRemovals, p.Removals(), r.MaxRemovals)
+	}
+
+	created := false
+	defer func() {
+		// later plans must see the new groups
+		if created {
+			r.client.GroupDirectory.Refresh()
+		}
+	}()
+
+	for _, c := range p.Changes {
+		id := c.GroupID
+
+		if c.Create() {
+			g, _, err := r.client.Groups.Create(&docbase.GroupCreateRequest{Name: c.Group, Description: c.Description})
+
+			if err != nil {
+				return fmt.Errorf("creating group %s: %w", c.Group, err)
+			}
+
+			id = g.ID
+			created = true
+		}
+
+		if len(c.Add) > 0 {
+			if _, err := r.client.GroupUsers.Create(id, &docbase.GroupUserCreateRequest{UserIDs: memberIDs(c.Add)}); err != nil {
+				return fmt.Errorf("adding members to %s: %w", c.Group, err)
+			}
+		}
+
+		if len(c.Remove) > 0 {
+			if _, err := r.client.GroupUsers.Delete(id, &docbase.GroupUserCreateRequest{UserIDs: memberIDs(c.Remove)}); err != nil {
+				return fmt.Errorf("removing members from %s: %w", c.Group, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run plans, prints the plan to Out and applies it unless DryRun
+func (r *Reconciler) Run(d *Desired) (*Plan, error) {
+	plan, err := r.Plan(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := r.Out
+	if out == nil {
+		out = io.Discard
+	}
+
+	if err := plan.Print(out); err != nil {
+		return plan, err
+	}
+
+	if r.DryRun {
+		return plan, nil
+	}
+
+	return plan, r.Apply(plan)
+}
+
+// resolve looks up a username, "@username" or an aliased email
+func (r *Reconciler) resolve(member string, aliases map[string]string) (*docbase.User, error) {
+	member = strings.TrimSpace(member)
+
+	if strings.Contains(strings.TrimPrefix(member, "@"), "@") {
+		username, ok := aliases[strings.ToLower(member)]
+
+		if !ok {
+			return nil, fmt.Errorf("%w: no alias for %s", docbase.ErrUserNotFound, member)
+		}
+
+		return r.client.UserDirectory.ByUsername(strings.TrimPrefix(username, "@"))
+	}
+
+	return r.client.UserDirectory.Lookup(member)
+}
+
+// change returns the change of the group, or nil if there is none
+func (r *Reconciler) change(g Group, ids []int, members map[int]Member) (*Change, error) {
+	existing, err := r.client.GroupDirectory.Resolve(docbase.GroupByName(g.Name))
+
+	if errors.Is(err, docbase.ErrGroupNotFound) {
+		c := &Change{Group: g.Name, Description: g.Description}
+		for _, id := range dedup(ids) {
+			c.Add = append(c.Add, members[id])
+		}
+
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := r.client.GroupUsers.Diff(existing.ID, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if diff.Empty() {
+		return nil, nil
+	}
+
+	c := &Change{Group: g.Name, GroupID: existing.ID}
+	for _, id := range diff.Add {
+		c.Add = append(c.Add, members[id])
+	}
+
+	for _, id := range diff.Remove {
+		m := Member{ID: id}
+		if u, err := r.client.UserDirectory.ByID(id); err == nil {
+			m = Member{ID: u.ID, Username: u.Username, Name: u.Name}
+		}
+
+		c.Remove = append(c.Remove, m)
+	}
+
+	return c, nil
+}
+
+func memberIDs(members []Member) []int {
+	ids := make([]int, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+
+	return ids
+}
+
+func dedup(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var out []int
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,194 @@
+package reconcile
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+var team = fakeserver.Seed{
+	Users:  []docbase.User{fakeserver.Taro, fakeserver.Hanako, {ID: 3, Username: "jiro", Name: "Jiro"}},
+	Groups: []fakeserver.Group{{Name: "infra", Members: []int{1, 3}}},
+}
+
+func TestLoadCSV(t *testing.T) {
+	data := "group,member,description\n" +
+		"infra,taro,Infrastructure\n" +
+		"infra,hanako@example.com,\n" +
+		"design,,\n"
+
+	got, err := LoadCSV(strings.NewReader(data))
+
+	if err != nil {
+		t.Fatalf("LoadCSV returned an error: %v", err)
+	}
+
+	want := []Group{
+		{Name: "infra", Description: "Infrastructure", Members: []string{"taro", "hanako@example.com"}},
+		{Name: "design"},
+	}
+
+	if !reflect.DeepEqual(got.Groups, want) {
+		t.Errorf("LoadCSV returned %+v, want %+v", got.Groups, want)
+	}
+
+	if _, err := LoadCSV(strings.NewReader("team,member\n")); err == nil {
+		t.Errorf("LoadCSV should require the group column")
+	}
+}
+
+func TestLoadCSV_Username(t *testing.T) {
+	data := "group,member,username\n" +
+		"infra,taro@example.com,taro\n" +
+		"infra,hanako@example.com,@hanako\n" +
+		"design,taro@example.com,\n"
+
+	got, err := LoadCSV(strings.NewReader(data))
+
+	if err != nil {
+		t.Fatalf("LoadCSV returned an error: %v", err)
+	}
+
+	want := map[string]string{"taro@example.com": "taro", "hanako@example.com": "hanako"}
+	if !reflect.DeepEqual(got.Aliases, want) {
+		t.Errorf("Aliases are %v, want %v", got.Aliases, want)
+	}
+
+	s := fakeserver.NewTeam(t, team)
+	plan, err := New(s.Client).Plan(got)
+
+	if err != nil || len(plan.Unresolved) != 0 {
+		t.Fatalf("Plan returned %+v, %v", plan, err)
+	}
+
+	if _, err := LoadCSV(strings.NewReader(data + "design,taro@example.com,jiro\n")); err == nil {
+		t.Errorf("LoadCSV should reject an email aliased to two users")
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	data := `
+aliases:
+  Hanako@Example.com: hanako
+groups:
+  - name: infra
+    members: [taro, "@jiro"]
+  - name: infra
+`
+	if _, err := LoadYAML(strings.NewReader(data)); err == nil {
+		t.Errorf("LoadYAML should reject duplicated groups")
+	}
+
+	got, err := LoadYAML(strings.NewReader(strings.TrimSuffix(data, "  - name: infra\n")))
+
+	if err != nil {
+		t.Fatalf("LoadYAML returned an error: %v", err)
+	}
+
+	if got.Aliases["Hanako@Example.com"] != "hanako" || len(got.Groups) != 1 || len(got.Groups[0].Members) != 2 {
+		t.Errorf("LoadYAML returned %+v", got)
+	}
+}
+
+func TestReconciler_Run(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	r := New(s.Client)
+
+	var out strings.Builder
+	r.Out = &out
+	r.MaxRemovals = 1
+
+	desired := &Desired{
+		Aliases: map[string]string{"Hanako@Example.com": "hanako"},
+		Groups: []Group{
+			{Name: "infra", Members: []string{"taro", "hanako@example.com"}},
+			{Name: "design", Description: "Designers", Members: []string{"@jiro"}},
+		},
+	}
+
+	plan, err := r.Run(desired)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	want := "~ group infra\n" +
+		"    + @hanako\n" +
+		"    - @jiro\n" +
+		"+ create group design\n" +
+		"    + @jiro\n"
+
+	if out.String() != want {
+		t.Errorf("Run printed\n%s\nwant\n%s", out.String(), want)
+	}
+
+	if plan.Removals() != 1 {
+		t.Errorf("Removals is %d, want 1", plan.Removals())
+	}
+
+	if got := s.Members("infra"); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("infra members are %v", got)
+	}
+
+	if got := s.Members("design"); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("design members are %v", got)
+	}
+
+	// applied twice, nothing changes
+	out.Reset()
+	if _, err := r.Run(desired); err != nil || out.String() != "No changes.\n" {
+		t.Errorf("Second Run printed %q, %v", out.String(), err)
+	}
+}
+
+func TestReconciler_DryRun(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	r := New(s.Client)
+	r.DryRun = true
+
+	plan, err := r.Run(&Desired{Groups: []Group{{Name: "infra"}, {Name: "design"}}})
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if len(plan.Changes) != 2 || plan.Removals() != 2 {
+		t.Errorf("Plan is %+v", plan)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("DryRun should not change anything: %v", reqs)
+	}
+}
+
+func TestReconciler_Safety(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	r := New(s.Client)
+
+	// removals are forbidden by default
+	_, err := r.Run(&Desired{Groups: []Group{{Name: "infra", Members: []string{"taro"}}}})
+
+	if !errors.Is(err, ErrTooManyRemovals) {
+		t.Errorf("Error should be ErrTooManyRemovals but is %v", err)
+	}
+
+	// an unknown member must not be removed by mistake
+	r.MaxRemovals = -1
+	plan, err := r.Run(&Desired{Groups: []Group{{Name: "infra", Members: []string{"taro", "jiro@example.com"}}}})
+
+	if !errors.Is(err, ErrUnresolved) {
+		t.Errorf("Error should be ErrUnresolved but is %v", err)
+	}
+
+	if len(plan.Unresolved) != 1 || !strings.HasPrefix(plan.Unresolved[0], "infra: jiro@example.com") {
+		t.Errorf("Unresolved is %v", plan.Unresolved)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("Nothing should be changed: %v", reqs)
+	}
+}
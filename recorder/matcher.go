@@ -0,0 +1,56 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// Matcher reports whether req with body matches a recorded request
+type Matcher func(req *http.Request, body []byte, recorded Request) bool
+
+// DefaultMatchers match on method, path, query and body
+var DefaultMatchers = []Matcher{MatchMethod, MatchPath, MatchQuery, MatchBody}
+
+// MatchMethod matches the HTTP method
+func MatchMethod(req *http.Request, body []byte, recorded Request) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchPath matches the URL path, ignoring scheme and host
+func MatchPath(req *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+
+	if err != nil {
+		return false
+	}
+
+	return req.URL.Path == u.Path
+}
+
+// MatchQuery matches the query parameters regardless of their order
+func MatchQuery(req *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(req.URL.Query(), u.Query())
+}
+
+// MatchBody matches the body, comparing JSON semantically
+func MatchBody(req *http.Request, body []byte, recorded Request) bool {
+	if bytes.Equal(body, []byte(recorded.Body)) {
+		return true
+	}
+
+	var got, want interface{}
+	if json.Unmarshal(body, &got) != nil || json.Unmarshal([]byte(recorded.Body), &want) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(got, want)
+}
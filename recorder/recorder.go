@@ -0,0 +1,271 @@
+// Package recorder records DocBase API interactions into cassette files
+// and replays them offline for deterministic tests.
+//
+//	rec, err := recorder.New("testdata/cassettes/posts.json", recorder.ModeAuto)
+//	defer rec.Stop()
+//	client, err := docbase.NewClientWithOptions("team", "token", docbase.WithHTTPClient(rec.Client()))
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Mode selects whether the recorder hits the network
+type Mode int
+
+const (
+	// ModeReplay serves every request from the cassette and never hits the network.
+	ModeReplay Mode = iota
+	// ModeRecord sends every request and overwrites the cassette on Stop.
+	ModeRecord
+	// ModeAuto replays when the cassette exists and records otherwise.
+	ModeAuto
+)
+
+// ErrNoInteraction is returned in replay when no recorded interaction matches the request.
+var ErrNoInteraction = errors.New("recorder: no interaction matches the request")
+
+// Cassette is the file format of recorded interactions
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded pair of request and response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request represents a recorded request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// Response represents a recorded response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// Recorder is a http.RoundTripper recording or replaying interactions
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+	matchers  []Matcher
+	scrubbers []Scrubber
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// Option configures a Recorder
+type Option func(*Recorder)
+
+// WithTransport sets the transport used while recording. Default is http.DefaultTransport.
+func WithTransport(t http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = t
+	}
+}
+
+// WithMatchers replaces DefaultMatchers
+func WithMatchers(matchers ...Matcher) Option {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithScrubbers adds scrubbers applied to interactions before they are saved
+func WithScrubbers(scrubbers ...Scrubber) Option {
+	return func(r *Recorder) {
+		r.scrubbers = append(r.scrubbers, scrubbers...)
+	}
+}
+
+// New returns a Recorder backed by the cassette file at path
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		matchers:  DefaultMatchers,
+		scrubbers: append([]Scrubber{}, DefaultScrubbers...),
+		cassette:  &Cassette{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode == ModeAuto {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+
+	if r.mode == ModeReplay {
+		b, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("recorder: failed to read cassette: %w", err)
+		}
+
+		if err := json.Unmarshal(b, r.cassette); err != nil {
+			return nil, fmt.Errorf("recorder: failed to parse cassette %s: %w", path, err)
+		}
+
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+
+	return r, nil
+}
+
+// Mode returns the effective mode, never ModeAuto
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns a http.Client using the recorder as transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+
+	return r.record(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || !r.match(req, body, in.Request) {
+			continue
+		}
+
+		r.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(in.Response.Body))),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+}
+
+func (r *Recorder) match(req *http.Request, body []byte, recorded Request) bool {
+	for _, m := range r.matchers {
+		if !m(req, body, recorded) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	in := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   string(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       string(respBody),
+		},
+	}
+
+	for _, scrub := range r.scrubbers {
+		scrub(in)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Stop saves the cassette when recording
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(r.cassette); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.path, buf.Bytes(), 0644)
+}
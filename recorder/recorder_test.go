@@ -0,0 +1,182 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+func loadFixture(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("..", "testdata", name))
+
+	if err != nil {
+		t.Fatalf("Error while trying to read %s: %v", name, err)
+	}
+
+	return string(b)
+}
+
+func newClient(t *testing.T, rec *Recorder, baseURL string) *docbase.Client {
+	cli, err := docbase.NewClientWithOptions("fakeTeam", "secretToken",
+		docbase.WithHTTPClient(rec.Client()),
+		docbase.WithBaseURL(baseURL),
+	)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	return cli
+}
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "post.json")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, loadFixture(t, "post-detail-response.json"))
+	}))
+
+	rec, err := New(path, ModeAuto)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if rec.Mode() != ModeRecord {
+		t.Errorf("Mode is %v, want ModeRecord for a missing cassette", rec.Mode())
+	}
+
+	recorded, _, err := newClient(t, rec, srv.URL).Posts.Get(1)
+
+	if err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+
+	if recorded.User.Name != "danny" {
+		t.Errorf("Recording should return the real response: %+v", recorded.User)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop returned an error: %v", err)
+	}
+
+	srv.Close()
+
+	b, _ := ioutil.ReadFile(path)
+	for _, secret := range []string{"secretToken", "danny", "aaa.gif"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("Cassette should not contain %q", secret)
+		}
+	}
+
+	rec, err = New(path, ModeAuto)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if rec.Mode() != ModeReplay {
+		t.Errorf("Mode is %v, want ModeReplay for an existing cassette", rec.Mode())
+	}
+
+	cli := newClient(t, rec, "http://replay.invalid")
+
+	replayed, _, err := cli.Posts.Get(1)
+
+	if err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+
+	if replayed.Title != recorded.Title || replayed.User.Name != "user1" {
+		t.Errorf("Replayed post %+v", replayed)
+	}
+
+	if _, _, err := cli.Posts.Get(1); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Interactions should be replayed once: %v", err)
+	}
+}
+
+func TestRecorder_ReplayCassette(t *testing.T) {
+	rec, err := New(filepath.Join("testdata", "post-list.json"), ModeReplay)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	cli := newClient(t, rec, "https://api.docbase.io/teams/fakeTeam")
+
+	posts, resp, err := cli.Posts.List(&docbase.PostListOptions{Q: "tag:日報", Page: 1, PerPage: 2})
+
+	if err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+
+	if len(posts) != 2 || resp.Total != 39 {
+		t.Errorf("List returned %d posts of %d", len(posts), resp.Total)
+	}
+
+	if _, _, err := cli.Posts.List(&docbase.PostListOptions{Q: "tag:other", Page: 1, PerPage: 2}); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Query should not match: %v", err)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	recorded := Request{
+		Method: http.MethodPatch,
+		URL:    "https://api.docbase.io/teams/kray/posts/1?a=1&b=2",
+		Body:   `{"title": "t", "tags": ["x"]}`,
+	}
+
+	testCases := []struct {
+		desc  string
+		req   *http.Request
+		body  string
+		match bool
+	}{
+		{"Same", httptest.NewRequest(http.MethodPatch, "http://localhost/teams/kray/posts/1?b=2&a=1", nil), `{"tags":["x"],"title":"t"}`, true},
+		{"Method", httptest.NewRequest(http.MethodPut, "http://localhost/teams/kray/posts/1?a=1&b=2", nil), recorded.Body, false},
+		{"Path", httptest.NewRequest(http.MethodPatch, "http://localhost/teams/kray/posts/2?a=1&b=2", nil), recorded.Body, false},
+		{"Query", httptest.NewRequest(http.MethodPatch, "http://localhost/teams/kray/posts/1?a=1", nil), recorded.Body, false},
+		{"Body", httptest.NewRequest(http.MethodPatch, "http://localhost/teams/kray/posts/1?a=1&b=2", nil), `{"title": "u"}`, false},
+	}
+	for _, tc := range testCases {
+		got := true
+		for _, m := range DefaultMatchers {
+			got = got && m(tc.req, []byte(tc.body), recorded)
+		}
+
+		if got != tc.match {
+			t.Errorf("%s: match is %v, want %v", tc.desc, got, tc.match)
+		}
+	}
+}
+
+func TestScrubUsers(t *testing.T) {
+	in := &Interaction{
+		Request: Request{Header: http.Header{"X-Docbasetoken": []string{"secretToken"}}},
+		Response: Response{
+			Header: http.Header{},
+			Body:   `[{"id": 12345678901234, "name": "山田", "username": "yamada", "email": "yamada@example.jp", "groups": [{"id": 1, "name": "DocBase"}]}]`,
+		},
+	}
+
+	for _, scrub := range DefaultScrubbers {
+		scrub(in)
+	}
+
+	want := `[{"email":"REDACTED","groups":[{"id":1,"name":"DocBase"}],"id":12345678901234,"name":"user12345678901234","username":"user12345678901234"}]`
+	if in.Response.Body != want {
+		t.Errorf("Scrubbed body is %s, want %s", in.Response.Body, want)
+	}
+
+	if got := in.Request.Header.Get("X-DocBaseToken"); got != "REDACTED" {
+		t.Errorf("Token header is %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Scrubber rewrites an interaction before it is saved
+type Scrubber func(in *Interaction)
+
+// DefaultScrubbers remove the access token and personal data of users
+var DefaultScrubbers = []Scrubber{ScrubToken, ScrubUsers}
+
+const redacted = "REDACTED"
+
+// ScrubToken redacts the access token and cookies
+func ScrubToken(in *Interaction) {
+	for _, k := range []string{"X-Docbasetoken", "Authorization", "Cookie"} {
+		if in.Request.Header.Get(k) != "" {
+			in.Request.Header.Set(k, redacted)
+		}
+	}
+
+	in.Response.Header.Del("Set-Cookie")
+}
+
+// ScrubUsers replaces names, usernames, emails and profile images of users in JSON bodies
+// with placeholders derived from the user ID, so recorded data stays consistent.
+func ScrubUsers(in *Interaction) {
+	in.Request.Body = scrubJSON(in.Request.Body)
+	in.Response.Body = scrubJSON(in.Response.Body)
+}
+
+func scrubJSON(body string) string {
+	d := json.NewDecoder(bytes.NewReader([]byte(body)))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return body
+	}
+
+	if !scrubValue(v) {
+		return body
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// scrubValue scrubs v in place and reports whether anything changed
+func scrubValue(v interface{}) bool {
+	changed := false
+
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if scrubValue(e) {
+				changed = true
+			}
+		}
+	case map[string]interface{}:
+		if _, ok := v["email"]; ok {
+			v["email"] = redacted
+			changed = true
+		}
+
+		if isUser(v) {
+			placeholder := fmt.Sprintf("user%v", v["id"])
+			for _, k := range []string{"name", "username"} {
+				if _, ok := v[k]; ok {
+					v[k] = placeholder
+				}
+			}
+			if _, ok := v["profile_image_url"]; ok {
+				v["profile_image_url"] = "https://example.com/" + placeholder + ".png"
+			}
+			changed = true
+		}
+
+		for _, e := range v {
+			if scrubValue(e) {
+				changed = true
+			}
+		}
+	}
+
+	return changed
+}
+
+// isUser reports whether an object looks like User or SimpleUser
+func isUser(v map[string]interface{}) bool {
+	_, hasImage := v["profile_image_url"]
+	_, hasUsername := v["username"]
+	return hasImage || hasUsername
+}
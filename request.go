@@ -0,0 +1,88 @@
+package docbase
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// GetJSON sends a GET request to path with query and decodes the response into a new T.
+// It is meant for endpoints not covered by the services, with the same auth,
+// rate handling, errors and decoding.
+//
+//	posts, resp, err := docbase.GetJSON[docbase.PostListResponse](client, "/posts", url.Values{"q": {"tag:go"}})
+func GetJSON[T any](c *Client, path string, query url.Values) (*T, *Response, error) {
+	return doJSON[T](c, http.MethodGet, withQuery(path, query), nil)
+}
+
+// GetUncachedJSON is GetJSON bypassing the response cache and request coalescing,
+// for reads that must see the latest state, e.g. right before a write.
+// The response still refreshes the cache.
+func GetUncachedJSON[T any](c *Client, path string, query url.Values) (*T, *Response, error) {
+	req, err := c.NewRequest(http.MethodGet, withQuery(path, query), nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Cache-Control", "no-cache")
+
+	v := new(T)
+	resp, err := c.Do(req, v)
+
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return v, resp, nil
+}
+
+// PostJSON sends a POST request to path with body and decodes the response into a new T.
+func PostJSON[T any](c *Client, path string, body interface{}) (*T, *Response, error) {
+	return doJSON[T](c, http.MethodPost, path, body)
+}
+
+// PatchJSON sends a PATCH request to path with body and decodes the response into a new T.
+func PatchJSON[T any](c *Client, path string, body interface{}) (*T, *Response, error) {
+	return doJSON[T](c, http.MethodPatch, path, body)
+}
+
+// PutJSON sends a PUT request to path with body and decodes the response into a new T.
+func PutJSON[T any](c *Client, path string, body interface{}) (*T, *Response, error) {
+	return doJSON[T](c, http.MethodPut, path, body)
+}
+
+// DeleteJSON sends a DELETE request to path with body, ignoring the response body.
+func DeleteJSON(c *Client, path string, body interface{}) (*Response, error) {
+	return c.call(http.MethodDelete, path, body, nil)
+}
+
+// doJSON is the request pipeline shared by the services
+func doJSON[T any](c *Client, method, path string, body interface{}) (*T, *Response, error) {
+	v := new(T)
+	resp, err := c.call(method, path, body, v)
+
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return v, resp, nil
+}
+
+// call sends a request and decodes the response into v unless v is nil
+func (c *Client) call(method, path string, body, v interface{}) (*Response, error) {
+	req, err := c.NewRequest(method, path, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req, v)
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,131 @@
+package docbase
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type starResponse struct {
+	PostID  int  `json:"post_id"`
+	Starred bool `json:"starred"`
+}
+
+func TestGetJSON(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/posts/1/stars", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testHeader(t, r, "X-DocBaseToken", "dummyToken")
+
+		if got, want := r.URL.Query().Get("page"), "2"; got != want {
+			t.Errorf("Query page is %v, want %v", got, want)
+		}
+
+		fmt.Fprint(w, `{"post_id": 1, "starred": true}`)
+	})
+
+	got, resp, err := GetJSON[starResponse](client, "/posts/1/stars", url.Values{"page": {"2"}})
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GetJSON response code = %v, expected %v", resp.StatusCode, http.StatusOK)
+	}
+
+	if want := (&starResponse{PostID: 1, Starred: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetJSON returned %+v, want %+v", got, want)
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	setup()
+	defer teardown()
+
+	methods := map[string]func() (*starResponse, *Response, error){
+		"POST": func() (*starResponse, *Response, error) {
+			return PostJSON[starResponse](client, "/stars", starResponse{PostID: 1})
+		},
+		"PATCH": func() (*starResponse, *Response, error) {
+			return PatchJSON[starResponse](client, "/stars", starResponse{PostID: 1})
+		},
+		"PUT": func() (*starResponse, *Response, error) {
+			return PutJSON[starResponse](client, "/stars", starResponse{PostID: 1})
+		},
+	}
+
+	var method string
+	mux.HandleFunc("/stars", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, method)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if got, want := string(body), `{"post_id":1,"starred":false}`; got != want {
+			t.Errorf("Body is %v, want %v", got, want)
+		}
+
+		fmt.Fprint(w, `{"post_id": 1, "starred": true}`)
+	})
+
+	for m, fn := range methods {
+		method = m
+		got, _, err := fn()
+
+		if err != nil {
+			t.Errorf("%s returned an error: %v", m, err)
+		}
+
+		if got == nil || !got.Starred {
+			t.Errorf("%s returned %+v", m, got)
+		}
+	}
+}
+
+func TestDeleteJSON(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/stars/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := DeleteJSON(client, "/stars/1", nil)
+
+	if err != nil {
+		t.Fatalf("Shouldn't have returned an error: %+v", err)
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DeleteJSON response code = %v, expected %v", resp.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestGetJSON_Error(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/unknown", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": "not_found", "messages": ["Not Found"]}`)
+	})
+
+	got, resp, err := GetJSON[starResponse](client, "/unknown", nil)
+
+	if got != nil {
+		t.Errorf("GetJSON should return nil on error: %+v", got)
+	}
+
+	if _, ok := err.(*ErrorResponse); !ok {
+		t.Errorf("Error should be of type ErrorResponse but is %T: %+v", err, err)
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GetJSON should return the response on error: %+v", resp)
+	}
+}
@@ -0,0 +1,75 @@
+package docbase
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Scope represents who can read a post
+type Scope string
+
+const (
+	ScopeEveryone Scope = "everyone"
+	ScopeGroup    Scope = "group"
+	ScopePrivate  Scope = "private"
+)
+
+// Valid reports whether s is a scope known by DocBase
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeEveryone, ScopeGroup, ScopePrivate:
+		return true
+	}
+
+	return false
+}
+
+// Ptr returns a pointer to s, for PostUpdateRequest.Scope
+func (s Scope) Ptr() *Scope {
+	return &s
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+// Unknown scopes are rejected. The empty scope is marshaled as is.
+func (s Scope) MarshalJSON() ([]byte, error) {
+	if s != "" && !s.Valid() {
+		return nil, fmt.Errorf("unknown scope: %q", string(s))
+	}
+
+	return json.Marshal(string(s))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// Unknown scopes are rejected.
+func (s *Scope) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	if str != "" && !Scope(str).Valid() {
+		return fmt.Errorf("unknown scope: %q", str)
+	}
+
+	*s = Scope(str)
+	return nil
+}
+
+// validateScope checks that groups are given with ScopeGroup and only with it.
+// The empty scope means the default, ScopeEveryone.
+func validateScope(scope Scope, groups []string) error {
+	switch scope {
+	case "", ScopeEveryone, ScopePrivate:
+		if len(groups) > 0 {
+			return fmt.Errorf("groups must be empty unless scope is %q: %v", ScopeGroup, groups)
+		}
+	case ScopeGroup:
+		if len(groups) == 0 {
+			return fmt.Errorf("groups are required for scope %q", ScopeGroup)
+		}
+	default:
+		return fmt.Errorf("unknown scope: %q", string(scope))
+	}
+
+	return nil
+}
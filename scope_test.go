@@ -0,0 +1,105 @@
+package docbase
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScope_JSON(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		data    string
+		want    Scope
+		wantErr bool
+	}{
+		{"Everyone", `"everyone"`, ScopeEveryone, false},
+		{"Group", `"group"`, ScopeGroup, false},
+		{"Private", `"private"`, ScopePrivate, false},
+		{"Empty", `""`, "", false},
+		{"Unknown", `"public"`, "", true},
+		{"NotString", `1`, "", true},
+	}
+	for _, tc := range testCases {
+		var got Scope
+		err := json.Unmarshal([]byte(tc.data), &got)
+
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: Unmarshal returned %v, wantErr %v", tc.desc, err, tc.wantErr)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("%s: Unmarshal is %v, want %v", tc.desc, got, tc.want)
+		}
+
+		if tc.wantErr {
+			continue
+		}
+
+		b, err := json.Marshal(got)
+
+		if err != nil || string(b) != tc.data {
+			t.Errorf("%s: Marshal is %s, %v, want %s", tc.desc, b, err, tc.data)
+		}
+	}
+
+	if _, err := json.Marshal(Scope("public")); err == nil {
+		t.Errorf("Marshal should reject unknown scope")
+	}
+
+	if err := json.Unmarshal([]byte(`{"id": 1, "scope": "secret"}`), &Post{}); err == nil {
+		t.Errorf("Post with unknown scope should be rejected")
+	}
+}
+
+func TestPostUpdateRequest_Validate(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		req     *PostUpdateRequest
+		wantErr bool
+	}{
+		{"Unset", &PostUpdateRequest{}, false},
+		{"GroupsOnly", &PostUpdateRequest{Groups: []string{"DocBase"}}, false},
+		{"GroupKeepingGroups", &PostUpdateRequest{Scope: ScopeGroup.Ptr()}, false},
+		{"Group", &PostUpdateRequest{Scope: ScopeGroup.Ptr(), Groups: []string{"DocBase"}}, false},
+		{"GroupWithoutGroups", &PostUpdateRequest{Scope: ScopeGroup.Ptr(), Groups: []string{}}, true},
+		{"PrivateWithGroups", &PostUpdateRequest{Scope: ScopePrivate.Ptr(), Groups: []string{"DocBase"}}, true},
+		{"Unknown", &PostUpdateRequest{Scope: Scope("public").Ptr()}, true},
+	}
+	for _, tc := range testCases {
+		if err := tc.req.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: Validate returned %v, wantErr %v", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPost_Visibility(t *testing.T) {
+	everyone := &Post{Scope: ScopeEveryone}
+	group := &Post{Scope: ScopeGroup, Groups: []SimpleGroup{{ID: 1, Name: "DocBase"}}}
+	private := &Post{Scope: ScopePrivate}
+
+	if !everyone.IsPublic() || group.IsPublic() || private.IsPublic() {
+		t.Errorf("IsPublic should be true only for everyone scope")
+	}
+
+	if !private.IsPrivate() || everyone.IsPrivate() {
+		t.Errorf("IsPrivate should be true only for private scope")
+	}
+
+	testCases := []struct {
+		desc string
+		post *Post
+		name string
+		want bool
+	}{
+		{"Everyone", everyone, "DocBase", true},
+		{"Member", group, "DocBase", true},
+		{"OtherGroup", group, "kray-internal", false},
+		{"Private", private, "DocBase", false},
+	}
+	for _, tc := range testCases {
+		if got := tc.post.VisibleToGroup(tc.name); got != tc.want {
+			t.Errorf("%s: VisibleToGroup(%q) is %v, want %v", tc.desc, tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+
+	"github.com/hayashiki/docbase-go"
+)
+
+// newMarkdown returns the renderer of DocBase markdown: GitHub flavored, with line
+// breaks kept as in DocBase
+func newMarkdown(rawHTML bool) goldmark.Markdown {
+	opts := []goldmark.Option{
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	}
+
+	if rawHTML {
+		opts = append(opts, goldmark.WithRendererOptions(gmhtml.WithHardWraps(), gmhtml.WithUnsafe()))
+	} else {
+		opts = append(opts, goldmark.WithRendererOptions(gmhtml.WithHardWraps()))
+	}
+
+	return goldmark.New(opts...)
+}
+
+type site struct {
+	dir   string
+	title string
+	md    goldmark.Markdown
+	// files maps the URLs of the downloaded attachments to their paths in the site
+	files map[string]string
+	// pages maps the URLs of the exported posts to their IDs
+	pages map[string]int
+
+	tags, groups, authors map[string]*listing
+}
+
+// link is a link relative to the root of the site
+type link struct {
+	Name  string
+	Href  string
+	Count int
+}
+
+type entry struct {
+	Href    string
+	Title   string
+	Author  link
+	Created time.Time
+	Draft   bool
+	Tags    []link
+}
+
+type comment struct {
+	Author  string
+	Created time.Time
+	Body    template.HTML
+}
+
+// listing is the page of a tag, group or author
+type listing struct {
+	link
+	Posts []entry
+}
+
+type page struct {
+	Site  string
+	Title string
+	// Root is the relative path to the root of the site
+	Root string
+
+	Posts                 []entry
+	Tags, Groups, Authors []link
+
+	Post        *docbase.Post
+	Entry       entry
+	Body        template.HTML
+	Comments    []comment
+	Attachments []link
+}
+
+// searchEntry is an entry of the search index
+type searchEntry struct {
+	Title  string   `json:"title"`
+	Href   string   `json:"href"`
+	Author string   `json:"author"`
+	Tags   []string `json:"tags"`
+	Groups []string `json:"groups"`
+	Text   string   `json:"text"`
+}
+
+func (s *site) write(posts []*docbase.Post) error {
+	s.tags = make(map[string]*listing)
+	s.groups = make(map[string]*listing)
+	s.authors = make(map[string]*listing)
+	slugs := make(map[string]bool)
+
+	index := &page{Site: s.title, Title: s.title}
+	search := []searchEntry{}
+
+	for _, p := range posts {
+		e := entry{
+			Href:    "posts/" + strconv.Itoa(p.ID) + ".html",
+			Title:   p.Title,
+			Created: p.CreatedAt,
+			Draft:   p.Draft,
+		}
+
+		author := s.list(s.authors, slugs, "authors", strconv.Itoa(p.User.ID), p.User.Name)
+		e.Author = author.link
+
+		for _, t := range p.Tags {
+			e.Tags = append(e.Tags, s.list(s.tags, slugs, "tags", t.Name, t.Name).link)
+		}
+
+		var groups []link
+		for _, g := range p.Groups {
+			groups = append(groups, s.list(s.groups, slugs, "groups", g.Name, g.Name).link)
+		}
+
+		author.Posts = append(author.Posts, e)
+		for _, t := range p.Tags {
+			s.tags[t.Name].Posts = append(s.tags[t.Name].Posts, e)
+		}
+		for _, g := range p.Groups {
+			s.groups[g.Name].Posts = append(s.groups[g.Name].Posts, e)
+		}
+
+		index.Posts = append(index.Posts, e)
+
+		se := searchEntry{Title: p.Title, Href: e.Href, Author: p.User.Name, Tags: []string{}, Groups: []string{}, Text: p.Body}
+		for _, t := range p.Tags {
+			se.Tags = append(se.Tags, t.Name)
+		}
+		for _, g := range p.Groups {
+			se.Groups = append(se.Groups, g.Name)
+		}
+		search = append(search, se)
+
+		if err := s.writePost(p, e, groups); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range []struct {
+		lists map[string]*listing
+		links *[]link
+		title string
+	}{{s.tags, &index.Tags, "Tag"}, {s.groups, &index.Groups, "Group"}, {s.authors, &index.Authors, "Author"}} {
+		for _, list := range l.lists {
+			list.Count = len(list.Posts)
+			*l.links = append(*l.links, list.link)
+
+			p := &page{Site: s.title, Title: l.title + ": " + list.Name, Root: "../", Posts: list.Posts}
+			if err := s.render(list.Href, listTemplate, p); err != nil {
+				return err
+			}
+		}
+
+		sort.Slice(*l.links, func(i, j int) bool { return (*l.links)[i].Name < (*l.links)[j].Name })
+	}
+
+	if err := s.render("index.html", indexTemplate, index); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(search)
+
+	if err != nil {
+		return err
+	}
+
+	for name, content := range map[string][]byte{
+		"search.json":      b,
+		"assets/style.css": []byte(styleCSS),
+		"assets/search.js": []byte(searchJS),
+	} {
+		if err := s.writeFile(name, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list returns the listing of the key, adding it with a page of a unique name
+func (s *site) list(lists map[string]*listing, slugs map[string]bool, dir, key, name string) *listing {
+	if l, ok := lists[key]; ok {
+		return l
+	}
+
+	base := dir + "/" + slug(name)
+	href := base + ".html"
+	for n := 2; slugs[href]; n++ {
+		href = base + "-" + strconv.Itoa(n) + ".html"
+	}
+	slugs[href] = true
+
+	l := &listing{link: link{Name: name, Href: href}}
+	lists[key] = l
+
+	return l
+}
+
+// slug keeps the letters and digits of the name, in any script, for file names
+func slug(name string) string {
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, name)
+
+	s = strings.Trim(s, "-")
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func (s *site) writePost(p *docbase.Post, e entry, groups []link) error {
+	body, err := s.markdown(p.Body, "../")
+
+	if err != nil {
+		return err
+	}
+
+	pg := &page{Site: s.title, Title: p.Title, Root: "../", Post: p, Entry: e, Body: body, Groups: groups}
+
+	for _, c := range p.Comments {
+		html, err := s.markdown(c.Body, "../")
+
+		if err != nil {
+			return err
+		}
+
+		pg.Comments = append(pg.Comments, comment{Author: c.Name, Created: c.CreatedAt, Body: html})
+	}
+
+	for _, a := range p.Attachments {
+		pg.Attachments = append(pg.Attachments, link{Name: a.Name, Href: s.files[a.URL]})
+	}
+
+	return s.render(e.Href, postTemplate, pg)
+}
+
+func (s *site) markdown(md, root string) (template.HTML, error) {
+	var b bytes.Buffer
+	if err := s.md.Convert([]byte(s.localize(md, root)), &b); err != nil {
+		return "", err
+	}
+
+	return template.HTML(b.String()), nil
+}
+
+func (s *site) render(name string, t *template.Template, p *page) error {
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, "layout", p); err != nil {
+		return err
+	}
+
+	return s.writeFile(name, b.Bytes())
+}
+
+func (s *site) writeFile(name string, content []byte) error {
+	p := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, content, 0o644)
+}
@@ -0,0 +1,166 @@
+// Package site exports posts to a static HTML site, servable by any static file server.
+package site
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+	"github.com/hayashiki/docbase-go/internal/report"
+)
+
+// postLink matches links to posts, which are rewritten to the exported pages
+var postLink = regexp.MustCompile(`https?://[^\s()<>"'\]]+/posts/(\d+)`)
+
+// Report is the result of an export
+type Report struct {
+	Query       string `json:"query"`
+	Dir         string `json:"dir"`
+	Posts       int    `json:"posts"`
+	Comments    int    `json:"comments"`
+	Attachments int    `json:"attachments"`
+	Tags        int    `json:"tags"`
+	Groups      int    `json:"groups"`
+	Authors     int    `json:"authors"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	return report.WriteJSON(w, r)
+}
+
+// Print writes the counts of the export
+func (r *Report) Print(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Exported %d posts, %d comments and %d attachments to %s\n  %d tags, %d groups, %d authors\n",
+		r.Posts, r.Comments, r.Attachments, r.Dir, r.Tags, r.Groups, r.Authors)
+
+	return err
+}
+
+// Exporter writes posts to a static site
+type Exporter struct {
+	client *docbase.Client
+
+	// Title is the title of the site, the team by default
+	Title string
+	// RawHTML keeps the HTML of the markdown of posts. It is escaped by default, as the
+	// site is served without the sanitizing of DocBase.
+	RawHTML bool
+}
+
+// New returns an Exporter
+func New(c *docbase.Client) *Exporter {
+	return &Exporter{client: c}
+}
+
+// Run writes the posts matching the query, with their comments and attachments, to
+// dir, which must not exist or be empty. The site has a page per post, an index of the
+// posts by tag, group and author and a search over a JSON index built with it. Links
+// are relative, attachments are kept in the site and links between the exported posts
+// point to their pages.
+func (e *Exporter) Run(query, dir string) (*Report, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s is not empty", dir)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	posts, err := postiter.All(e.client, query)
+
+	if err != nil {
+		return nil, fmt.Errorf("listing posts: %w", err)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].CreatedAt.After(posts[j].CreatedAt) })
+
+	s := &site{dir: dir, title: e.Title, md: newMarkdown(e.RawHTML), files: make(map[string]string), pages: make(map[string]int)}
+	if s.title == "" {
+		s.title = e.client.Team
+	}
+
+	for _, p := range posts {
+		s.pages[p.URL] = p.ID
+	}
+
+	report := &Report{Query: query, Dir: dir, Posts: len(posts)}
+
+	for _, p := range posts {
+		if err := e.attachments(s, p); err != nil {
+			return nil, fmt.Errorf("post %d: %w", p.ID, err)
+		}
+
+		report.Comments += len(p.Comments)
+	}
+
+	if err := s.write(posts); err != nil {
+		return nil, err
+	}
+
+	report.Attachments = len(s.files)
+	report.Tags, report.Groups, report.Authors = len(s.tags), len(s.groups), len(s.authors)
+
+	return report, nil
+}
+
+// attachments downloads the attachments of the post, once for those shared by posts
+func (e *Exporter) attachments(s *site, p *docbase.Post) error {
+	for _, a := range p.Attachments {
+		id, name := path.Base(a.ID), path.Base(a.Name)
+		if id == "." || id == "/" || id == ".." {
+			return errors.New("attachment without ID")
+		}
+
+		if name == "." || name == "/" || name == ".." {
+			name = "file"
+		}
+
+		if _, ok := s.files[a.URL]; ok {
+			continue
+		}
+
+		content, _, err := e.client.Attachments.Download(a.ID)
+
+		if err != nil {
+			return fmt.Errorf("downloading %s: %w", a.Name, err)
+		}
+
+		dir := filepath.Join(s.dir, "attachments", id)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), *content, 0o644); err != nil {
+			return err
+		}
+
+		s.files[a.URL] = "attachments/" + url.PathEscape(id) + "/" + url.PathEscape(name)
+	}
+
+	return nil
+}
+
+// localize points the attachments and the exported posts the markdown refers to
+// to the site, relative to root
+func (s *site) localize(md, root string) string {
+	for from, to := range s.files {
+		md = strings.ReplaceAll(md, from, root+to)
+	}
+
+	return postLink.ReplaceAllStringFunc(md, func(link string) string {
+		if id, ok := s.pages[link]; ok {
+			return root + "posts/" + strconv.Itoa(id) + ".html"
+		}
+
+		return link
+	})
+}
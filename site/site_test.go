@@ -0,0 +1,175 @@
+package site
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+var team = fakeserver.Seed{
+	Posts: []fakeserver.Post{{
+		Author: 2,
+		Post: docbase.Post{
+			ID:        10,
+			Title:     "Setup",
+			Body:      "Steps\nin order",
+			Tags:      []docbase.Tag{{Name: "guide"}},
+			CreatedAt: time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}},
+}
+
+// addDesign adds a post showing an attachment and linking to the setup post
+func addDesign(s *fakeserver.Team) {
+	a := s.AddAttachment("diagram.png", []byte("\x89PNG image"))
+	s.Seed(fakeserver.Post{
+		Author: 1,
+		Post: docbase.Post{
+			Title:       "Design",
+			Body:        "# Design\n\n" + a.Markdown + "\n\nSee [setup](" + s.URL + "/posts/10) and <script>alert(1)</script>\n\n| a | b |\n| - | - |\n| 1 | 2 |",
+			Tags:        []docbase.Tag{{Name: "guide"}, {Name: "設計/API"}},
+			Groups:      []docbase.SimpleGroup{{Name: "dev"}},
+			Scope:       docbase.ScopeGroup,
+			Attachments: []docbase.Attachment{a},
+			CreatedAt:   time.Date(2019, 4, 2, 10, 0, 0, 0, time.UTC),
+		},
+	})
+}
+
+func read(t *testing.T, dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+
+	return string(b)
+}
+
+func TestExporter_Run(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	addDesign(s)
+	c := s.Client
+	dir := filepath.Join(t.TempDir(), "site")
+
+	e := New(c)
+	e.Title = "Handbook"
+	report, err := e.Run("tag:guide", dir)
+
+	if err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if report.Posts != 2 || report.Attachments != 1 || report.Tags != 2 || report.Groups != 1 || report.Authors != 2 {
+		t.Errorf("Report is %+v", report)
+	}
+
+	var design string
+	for _, p := range s.Posts() {
+		if p.Title == "Design" {
+			design = "posts/" + strconv.Itoa(p.ID) + ".html"
+		}
+	}
+
+	page := read(t, dir, design)
+
+	for _, want := range []string{
+		`<title>Design - Handbook</title>`,
+		`href="../assets/style.css"`,
+		`<h1 id="design">Design</h1>`,
+		`<img src="../attachments/`,
+		`<a href="../posts/10.html">setup</a>`,
+		`<table>`,
+		`href="../tags/guide.html">#guide</a>`,
+		`href="../tags/%e8%a8%ad%e8%a8%88-api.html">#設計/API</a>`,
+		`href="../groups/dev.html">dev</a>`,
+		`href="../authors/taro.html">Taro</a>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("the design page should contain %s:\n%s", want, page)
+		}
+	}
+
+	if strings.Contains(page, "<script>alert") || strings.Contains(page, s.URL) {
+		t.Errorf("the design page should not have raw HTML nor links to the team:\n%s", page)
+	}
+
+	if setup := read(t, dir, "posts/10.html"); !strings.Contains(setup, "Steps<br>\nin order") {
+		t.Errorf("line breaks should be kept:\n%s", setup)
+	}
+
+	read(t, dir, "tags/設計-api.html")
+
+	index := read(t, dir, "index.html")
+	if strings.Index(index, ">Design</a>") > strings.Index(index, ">Setup</a>") || !strings.Contains(index, `<a href="tags/guide.html">guide</a> <span class="count">2</span>`) {
+		t.Errorf("index is\n%s", index)
+	}
+
+	if tag := read(t, dir, "tags/guide.html"); !strings.Contains(tag, `href="../posts/10.html">Setup</a>`) || !strings.Contains(tag, `href="../`+design+`">Design</a>`) {
+		t.Errorf("tag page is\n%s", tag)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "attachments", "*", "diagram.png"))
+	if len(matches) != 1 {
+		t.Fatalf("attachments are %v", matches)
+	}
+
+	if b, _ := os.ReadFile(matches[0]); string(b) != "\x89PNG image" {
+		t.Errorf("attachment has %q", b)
+	}
+
+	var search []searchEntry
+	if err := json.Unmarshal([]byte(read(t, dir, "search.json")), &search); err != nil || len(search) != 2 {
+		t.Fatalf("search index is %+v, %v", search, err)
+	}
+
+	if search[0].Title != "Design" || search[0].Href != design || search[0].Author != "Taro" || len(search[0].Tags) != 2 || search[0].Groups[0] != "dev" {
+		t.Errorf("search entry is %+v", search[0])
+	}
+
+	for _, asset := range []string{"assets/style.css", "assets/search.js"} {
+		read(t, dir, asset)
+	}
+}
+
+func TestPostTemplate_WithoutUpdatedAt(t *testing.T) {
+	p := &page{Title: "Setup", Root: "../", Post: &docbase.Post{Title: "Setup", CreatedAt: time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)}}
+
+	var b strings.Builder
+	if err := postTemplate.ExecuteTemplate(&b, "layout", p); err != nil {
+		t.Fatalf("ExecuteTemplate returned an error: %v", err)
+	}
+
+	if !strings.Contains(b.String(), "created 2019-04-01 10:00") || strings.Contains(b.String(), "updated") {
+		t.Errorf("the page should show the creation time only:\n%s", b.String())
+	}
+}
+
+func TestExporter_RunNotEmpty(t *testing.T) {
+	c := fakeserver.NewTeam(t, team).Client
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("mine"), 0o644)
+
+	if _, err := New(c).Run("", dir); err == nil {
+		t.Errorf("Run should not write to a directory that is not empty")
+	}
+
+	if read(t, dir, "index.html") != "mine" {
+		t.Errorf("index.html should be left")
+	}
+}
+
+func TestSlug(t *testing.T) {
+	for name, want := range map[string]string{"Guide": "guide", "設計/API": "設計-api", "C++": "c", "///": "-"} {
+		if got := slug(name); got != want {
+			t.Errorf("slug(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
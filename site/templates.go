@@ -0,0 +1,142 @@
+package site
+
+import "html/template"
+
+const layoutHTML = `{{define "layout"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if ne .Title .Site}}{{.Title}} - {{end}}{{.Site}}</title>
+<link rel="stylesheet" href="{{.Root}}assets/style.css">
+</head>
+<body>
+<header><a href="{{.Root}}index.html">{{.Site}}</a></header>
+<main>
+{{template "content" .}}
+</main>
+</body>
+</html>
+{{end}}
+
+{{define "posts"}}<ul class="posts">
+{{- range .Posts}}
+<li><a href="{{$.Root}}{{.Href}}">{{.Title}}</a>{{if .Draft}} <span class="draft">draft</span>{{end}}
+<span class="meta"><a href="{{$.Root}}{{.Author.Href}}">{{.Author.Name}}</a> {{.Created.Format "2006-01-02"}}</span>
+{{- range .Tags}} <a class="tag" href="{{$.Root}}{{.Href}}">#{{.Name}}</a>{{end}}</li>
+{{- end}}
+</ul>{{end}}
+
+{{define "links"}}<ul class="links">
+{{- range .}}
+<li><a href="{{.Href}}">{{.Name}}</a> <span class="count">{{.Count}}</span></li>
+{{- end}}
+</ul>{{end}}
+`
+
+const indexHTML = `{{define "content"}}<h1>{{.Title}}</h1>
+<input id="search" type="search" placeholder="Search" autocomplete="off">
+<ul id="results" class="posts"></ul>
+<div class="columns">
+<section><h2>Posts</h2>
+{{template "posts" .}}
+</section>
+<nav>
+{{if .Tags}}<h2>Tags</h2>{{template "links" .Tags}}{{end}}
+{{if .Groups}}<h2>Groups</h2>{{template "links" .Groups}}{{end}}
+{{if .Authors}}<h2>Authors</h2>{{template "links" .Authors}}{{end}}
+</nav>
+</div>
+<script src="assets/search.js"></script>
+{{end}}`
+
+const postHTML = `{{define "content"}}<article>
+<h1>{{.Title}}{{if .Post.Draft}} <span class="draft">draft</span>{{end}}</h1>
+<p class="meta"><a href="{{.Root}}{{.Entry.Author.Href}}">{{.Entry.Author.Name}}</a>
+created {{.Post.CreatedAt.Format "2006-01-02 15:04"}}{{if not .Post.UpdatedAt.IsZero}}, updated {{.Post.UpdatedAt.Format "2006-01-02 15:04"}}{{end}}
+{{- range .Entry.Tags}} <a class="tag" href="{{$.Root}}{{.Href}}">#{{.Name}}</a>{{end}}
+{{- range .Groups}} <a class="group" href="{{$.Root}}{{.Href}}">{{.Name}}</a>{{end}}</p>
+<div class="body">
+{{.Body}}
+</div>
+{{if .Attachments}}<section class="attachments"><h2>Attachments</h2><ul>
+{{- range .Attachments}}
+<li><a href="{{$.Root}}{{.Href}}">{{.Name}}</a></li>
+{{- end}}
+</ul></section>{{end}}
+{{if .Comments}}<section class="comments"><h2>Comments</h2>
+{{- range .Comments}}
+<div class="comment"><p class="meta">{{.Author}} {{.Created.Format "2006-01-02 15:04"}}</p>
+{{.Body}}
+</div>
+{{- end}}
+</section>{{end}}
+</article>
+{{end}}`
+
+const listHTML = `{{define "content"}}<h1>{{.Title}}</h1>
+{{template "posts" .}}
+{{end}}`
+
+var (
+	indexTemplate = template.Must(template.Must(template.New("index").Parse(layoutHTML)).Parse(indexHTML))
+	postTemplate  = template.Must(template.Must(template.New("post").Parse(layoutHTML)).Parse(postHTML))
+	listTemplate  = template.Must(template.Must(template.New("list").Parse(layoutHTML)).Parse(listHTML))
+)
+
+const styleCSS = `body { margin: 0; font-family: -apple-system, "Segoe UI", "Hiragino Sans", Meiryo, sans-serif; color: #333; line-height: 1.7; }
+header { padding: 12px 24px; background: #3e7ec6; }
+header a { color: #fff; font-weight: bold; text-decoration: none; }
+main { max-width: 1080px; margin: 0 auto; padding: 16px 24px; }
+a { color: #3e7ec6; }
+.columns { display: flex; gap: 32px; }
+.columns section { flex: 3; }
+.columns nav { flex: 1; }
+.posts, .links { list-style: none; padding: 0; }
+.posts li { padding: 6px 0; border-bottom: 1px solid #eee; }
+.meta, .count { color: #888; font-size: 0.85em; margin-left: 8px; }
+.tag, .group { font-size: 0.85em; margin-left: 6px; }
+.draft { background: #eee; border-radius: 3px; padding: 0 6px; font-size: 0.8em; }
+#search { width: 100%; padding: 8px; font-size: 1em; box-sizing: border-box; }
+.body img { max-width: 100%; }
+.body pre { background: #f6f8fa; padding: 12px; overflow: auto; }
+.body table { border-collapse: collapse; }
+.body th, .body td { border: 1px solid #ddd; padding: 4px 8px; }
+.comment { border-top: 1px solid #eee; }
+`
+
+const searchJS = `(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  var index = [];
+
+  fetch("search.json").then(function (res) { return res.json(); }).then(function (entries) {
+    index = entries.map(function (e) {
+      e.haystack = [e.title, e.author, e.tags.join(" "), e.groups.join(" "), e.text].join("\n").toLowerCase();
+      return e;
+    });
+    search();
+  });
+
+  function search() {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.textContent = "";
+    if (terms.length === 0) {
+      return;
+    }
+
+    index.filter(function (e) {
+      return terms.every(function (t) { return e.haystack.indexOf(t) >= 0; });
+    }).forEach(function (e) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = e.href;
+      a.textContent = e.title;
+      li.appendChild(a);
+      results.appendChild(li);
+    });
+  }
+
+  input.addEventListener("input", search);
+})();
+`
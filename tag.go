@@ -2,7 +2,6 @@ package docbase
 
 import (
 	"net/http"
-	"net/url"
 )
 
 // TagService implements interface with API /tags endpoint.
@@ -24,19 +23,5 @@ type Tag struct {
 type TagListResponse []Tag
 
 func (s *tagService) List() (*TagListResponse, *Response, error) {
-	u, err := url.Parse("/tags")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
-
-	tagResp := &TagListResponse{}
-	resp, err := s.client.Do(req, tagResp)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return tagResp, resp, err
+	return doJSON[TagListResponse](s.client, http.MethodGet, "/tags", nil)
 }
@@ -0,0 +1,259 @@
+package tagstats
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// Separator divides the levels of hierarchical tags like "infra/aws"
+const Separator = "/"
+
+// Key returns the form near-duplicate tags share. Each level of a hierarchical tag is
+// folded to narrow width and lower case, kana are romanized and English plurals made singular.
+func Key(tag string) string {
+	levels := strings.Split(tag, Separator)
+	for i, l := range levels {
+		l = strings.ToLower(width.Fold.String(strings.TrimSpace(l)))
+		l = romanize(l)
+		levels[i] = singular(l)
+	}
+
+	return strings.Join(levels, Separator)
+}
+
+// Parent returns the parent of a hierarchical tag, or "" for a top level tag
+func Parent(tag string) string {
+	i := strings.LastIndex(tag, Separator)
+	if i < 0 {
+		return ""
+	}
+
+	return tag[:i]
+}
+
+// Leaf returns the last level of a hierarchical tag
+func Leaf(tag string) string {
+	return tag[strings.LastIndex(tag, Separator)+1:]
+}
+
+// singular strips English plural suffixes of words longer than 3 letters
+func singular(s string) string {
+	if len(s) <= 3 || !isASCIIWord(s) {
+		return s
+	}
+
+	switch {
+	case strings.HasSuffix(s, "ies"):
+		return strings.TrimSuffix(s, "ies") + "y"
+	case strings.HasSuffix(s, "sses"), strings.HasSuffix(s, "xes"), strings.HasSuffix(s, "ches"), strings.HasSuffix(s, "shes"):
+		return strings.TrimSuffix(s, "es")
+	case strings.HasSuffix(s, "ss"), strings.HasSuffix(s, "us"), strings.HasSuffix(s, "is"):
+		return s
+	case strings.HasSuffix(s, "s"):
+		return strings.TrimSuffix(s, "s")
+	}
+
+	return s
+}
+
+func isASCIIWord(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// romanize converts kana to Hepburn romaji, so "テスト", "てすと" and "tesuto" share a key.
+// Long vowels are shortened as romaji spellings of them vary, in the romaji of kana and in
+// tags spelled in romaji only, so "トウキョウ" and "toukyou" share one too. Other letters are
+// kept as is, "queue" and the "boot" of "bootキャンプ" alike.
+func romanize(s string) string {
+	if !hasKana(s) {
+		if isRomaji(s) {
+			return shortenVowels(s)
+		}
+		return s
+	}
+
+	rs := []rune(s)
+	var b, run strings.Builder
+
+	// the romaji of the kana read so far, shortened apart from the letters around
+	flush := func() {
+		b.WriteString(shortenVowels(run.String()))
+		run.Reset()
+	}
+
+	for i := 0; i < len(rs); i++ {
+		r := toHiragana(rs[i])
+
+		if r == 'っ' {
+			// doubles the next consonant
+			if i+1 < len(rs) {
+				if next := romajiAt(rs, i+1); strings.HasPrefix(next, "ch") {
+					run.WriteByte('t')
+				} else if next != "" {
+					run.WriteByte(next[0])
+				}
+			}
+			continue
+		}
+
+		if r == 'ー' {
+			continue
+		}
+
+		if i+1 < len(rs) {
+			if ro, ok := youon[string([]rune{r, toHiragana(rs[i+1])})]; ok {
+				run.WriteString(ro)
+				i++
+				continue
+			}
+		}
+
+		if ro, ok := kana[r]; ok {
+			run.WriteString(ro)
+			continue
+		}
+
+		flush()
+		b.WriteRune(rs[i])
+	}
+
+	flush()
+	return b.String()
+}
+
+func romajiAt(rs []rune, i int) string {
+	r := toHiragana(rs[i])
+
+	if i+1 < len(rs) {
+		if ro, ok := youon[string([]rune{r, toHiragana(rs[i+1])})]; ok {
+			return ro
+		}
+	}
+
+	return kana[r]
+}
+
+func shortenVowels(s string) string {
+	for _, long := range []string{"ou", "oo", "uu", "aa", "ii", "ee"} {
+		s = strings.ReplaceAll(s, long, long[:1])
+	}
+
+	return s
+}
+
+// isRomaji reports whether s is spelled with the romaji of kana only, like "toukyou"
+// but not "boot"
+func isRomaji(s string) bool {
+	for s != "" {
+		n := romajiSyllable(s)
+		if n == 0 {
+			return false
+		}
+		s = s[n:]
+	}
+
+	return true
+}
+
+// romajiSyllable returns the length of the romaji of a kana s starts with, or 0
+func romajiSyllable(s string) int {
+	if strings.IndexByte("aiueo", s[0]) >= 0 {
+		return 1
+	}
+
+	// ん, unless a vowel or y follows it
+	if s[0] == 'n' && (len(s) == 1 || strings.IndexByte("aiueoy", s[1]) < 0) {
+		return 1
+	}
+
+	// the doubled consonant of っ, like the first t of "chotto" or "matcha"
+	if len(s) > 2 && (s[0] != 'n' && s[0] == s[1] && romajiOnsets[s[1:2]] || s[:3] == "tch") {
+		return 1
+	}
+
+	for n := 3; n >= 1; n-- {
+		if len(s) > n && romajiOnsets[s[:n]] && strings.IndexByte("aiueo", s[n]) >= 0 {
+			return n + 1
+		}
+	}
+
+	return 0
+}
+
+// romajiOnsets are the consonants the romaji of kana start with, like "k", "sh" and "ky"
+var romajiOnsets = func() map[string]bool {
+	onsets := make(map[string]bool)
+	for _, ro := range kana {
+		if len(ro) > 1 {
+			onsets[ro[:len(ro)-1]] = true
+		}
+	}
+	for _, ro := range youon {
+		onsets[ro[:len(ro)-1]] = true
+	}
+
+	return onsets
+}()
+
+func hasKana(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toHiragana maps katakana to hiragana, which are 0x60 apart
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - 0x60
+	}
+
+	return r
+}
+
+var kana = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'を': "o", 'ん': "n",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゔ': "vu",
+}
+
+var youon = map[string]string{
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"てぃ": "ti", "でぃ": "di", "ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo", "ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+}
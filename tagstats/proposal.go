@@ -0,0 +1,196 @@
+package tagstats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/width"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hayashiki/docbase-go/bulktag"
+)
+
+// Merge merges tags into one
+type Merge struct {
+	Into   string   `yaml:"into"`
+	From   []string `yaml:"from"`
+	Reason string   `yaml:"reason,omitempty"`
+	Posts  int      `yaml:"posts,omitempty"` // posts having the tags of From
+}
+
+// Rename renames a tag
+type Rename struct {
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Reason string `yaml:"reason,omitempty"`
+	Posts  int    `yaml:"posts,omitempty"`
+}
+
+// Proposal is a cleanup of tags, to be reviewed and edited before applying
+type Proposal struct {
+	Merges  []Merge  `yaml:"merges"`
+	Renames []Rename `yaml:"renames"`
+}
+
+const proposalHeader = `# Tag cleanup proposal.
+# Review the entries, edit or delete them, then apply the file.
+`
+
+// Propose merges near-duplicate tags into the most used of them, flat tags into the
+// only hierarchical tag with the same leaf, and renames tags written in full-width
+// or half-width characters. Tags on no posts are left out.
+func Propose(a *Analysis) *Proposal {
+	p := &Proposal{}
+	merged := make(map[string]bool)
+
+	groups := make(map[string][]*TagStats)
+	for _, s := range a.Sorted() {
+		if s.Posts > 0 {
+			groups[Key(s.Name)] = append(groups[Key(s.Name)], s)
+		}
+	}
+
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+
+		// sorted by posts, so the first is the most used
+		m := Merge{Into: g[0].Name}
+		reasons := make(map[string]bool)
+
+		for _, s := range g[1:] {
+			m.From = append(m.From, s.Name)
+			m.Posts += s.Posts
+			merged[s.Name] = true
+			reasons[reason(s.Name, m.Into)] = true
+		}
+
+		merged[m.Into] = true
+		m.Reason = joinKeys(reasons)
+		p.Merges = append(p.Merges, m)
+	}
+
+	p.Merges = append(p.Merges, proposeHierarchy(a, merged)...)
+
+	for _, s := range a.Sorted() {
+		if s.Posts == 0 || merged[s.Name] {
+			continue
+		}
+
+		if folded := width.Fold.String(strings.TrimSpace(s.Name)); folded != s.Name {
+			p.Renames = append(p.Renames, Rename{From: s.Name, To: folded, Reason: "width", Posts: s.Posts})
+		}
+	}
+
+	sort.Slice(p.Merges, func(i, j int) bool { return p.Merges[i].Into < p.Merges[j].Into })
+	sort.Slice(p.Renames, func(i, j int) bool { return p.Renames[i].From < p.Renames[j].From })
+
+	return p
+}
+
+// proposeHierarchy merges a flat tag like "aws" into "infra/aws" when that is the only
+// hierarchical tag with the leaf
+func proposeHierarchy(a *Analysis, merged map[string]bool) []Merge {
+	byLeaf := make(map[string][]string)
+	for name, s := range a.Tags {
+		if s.Posts > 0 && Parent(name) != "" {
+			byLeaf[Key(Leaf(name))] = append(byLeaf[Key(Leaf(name))], name)
+		}
+	}
+
+	var merges []Merge
+	for _, s := range a.Sorted() {
+		if s.Posts == 0 || merged[s.Name] || Parent(s.Name) != "" {
+			continue
+		}
+
+		if candidates := byLeaf[Key(s.Name)]; len(candidates) == 1 {
+			merges = append(merges, Merge{Into: candidates[0], From: []string{s.Name}, Reason: "hierarchy", Posts: s.Posts})
+			merged[s.Name] = true
+		}
+	}
+
+	return merges
+}
+
+// reason tells how the tag differs from the one it is merged into
+func reason(from, into string) string {
+	switch {
+	case strings.EqualFold(from, into):
+		return "case"
+	case strings.EqualFold(width.Fold.String(from), width.Fold.String(into)):
+		return "width"
+	case hasKana(from) != hasKana(into):
+		return "kana/romaji"
+	case hasKana(from):
+		return "kana"
+	}
+
+	return "plural"
+}
+
+func joinKeys(set map[string]bool) string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// WriteYAML writes the proposal with a header for reviewers
+func (p *Proposal) WriteYAML(w io.Writer) error {
+	if _, err := io.WriteString(w, proposalHeader); err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// LoadProposal reads a proposal written by WriteYAML, usually after review
+func LoadProposal(r io.Reader) (*Proposal, error) {
+	p := &Proposal{}
+
+	if err := yaml.NewDecoder(r).Decode(p); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	for _, m := range p.Merges {
+		if err := bulktag.MergeTags(m.Into, m.From...).Validate(); err != nil {
+			return nil, fmt.Errorf("merge into %q: %w", m.Into, err)
+		}
+	}
+
+	for _, r := range p.Renames {
+		if err := bulktag.RenameTag(r.From, r.To).Validate(); err != nil {
+			return nil, fmt.Errorf("rename %q: %w", r.From, err)
+		}
+	}
+
+	return p, nil
+}
+
+// Operations returns the bulk tag operations carrying out the proposal
+func (p *Proposal) Operations() []bulktag.Operation {
+	var ops []bulktag.Operation
+	for _, m := range p.Merges {
+		ops = append(ops, bulktag.MergeTags(m.Into, m.From...))
+	}
+
+	for _, r := range p.Renames {
+		ops = append(ops, bulktag.RenameTag(r.From, r.To))
+	}
+
+	return ops
+}
@@ -0,0 +1,220 @@
+// Package tagstats computes tag usage and proposes a cleanup of near-duplicate tags.
+package tagstats
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/postiter"
+)
+
+// TagStats is the usage of a tag
+type TagStats struct {
+	Name  string
+	Posts int
+	// LastUsed is the latest update, or creation when not updated, of the posts having the tag
+	LastUsed time.Time
+}
+
+// Pair is a tag used together with another, and the number of posts having both
+type Pair struct {
+	Tag   string
+	Posts int
+}
+
+// Node is a level of the tag hierarchy. Posts counts each post having the tag
+// or any descendant once.
+type Node struct {
+	Name     string
+	Tag      *TagStats // nil when no post has the tag itself
+	Posts    int
+	Children []*Node
+}
+
+// Analysis is the tag usage of the posts matching a search
+type Analysis struct {
+	Query string
+	Posts int
+	Tags  map[string]*TagStats
+
+	pairs   map[string]map[string]int
+	postIDs map[string][]int
+}
+
+// Analyze pages through the posts matching q, all posts when empty, and counts their tags.
+// Tags of Tags.List on no matching post are included with no posts.
+func Analyze(c *docbase.Client, q string) (*Analysis, error) {
+	a := &Analysis{
+		Query:   q,
+		Tags:    make(map[string]*TagStats),
+		pairs:   make(map[string]map[string]int),
+		postIDs: make(map[string][]int),
+	}
+
+	tags, _, err := c.Tags.List()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range *tags {
+		a.tag(t.Name)
+	}
+
+	err = postiter.Each(c, q, func(p *docbase.Post) error {
+		a.Add(p)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Add counts the tags of a post
+func (a *Analysis) Add(p *docbase.Post) {
+	a.Posts++
+
+	for i, t := range p.Tags {
+		s := a.tag(t.Name)
+		s.Posts++
+
+		if updated := p.LastUpdatedAt(); updated.After(s.LastUsed) {
+			s.LastUsed = updated
+		}
+
+		a.postIDs[t.Name] = append(a.postIDs[t.Name], p.ID)
+
+		for _, other := range p.Tags[i+1:] {
+			a.pair(t.Name, other.Name)
+			a.pair(other.Name, t.Name)
+		}
+	}
+}
+
+func (a *Analysis) tag(name string) *TagStats {
+	if a.Tags == nil {
+		a.Tags = make(map[string]*TagStats)
+		a.pairs = make(map[string]map[string]int)
+		a.postIDs = make(map[string][]int)
+	}
+
+	s, ok := a.Tags[name]
+	if !ok {
+		s = &TagStats{Name: name}
+		a.Tags[name] = s
+	}
+
+	return s
+}
+
+func (a *Analysis) pair(tag, other string) {
+	if a.pairs[tag] == nil {
+		a.pairs[tag] = make(map[string]int)
+	}
+
+	a.pairs[tag][other]++
+}
+
+// Sorted returns the tags by the number of posts, then by name
+func (a *Analysis) Sorted() []*TagStats {
+	tags := make([]*TagStats, 0, len(a.Tags))
+	for _, s := range a.Tags {
+		tags = append(tags, s)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Posts != tags[j].Posts {
+			return tags[i].Posts > tags[j].Posts
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags
+}
+
+// CoOccurring returns up to n tags most often used with the tag, all when n is 0
+func (a *Analysis) CoOccurring(tag string, n int) []Pair {
+	var pairs []Pair
+	for other, count := range a.pairs[tag] {
+		pairs = append(pairs, Pair{Tag: other, Posts: count})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Posts != pairs[j].Posts {
+			return pairs[i].Posts > pairs[j].Posts
+		}
+		return pairs[i].Tag < pairs[j].Tag
+	})
+
+	if n > 0 && len(pairs) > n {
+		pairs = pairs[:n]
+	}
+
+	return pairs
+}
+
+// Tree returns the hierarchy of tags like "infra/aws" under "infra", top levels sorted by name
+func (a *Analysis) Tree() []*Node {
+	nodes := make(map[string]*Node)
+	var roots []*Node
+
+	var node func(name string) *Node
+	node = func(name string) *Node {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+
+		n := &Node{Name: name}
+		nodes[name] = n
+
+		if parent := Parent(name); parent != "" {
+			p := node(parent)
+			p.Children = append(p.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+
+		return n
+	}
+
+	for name, s := range a.Tags {
+		node(name).Tag = s
+	}
+
+	for _, root := range roots {
+		a.countTree(root)
+	}
+
+	sortNodes(roots)
+	return roots
+}
+
+// countTree sets Posts of the subtree and returns the post IDs in it
+func (a *Analysis) countTree(n *Node) map[int]bool {
+	ids := make(map[int]bool)
+	for _, id := range a.postIDs[n.Name] {
+		ids[id] = true
+	}
+
+	for _, c := range n.Children {
+		for id := range a.countTree(c) {
+			ids[id] = true
+		}
+	}
+
+	n.Posts = len(ids)
+	return ids
+}
+
+func sortNodes(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool { return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name) })
+
+	for _, n := range nodes {
+		sortNodes(n.Children)
+	}
+}
@@ -0,0 +1,158 @@
+package tagstats
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+func TestKey(t *testing.T) {
+	testCases := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"Go", "go", "ＧＯ"}, "go"},
+		{[]string{"テスト", "てすと", "ﾃｽﾄ", "tesuto"}, "tesuto"},
+		{[]string{"サーバー", "サーバ"}, "saba"},
+		{[]string{"ちょっと", "chotto"}, "chotto"},
+		{[]string{"libraries", "library"}, "library"},
+		{[]string{"meetings", "meeting"}, "meeting"},
+		{[]string{"Infra/AWS", "infra/aws"}, "infra/aws"},
+		{[]string{"status"}, "status"},
+		// romaji and kana
+		{[]string{"トウキョウ", "とうきょう", "toukyou", "tookyoo", "tokyo"}, "tokyo"},
+		{[]string{"ラーメン", "raamen", "ramen"}, "ramen"},
+		{[]string{"マッチャ", "matcha"}, "matcha"},
+		{[]string{"queue"}, "queue"},
+		{[]string{"boot"}, "boot"},
+		{[]string{"meetings"}, "meeting"},
+		// ASCII and kana mixed
+		{[]string{"APIサーバー", "apiサーバ"}, "apisaba"},
+		{[]string{"queueサーバー"}, "queuesaba"},
+		{[]string{"bootキャンプ"}, "bootkyanpu"},
+		{[]string{"Go言語"}, "go言語"},
+	}
+	for _, tc := range testCases {
+		for _, tag := range tc.tags {
+			if got := Key(tag); got != tc.want {
+				t.Errorf("Key(%q) is %q, want %q", tag, got, tc.want)
+			}
+		}
+	}
+}
+
+func newAnalysis(t *testing.T) *Analysis {
+	s := fakeserver.NewTeam(t, fakeserver.Seed{Users: []docbase.User{fakeserver.Taro}})
+
+	day := time.Date(2020, 3, 27, 9, 25, 9, 0, time.UTC)
+	for i, tags := range [][]string{
+		{"go", "infra/aws"},
+		{"go", "infra/aws", "meetings"},
+		{"Go", "infra"},
+		{"meeting", "aws"},
+		{"ＡＰＩ"},
+	} {
+		p := docbase.Post{Title: "Post", UpdatedAt: day.AddDate(0, 0, i)}
+		for _, name := range tags {
+			p.Tags = append(p.Tags, docbase.Tag{Name: name})
+		}
+
+		s.AddPost(p, 1)
+	}
+
+	a, err := Analyze(s.Client, "")
+
+	if err != nil {
+		t.Fatalf("Analyze returned an error: %v", err)
+	}
+
+	return a
+}
+
+func TestAnalyze(t *testing.T) {
+	a := newAnalysis(t)
+
+	if a.Posts != 5 {
+		t.Errorf("Posts is %d, want 5", a.Posts)
+	}
+
+	sorted := a.Sorted()
+	if sorted[0].Name != "go" || sorted[0].Posts != 2 || sorted[1].Name != "infra/aws" {
+		t.Errorf("Sorted starts with %+v, %+v", sorted[0], sorted[1])
+	}
+
+	if got, want := a.Tags["go"].LastUsed, time.Date(2020, 3, 28, 9, 25, 9, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("LastUsed is %v, want %v", got, want)
+	}
+
+	created := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	a.Add(&docbase.Post{ID: 100, Tags: []docbase.Tag{{Name: "legacy"}}, CreatedAt: created})
+
+	if got := a.Tags["legacy"].LastUsed; !got.Equal(created) {
+		t.Errorf("LastUsed of a post without updated_at is %v, want its creation %v", got, created)
+	}
+
+	if got, want := a.CoOccurring("go", 1), []Pair{{Tag: "infra/aws", Posts: 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CoOccurring returned %+v, want %+v", got, want)
+	}
+
+	var infra *Node
+	for _, n := range a.Tree() {
+		if n.Name == "infra" {
+			infra = n
+		}
+	}
+
+	if infra == nil || infra.Tag == nil || infra.Posts != 3 || len(infra.Children) != 1 || infra.Children[0].Posts != 2 {
+		t.Errorf("infra node is %+v", infra)
+	}
+}
+
+func TestPropose(t *testing.T) {
+	p := Propose(newAnalysis(t))
+
+	wantMerges := []Merge{
+		{Into: "go", From: []string{"Go"}, Reason: "case", Posts: 1},
+		{Into: "infra/aws", From: []string{"aws"}, Reason: "hierarchy", Posts: 1},
+		{Into: "meeting", From: []string{"meetings"}, Reason: "plural", Posts: 1},
+	}
+
+	if !reflect.DeepEqual(p.Merges, wantMerges) {
+		t.Errorf("Merges are %+v, want %+v", p.Merges, wantMerges)
+	}
+
+	wantRenames := []Rename{{From: "ＡＰＩ", To: "API", Reason: "width", Posts: 1}}
+
+	if !reflect.DeepEqual(p.Renames, wantRenames) {
+		t.Errorf("Renames are %+v, want %+v", p.Renames, wantRenames)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteYAML(&buf); err != nil {
+		t.Fatalf("WriteYAML returned an error: %v", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "# Tag cleanup proposal.") {
+		t.Errorf("WriteYAML wrote\n%s", buf.String())
+	}
+
+	loaded, err := LoadProposal(&buf)
+
+	if err != nil || !reflect.DeepEqual(loaded, p) {
+		t.Errorf("LoadProposal returned %+v, %v, want %+v", loaded, err, p)
+	}
+
+	ops := loaded.Operations()
+	if len(ops) != 4 || ops[0].String() != "merge Go -> go" || ops[3].String() != "rename ＡＰＩ -> API" {
+		t.Errorf("Operations are %v", ops)
+	}
+
+	if _, err := LoadProposal(strings.NewReader("renames:\n  - from: a\n")); err == nil {
+		t.Errorf("LoadProposal should reject a rename without a new name")
+	}
+}
@@ -0,0 +1,274 @@
+// Package trash deletes posts safely by keeping a local copy they can be restored from.
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/repost"
+)
+
+const (
+	itemFile      = "item.json"
+	attachmentDir = "attachments"
+)
+
+var (
+	// ErrNotInTrash is returned when the trash has no copy of the post
+	ErrNotInTrash = errors.New("post is not in the trash")
+	// ErrRestored is returned when the post was already restored
+	ErrRestored = errors.New("post was already restored")
+)
+
+// Item is a deleted post kept in the trash
+type Item struct {
+	Post      *docbase.Post `json:"post"`
+	DeletedAt time.Time     `json:"deleted_at"`
+	// RestoredAs is the ID of the post re-created by Restore
+	RestoredAs int `json:"restored_as,omitempty"`
+}
+
+// Trash keeps deleted posts in a directory, a subdirectory per post
+// with the post, its comments and the files of its attachments
+type Trash struct {
+	client *docbase.Client
+	dir    string
+	now    func() time.Time
+}
+
+// New returns a Trash in dir, which is created if missing
+func New(c *docbase.Client, dir string) (*Trash, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &Trash{client: c, dir: dir, now: time.Now}, nil
+}
+
+func (t *Trash) itemDir(postID int) string {
+	return filepath.Join(t.dir, strconv.Itoa(postID))
+}
+
+// Delete fetches the post with its comments and attachments, keeps them in the trash
+// and then deletes the post. Nothing is deleted if the copy cannot be made.
+// When the deletion fails, the copy is dropped only if the server refused it, as the post
+// may have been deleted otherwise. The item is then returned along with the error.
+func (t *Trash) Delete(postID int) (*Item, error) {
+	// the copy must be of the post as it is, not as the response cache has it
+	post, _, err := docbase.GetUncachedJSON[docbase.Post](t.client, fmt.Sprintf("/posts/%d", postID), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// written aside and renamed, so a half-made copy is never in the trash
+	tmp, err := os.MkdirTemp(t.dir, ".deleting-")
+
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := t.saveAttachments(tmp, post.Attachments); err != nil {
+		return nil, err
+	}
+
+	item := &Item{Post: post, DeletedAt: t.now()}
+
+	if err := writeItem(tmp, item); err != nil {
+		return nil, err
+	}
+
+	dir := t.itemDir(postID)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+
+	if _, err := t.client.Posts.Delete(postID); err != nil {
+		if refused(err) {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+
+		return item, fmt.Errorf("deleting post %d, kept in the trash: %w", postID, err)
+	}
+
+	return item, nil
+}
+
+// refused reports whether the server answered err with a client error, so the post still
+// exists. A missing post, a server error or no response leave that unknown.
+func refused(err error) bool {
+	var rateErr *docbase.RateLimitError
+	if errors.As(err, &rateErr) {
+		return true
+	}
+
+	var errResp *docbase.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+
+	code := errResp.Response.StatusCode
+	return code >= 400 && code < 500 && code != http.StatusNotFound
+}
+
+func (t *Trash) saveAttachments(dir string, attachments []docbase.Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	for _, a := range attachments {
+		content, _, err := t.client.Attachments.Download(a.ID)
+
+		if err != nil {
+			return fmt.Errorf("downloading %s: %w", a.Name, err)
+		}
+
+		path := t.attachmentPath(dir, a)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(path, *content, 0o600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachmentPath keeps the original name, which is uploaded again on restore,
+// in a directory of the ID as names may repeat
+func (t *Trash) attachmentPath(dir string, a docbase.Attachment) string {
+	name := filepath.Base(a.Name)
+	if name == "." || name == string(filepath.Separator) {
+		name = "file"
+	}
+
+	return filepath.Join(dir, attachmentDir, filepath.Base(a.ID), name)
+}
+
+// Get returns the item of the post
+func (t *Trash) Get(postID int) (*Item, error) {
+	b, err := os.ReadFile(filepath.Join(t.itemDir(postID), itemFile))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %d", ErrNotInTrash, postID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Item{}
+	if err := json.Unmarshal(b, item); err != nil {
+		return nil, fmt.Errorf("reading trash of post %d: %w", postID, err)
+	}
+
+	return item, nil
+}
+
+// List returns the items, last deleted first
+func (t *Trash) List() ([]*Item, error) {
+	entries, err := os.ReadDir(t.dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*Item
+	for _, e := range entries {
+		id, err := strconv.Atoi(e.Name())
+
+		if err != nil || !e.IsDir() {
+			continue
+		}
+
+		item, err := t.Get(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Restore re-creates the post and its comments with the original authors and
+// PublishedAt timestamps, uploads the attachments again and points the body to them.
+// Authors other than the owner of the token are only kept for owners and admins.
+// The restored post has a new ID.
+func (t *Trash) Restore(postID int) (*docbase.Post, error) {
+	item, err := t.Get(postID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if item.RestoredAs != 0 {
+		return nil, fmt.Errorf("%w: %d as %d", ErrRestored, postID, item.RestoredAs)
+	}
+
+	cp := &repost.Copy{Post: item.Post}
+	for _, a := range item.Post.Attachments {
+		cp.Files = append(cp.Files, t.attachmentPath(t.itemDir(postID), a))
+	}
+
+	restored, err := repost.CreatePost(t.client, cp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// recorded before the comments, so a failure there does not lead to a second copy
+	item.RestoredAs = restored.ID
+	if err := writeItem(t.itemDir(postID), item); err != nil {
+		return restored, err
+	}
+
+	if err := repost.CreateComments(t.client, restored.ID, cp); err != nil {
+		return restored, err
+	}
+
+	return restored, nil
+}
+
+// Purge removes the post from the trash for good
+func (t *Trash) Purge(postID int) error {
+	if _, err := t.Get(postID); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(t.itemDir(postID))
+}
+
+func writeItem(dir string, item *Item) error {
+	b, err := json.MarshalIndent(item, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, itemFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, itemFile))
+}
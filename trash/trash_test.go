@@ -0,0 +1,192 @@
+package trash
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hayashiki/docbase-go"
+	"github.com/hayashiki/docbase-go/internal/fakeserver"
+)
+
+var (
+	created   = time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+	commented = time.Date(2019, 4, 2, 12, 30, 0, 0, time.UTC)
+	image     = []byte("\x89PNG image")
+)
+
+var team = fakeserver.Seed{Groups: []fakeserver.Group{{Name: "dev", Members: []int{1}}}}
+
+// addDesign adds the post the tests trash, showing an attachment
+func addDesign(s *fakeserver.Team) int {
+	a := s.AddAttachment("diagram.png", image)
+
+	return s.Seed(fakeserver.Post{
+		Author: 1,
+		Post: docbase.Post{
+			Title:       "Design",
+			Body:        "# Design\n" + a.Markdown,
+			Scope:       docbase.ScopeGroup,
+			Tags:        []docbase.Tag{{Name: "design"}},
+			Groups:      []docbase.SimpleGroup{{Name: "dev"}},
+			Attachments: []docbase.Attachment{a},
+			CreatedAt:   created,
+		},
+		Comments: []fakeserver.Comment{{Author: 2, Comment: docbase.Comment{Body: "LGTM", CreatedAt: commented}}},
+	})
+}
+
+func TestTrash(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	c, id := s.Client, addDesign(s)
+	old := s.Post(id)
+
+	tr, err := New(c, filepath.Join(t.TempDir(), "trash"))
+
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	item, err := tr.Delete(id)
+
+	if err != nil {
+		t.Fatalf("Delete returned an error: %v", err)
+	}
+
+	if s.Post(id) != nil {
+		t.Errorf("post should be deleted")
+	}
+
+	if len(item.Post.Comments) != 1 || item.Post.Attachments[0].Name != "diagram.png" {
+		t.Errorf("Item is %+v", item.Post)
+	}
+
+	kept, err := os.ReadFile(tr.attachmentPath(tr.itemDir(id), old.Attachments[0]))
+
+	if err != nil || !bytes.Equal(kept, image) {
+		t.Errorf("attachment is %q, %v", kept, err)
+	}
+
+	items, err := tr.List()
+
+	if err != nil || len(items) != 1 || items[0].Post.ID != id {
+		t.Fatalf("List returned %+v, %v", items, err)
+	}
+
+	restored, err := tr.Restore(id)
+
+	if err != nil {
+		t.Fatalf("Restore returned an error: %v", err)
+	}
+
+	p := s.Post(restored.ID)
+
+	if p.User.ID != 1 || !p.CreatedAt.Equal(created) || p.Scope != docbase.ScopeGroup {
+		t.Errorf("restored post is %+v", p)
+	}
+
+	if len(p.Tags) != 1 || p.Tags[0].Name != "design" || len(p.Groups) != 1 || p.Groups[0].Name != "dev" {
+		t.Errorf("restored post has tags %+v and groups %+v", p.Tags, p.Groups)
+	}
+
+	if len(p.Comments) != 1 || p.Comments[0].SimpleUser.ID != 2 || !p.Comments[0].CreatedAt.Equal(commented) {
+		t.Errorf("restored comments are %+v", p.Comments)
+	}
+
+	if strings.Contains(p.Body, old.Attachments[0].URL) || !strings.Contains(p.Body, "/uploads/") {
+		t.Errorf("body should point to the uploaded attachment: %s", p.Body)
+	}
+
+	uploaded := strings.TrimPrefix(p.Body[strings.LastIndex(p.Body, "/uploads/"):], "/uploads/")
+	uploaded = strings.TrimSuffix(uploaded, ")")
+	if got := s.Attachment(uploaded); !bytes.Equal(got, image) {
+		t.Errorf("uploaded attachment %s is %q", uploaded, got)
+	}
+
+	if _, err := tr.Restore(id); !errors.Is(err, ErrRestored) {
+		t.Errorf("Error should be ErrRestored but is %v", err)
+	}
+
+	if err := tr.Purge(id); err != nil {
+		t.Fatalf("Purge returned an error: %v", err)
+	}
+
+	if _, err := tr.Get(id); !errors.Is(err, ErrNotInTrash) {
+		t.Errorf("Error should be ErrNotInTrash but is %v", err)
+	}
+}
+
+func TestTrash_DeleteNotFound(t *testing.T) {
+	s := fakeserver.NewTeam(t, team)
+	c := s.Client
+	tr, _ := New(c, t.TempDir())
+
+	if _, err := tr.Delete(999); err == nil {
+		t.Errorf("Delete should fail for a missing post")
+	}
+
+	items, err := tr.List()
+
+	if err != nil || len(items) != 0 {
+		t.Errorf("List returned %+v, %v", items, err)
+	}
+
+	if reqs := s.Requests(); len(reqs) != 0 {
+		t.Errorf("Requests are %v", reqs)
+	}
+}
+
+// failDeletes answers DELETE requests with status and sends the others
+type failDeletes int
+
+func (status failDeletes) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodDelete {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+
+	return &http.Response{
+		StatusCode: int(status),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":"failed","messages":["failed"]}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestTrash_DeleteFailed(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		status int
+		kept   bool
+	}{
+		{"ServerError", http.StatusInternalServerError, true},
+		{"NotFound", http.StatusNotFound, true},
+		{"Forbidden", http.StatusForbidden, false},
+	}
+	for _, tc := range testCases {
+		s := fakeserver.NewTeam(t, team)
+		id := addDesign(s)
+		c, err := docbase.NewClientWithOptions("fakeTeam", "fakeToken", docbase.WithBaseURL(s.URL),
+			docbase.WithHTTPClient(&http.Client{Transport: failDeletes(tc.status)}))
+
+		if err != nil {
+			t.Fatalf("NewClientWithOptions returned an error: %v", err)
+		}
+
+		tr, _ := New(c, t.TempDir())
+		item, err := tr.Delete(id)
+
+		if err == nil {
+			t.Errorf("%s: Delete should return the error", tc.desc)
+		}
+
+		if _, getErr := tr.Get(id); (getErr == nil) != tc.kept || (item != nil) != tc.kept {
+			t.Errorf("%s: the copy should be kept: %v, got %+v, %v", tc.desc, tc.kept, item, getErr)
+		}
+	}
+}
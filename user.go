@@ -49,30 +49,13 @@ type UserListOptions struct {
 
 // List User
 func (s *userService) List(opts *UserListOptions) (*UserListResponse, *Response, error) {
-	u, err := url.Parse("/users")
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	q := u.Query()
+	q := url.Values{}
 	q.Set("per_page", strconv.Itoa(opts.PerPage))
 	q.Set("page", strconv.Itoa(opts.Page))
 	q.Set("q", opts.Q)
-	q.Set("include_user_groups", opts.Q)
-	u.RawQuery = q.Encode()
-
-	req, err := s.client.NewRequest(http.MethodGet, u.String(), nil)
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	userResp := &UserListResponse{}
-	resp, err := s.client.Do(req, userResp)
-	if err != nil {
-		return nil, resp, err
+	if opts.IncludeUserGroups {
+		q.Set("include_user_groups", "true")
 	}
 
-	return userResp, resp, err
+	return doJSON[UserListResponse](s.client, http.MethodGet, withQuery("/users", q), nil)
 }
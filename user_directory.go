@@ -0,0 +1,305 @@
+package docbase
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const userListPerPage = 100
+
+var (
+	// ErrUserNotFound is returned when no user matches
+	ErrUserNotFound = errors.New("user not found")
+	// ErrAmbiguousUser is returned when several users have the name
+	ErrAmbiguousUser = errors.New("user name is ambiguous")
+)
+
+// UserDirectory looks up users by ID, username or name. All users with their groups are
+// loaded lazily and kept for the TTL. A username or name missing from the index is searched
+// for and added, so new users are found without reloading everyone. A search finding no one
+// is not repeated within missRefreshInterval.
+type UserDirectory struct {
+	client *Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu         sync.Mutex
+	byID       map[int]User
+	byUsername map[string]int
+	byName     map[string][]int
+	loadedAt   time.Time
+	// missed is when a search for a username or name found no one
+	missed map[string]time.Time
+}
+
+// NewUserDirectory returns a UserDirectory caching users for ttl
+func NewUserDirectory(c *Client, ttl time.Duration) *UserDirectory {
+	return &UserDirectory{client: c, ttl: ttl, now: time.Now}
+}
+
+// Lookup resolves a mention like "@docbaseman", a search term like "author:docbaseman",
+// a numeric ID, a username or a name
+func (d *UserDirectory) Lookup(s string) (*User, error) {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case strings.HasPrefix(s, "@"):
+		return d.ByUsername(strings.TrimPrefix(s, "@"))
+	case strings.HasPrefix(s, "author:"):
+		return d.ByUsername(strings.TrimPrefix(s, "author:"))
+	}
+
+	if id, err := strconv.Atoi(s); err == nil {
+		return d.ByID(id)
+	}
+
+	return d.byUsernameOrName(s)
+}
+
+// byUsernameOrName prefers the username and searches once for both
+func (d *UserDirectory) byUsernameOrName(s string) (*User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := d.byUsername[s]; !ok && len(d.byName[s]) == 0 {
+		if err := d.search(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if id, ok := d.byUsername[s]; ok {
+		u := d.byID[id]
+		return &u, nil
+	}
+
+	return d.uniqueName(s)
+}
+
+// ByID returns the user of the ID
+func (d *UserDirectory) ByID(id int) (*User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return nil, err
+	}
+
+	if u, ok := d.byID[id]; ok {
+		return &u, nil
+	}
+
+	// IDs cannot be searched for, so reload everyone
+	if d.now().Sub(d.loadedAt) >= missRefreshInterval {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+
+		if u, ok := d.byID[id]; ok {
+			return &u, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: #%d", ErrUserNotFound, id)
+}
+
+// ByUsername returns the user of the username
+func (d *UserDirectory) ByUsername(username string) (*User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return nil, err
+	}
+
+	if id, ok := d.byUsername[username]; ok {
+		u := d.byID[id]
+		return &u, nil
+	}
+
+	if err := d.search(username); err != nil {
+		return nil, err
+	}
+
+	if id, ok := d.byUsername[username]; ok {
+		u := d.byID[id]
+		return &u, nil
+	}
+
+	return nil, fmt.Errorf("%w: @%s", ErrUserNotFound, username)
+}
+
+// ByName returns the user of the name, which must be unique
+func (d *UserDirectory) ByName(name string) (*User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return nil, err
+	}
+
+	if len(d.byName[name]) == 0 {
+		if err := d.search(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.uniqueName(name)
+}
+
+func (d *UserDirectory) uniqueName(name string) (*User, error) {
+	switch ids := d.byName[name]; len(ids) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	case 1:
+		u := d.byID[ids[0]]
+		return &u, nil
+	default:
+		return nil, fmt.Errorf("%w: %d users are named %s", ErrAmbiguousUser, len(ids), name)
+	}
+}
+
+// All returns all users
+func (d *UserDirectory) All() ([]User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.loadIfExpired(); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(d.byID))
+	for _, u := range d.byID {
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// Refresh reloads all users
+func (d *UserDirectory) Refresh() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.load()
+}
+
+func (d *UserDirectory) loadIfExpired() error {
+	if d.byID != nil && d.now().Sub(d.loadedAt) < d.ttl {
+		return nil
+	}
+
+	return d.load()
+}
+
+// load pages through all users, keeping the previous index when it fails
+func (d *UserDirectory) load() error {
+	var all []User
+
+	for page := 1; ; page++ {
+		users, err := d.list(page, "")
+
+		if err != nil {
+			return err
+		}
+
+		all = append(all, users...)
+
+		if len(users) < userListPerPage {
+			break
+		}
+	}
+
+	d.byID = make(map[int]User)
+	d.byUsername = make(map[string]int)
+	d.byName = make(map[string][]int)
+
+	for _, u := range all {
+		d.add(u)
+	}
+
+	d.loadedAt = d.now()
+	d.missed = nil
+
+	return nil
+}
+
+// search adds the users matching q to the index, unless it found no one recently
+func (d *UserDirectory) search(q string) error {
+	now := d.now()
+
+	if at, ok := d.missed[q]; ok && now.Sub(at) < missRefreshInterval {
+		return nil
+	}
+
+	users, err := d.list(1, q)
+
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, u := range users {
+		d.add(u)
+		found = found || u.Username == q || u.Name == q
+	}
+
+	for name, at := range d.missed {
+		if now.Sub(at) >= missRefreshInterval {
+			delete(d.missed, name)
+		}
+	}
+
+	if !found {
+		if d.missed == nil {
+			d.missed = make(map[string]time.Time)
+		}
+		d.missed[q] = now
+	}
+
+	return nil
+}
+
+func (d *UserDirectory) list(page int, q string) (UserListResponse, error) {
+	users, _, err := d.client.Users.List(&UserListOptions{
+		Q:                 q,
+		Page:              page,
+		PerPage:           userListPerPage,
+		IncludeUserGroups: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return *users, nil
+}
+
+func (d *UserDirectory) add(u User) {
+	if old, ok := d.byID[u.ID]; ok {
+		d.byName[old.Name] = removeID(d.byName[old.Name], u.ID)
+		delete(d.byUsername, old.Username)
+	}
+
+	d.byID[u.ID] = u
+	d.byUsername[u.Username] = u.ID
+	d.byName[u.Name] = append(d.byName[u.Name], u.ID)
+}
+
+func removeID(ids []int, id int) []int {
+	kept := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
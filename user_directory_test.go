@@ -0,0 +1,190 @@
+package docbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// handleUsers serves users filtered by q in pages and records the queries
+func handleUsers(t *testing.T, users *[]User, queries *[]string) {
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+
+		if got := r.URL.Query().Get("include_user_groups"); got != "true" {
+			t.Errorf("include_user_groups is %q, want true", got)
+		}
+
+		q := r.URL.Query().Get("q")
+		*queries = append(*queries, q)
+
+		var matched []User
+		for _, u := range *users {
+			if q == "" || strings.Contains(u.Username, q) || strings.Contains(u.Name, q) {
+				matched = append(matched, u)
+			}
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		start, end := (page-1)*perPage, page*perPage
+		if start > len(matched) {
+			start = len(matched)
+		}
+		if end > len(matched) {
+			end = len(matched)
+		}
+
+		json.NewEncoder(w).Encode(matched[start:end])
+	})
+}
+
+func TestUserDirectory_Lookup(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var users []User
+	for i := 1; i <= userListPerPage+1; i++ {
+		users = append(users, User{ID: i, Username: fmt.Sprintf("user%d", i), Name: fmt.Sprintf("ユーザー%d", i)})
+	}
+	users[0].Groups = []SimpleGroup{{ID: 1, Name: "DocBase"}}
+	users[1].Name = "同姓同名"
+	users[2].Name = "同姓同名"
+
+	var queries []string
+	handleUsers(t, &users, &queries)
+
+	d := NewUserDirectory(client, time.Hour)
+
+	testCases := []struct {
+		desc string
+		in   string
+		want int
+	}{
+		{"Mention", "@user1", 1},
+		{"Author", "author:user101", 101},
+		{"ID", "5", 5},
+		{"Username", "user7", 7},
+		{"Name", "ユーザー9", 9},
+	}
+	for _, tc := range testCases {
+		u, err := d.Lookup(tc.in)
+
+		if err != nil {
+			t.Errorf("%s: Lookup returned an error: %v", tc.desc, err)
+			continue
+		}
+
+		if u.ID != tc.want {
+			t.Errorf("%s: Lookup returned #%d, want #%d", tc.desc, u.ID, tc.want)
+		}
+	}
+
+	if u, _ := d.ByID(1); len(u.Groups) != 1 {
+		t.Errorf("User should include groups: %+v", u)
+	}
+
+	if _, err := d.ByName("同姓同名"); !errors.Is(err, ErrAmbiguousUser) {
+		t.Errorf("Error should be ErrAmbiguousUser but is %v", err)
+	}
+
+	if len(queries) != 2 {
+		t.Errorf("Users should be loaded once over 2 pages: %v", queries)
+	}
+
+	// a new user is found by searching only for it
+	users = append(users, User{ID: 500, Username: "newcomer", Name: "新人"})
+
+	if u, err := d.Lookup("@newcomer"); err != nil || u.ID != 500 {
+		t.Errorf("Lookup returned %+v, %v, want #500", u, err)
+	}
+
+	if got := queries[len(queries)-1]; len(queries) != 3 || got != "newcomer" {
+		t.Errorf("Missing user should be searched for: %v", queries)
+	}
+
+	if _, err := d.Lookup("@nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Error should be ErrUserNotFound but is %v", err)
+	}
+
+	all, _ := d.All()
+	if len(all) != userListPerPage+2 {
+		t.Errorf("All returned %d users", len(all))
+	}
+}
+
+func TestUserDirectory_RefreshFailed(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var fail bool
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode([]User{{ID: 1, Username: "user1", Name: "ユーザー1"}})
+	})
+
+	d := NewUserDirectory(client, time.Hour)
+
+	if _, err := d.ByID(1); err != nil {
+		t.Fatalf("ByID returned an error: %v", err)
+	}
+
+	fail = true
+
+	if err := d.Refresh(); err == nil {
+		t.Fatalf("Refresh should return the error")
+	}
+
+	if u, err := d.ByUsername("user1"); err != nil || u.ID != 1 {
+		t.Errorf("Users loaded before the failed refresh should be kept: %+v, %v", u, err)
+	}
+}
+
+func TestUserDirectory_MissThrottled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	users := []User{{ID: 1, Username: "user1", Name: "ユーザー1"}}
+	var queries []string
+	handleUsers(t, &users, &queries)
+
+	now := time.Now()
+	d := NewUserDirectory(client, time.Hour)
+	d.now = func() time.Time { return now }
+
+	for _, lookup := range []func() (*User, error){
+		func() (*User, error) { return d.ByUsername("nobody") },
+		func() (*User, error) { return d.ByUsername("nobody") },
+		func() (*User, error) { return d.Lookup("nobody") },
+		func() (*User, error) { return d.ByName("nobody") },
+	} {
+		if _, err := lookup(); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("Error should be ErrUserNotFound but is %v", err)
+		}
+	}
+
+	if len(queries) != 2 || queries[1] != "nobody" {
+		t.Errorf("Users should be loaded once and the name searched for once: %v", queries)
+	}
+
+	// searched for again once missRefreshInterval passed
+	users = append(users, User{ID: 2, Username: "nobody", Name: "新人"})
+	now = now.Add(missRefreshInterval)
+
+	if u, err := d.ByUsername("nobody"); err != nil || u.ID != 2 {
+		t.Errorf("ByUsername returned %+v, %v, want #2", u, err)
+	}
+
+	if len(queries) != 3 {
+		t.Errorf("Missing user should be searched for again: %v", queries)
+	}
+}
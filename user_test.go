@@ -85,3 +85,32 @@ func TestUserService_List(t *testing.T) {
 		t.Errorf("Users returned %+v, want %+v", users, want)
 	}
 }
+
+func TestUserService_List_Query(t *testing.T) {
+	setup()
+	defer teardown()
+
+	testCases := []struct {
+		opts *UserListOptions
+		want string
+	}{
+		{&UserListOptions{Q: "docbase", Page: 1, PerPage: 5}, "page=1&per_page=5&q=docbase"},
+		{&UserListOptions{Q: "docbase", Page: 1, PerPage: 5, IncludeUserGroups: true}, "include_user_groups=true&page=1&per_page=5&q=docbase"},
+	}
+
+	var got string
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RawQuery
+		fmt.Fprint(w, `[]`)
+	})
+
+	for _, tc := range testCases {
+		if _, _, err := client.Users.List(tc.opts); err != nil {
+			t.Errorf("List returned an error: %v", err)
+		}
+
+		if got != tc.want {
+			t.Errorf("Query is %v, want %v", got, tc.want)
+		}
+	}
+}